@@ -0,0 +1,99 @@
+// Package bounce records the bounces and complaints reported for the email contact points
+// of entities, whether the reports are retrieved from a mailbox or posted to the API.
+package bounce
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Processor records the bounces reported by delivery status notifications and feedback
+// reports against the email contact points they bounced from.
+type Processor struct {
+	dbc       *sqlx.DB
+	logger    *zap.Logger
+	metrics   *metrics.Metrics
+	threshold int
+}
+
+// NewProcessor returns a reference to a Processor that suspends contact points once they've
+// hard bounced threshold times.
+func NewProcessor(dbc *sqlx.DB, logger *zap.Logger, m *metrics.Metrics, threshold int) *Processor {
+	return &Processor{
+		dbc:       dbc,
+		logger:    logger,
+		metrics:   m,
+		threshold: threshold,
+	}
+}
+
+// Record records each of the given bounces against the email contact points with the
+// bounced address, either all of them being recorded or none, so that a report whose
+// bounces fail to be recorded can be recorded again without counting any twice. Bounces
+// from addresses that no contact point has are ignored.
+func (p *Processor) Record(ctx context.Context, bounces []mail.Bounce) error {
+	updated, err := entity.RecordBounces(ctx, p.dbc, bounces, p.threshold)
+	if err != nil {
+		return fmt.Errorf("record bounces: %w", err)
+	}
+
+	for i, b := range bounces {
+		contactPoints := updated[i]
+		p.metrics.IncBounces(b.Kind)
+
+		p.logger.Info("bounce recorded",
+			zap.String("recipient", b.Recipient),
+			zap.String("kind", b.Kind),
+			zap.String("status", b.Status),
+			zap.String("reason", b.Reason),
+			zap.Int("contactPoints", len(contactPoints)))
+
+		for _, cp := range contactPoints {
+			if cp.NewlySuspended {
+				p.logger.Warn("contact point suspended",
+					zap.Int("entityID", cp.EntityID),
+					zap.Int("contactPointID", cp.ID),
+					zap.Int("bounceCount", cp.BounceCount),
+					zap.String("reason", cp.BounceReason))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Poll fetches the reports in the given mailbox, recording their bounces, straight away
+// and then every interval until ctx is done. Messages that aren't reports are discarded,
+// whereas reports whose bounces fail to be recorded are kept for the next fetch.
+func (p *Processor) Poll(ctx context.Context, mailbox *mail.Mailbox, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := mailbox.Fetch(ctx, func(msg []byte) error {
+			bounces, err := mail.ParseReport(bytes.NewReader(msg))
+			if err != nil {
+				p.logger.Warn("discarding message that isn't a bounce report", zap.Error(err))
+				return nil
+			}
+
+			return p.Record(ctx, bounces)
+		}); err != nil {
+			p.logger.Error("fetch bounce reports", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
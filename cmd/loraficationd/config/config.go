@@ -6,11 +6,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/db"
 	"github.com/22arw/lorafication/internal/platform/duration"
+	"github.com/22arw/lorafication/internal/platform/validate"
 	"github.com/kelseyhightower/envconfig"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v3"
@@ -40,6 +48,34 @@ const (
 	// DefaultDBPort is the default value of the DBPort struct field on the Config type.
 	DefaultDBPort = 5432
 
+	// DefaultDBSSLMode is the default value of the DBSSLMode struct field on the Config
+	// type.
+	DefaultDBSSLMode = "disable"
+
+	// DefaultDBApplicationName is the default value of the DBApplicationName struct field
+	// on the Config type.
+	DefaultDBApplicationName = "loraficationd"
+
+	// DefaultDBMaxOpenConns is the default value of the DBMaxOpenConns struct field on the
+	// Config type.
+	DefaultDBMaxOpenConns = 10
+
+	// DefaultDBMaxIdleConns is the default value of the DBMaxIdleConns struct field on the
+	// Config type.
+	DefaultDBMaxIdleConns = 5
+
+	// DefaultDBConnMaxLifetime is the default value of the DBConnMaxLifetime struct field
+	// on the Config type.
+	DefaultDBConnMaxLifetime = 30 * time.Minute
+
+	// DefaultMailTransport is the default value of the MailTransport struct field on the
+	// Config type.
+	DefaultMailTransport = mail.TransportSMTP
+
+	// DefaultLocalSMTPFrom is the default value of the SMTPFrom struct field on the Config
+	// type when neither it nor SMTPUser are set and email isn't sent over SMTP.
+	DefaultLocalSMTPFrom = "lorafication@localhost"
+
 	// DefaultSMTPHost is the default value of the SMTPHost struct field on the Config
 	// type.
 	DefaultSMTPHost = "smtp.gmail.com"
@@ -48,6 +84,101 @@ const (
 	// type.
 	DefaultSMTPPort = 587
 
+	// DefaultSMTPTLSMode is the default value of the SMTPTLSMode struct field on the Config
+	// type.
+	DefaultSMTPTLSMode = mail.TLSModeStartTLSRequired
+
+	// DefaultSMTPAuth is the default value of the SMTPAuth struct field on the Config type.
+	DefaultSMTPAuth = mail.AuthPlain
+
+	// DefaultSMTPDialTimeout is the default value of the SMTPDialTimeout struct field on
+	// the Config type.
+	DefaultSMTPDialTimeout = 10 * time.Second
+
+	// DefaultSMTPCommandTimeout is the default value of the SMTPCommandTimeout struct field
+	// on the Config type.
+	DefaultSMTPCommandTimeout = 30 * time.Second
+
+	// DefaultSMTPCooldown is the default value of the SMTPCooldown struct field on the
+	// Config type.
+	DefaultSMTPCooldown = mail.DefaultCooldown
+
+	// DefaultSMTPMaxSessions is the default value of the SMTPMaxSessions struct field on
+	// the Config type.
+	DefaultSMTPMaxSessions = mail.DefaultMaxSessions
+
+	// DefaultSMTPIdleTimeout is the default value of the SMTPIdleTimeout struct field on
+	// the Config type.
+	DefaultSMTPIdleTimeout = mail.DefaultIdleTimeout
+
+	// DefaultBounceThreshold is the default value of the BounceThreshold struct field on
+	// the Config type.
+	DefaultBounceThreshold = 3
+
+	// DefaultBouncePOP3Port is the default value of the BouncePOP3Port struct field on the
+	// Config type.
+	DefaultBouncePOP3Port = 995
+
+	// DefaultBouncePOP3TLSMode is the default value of the BouncePOP3TLSMode struct field
+	// on the Config type.
+	DefaultBouncePOP3TLSMode = mail.TLSModeImplicit
+
+	// DefaultBouncePOP3Interval is the default value of the BouncePOP3Interval struct
+	// field on the Config type.
+	DefaultBouncePOP3Interval = 5 * time.Minute
+
+	// DefaultDeliveryMaxAttempts is the default value of the DeliveryMaxAttempts struct
+	// field on the Config type.
+	DefaultDeliveryMaxAttempts = delivery.DefaultMaxAttempts
+
+	// DefaultDeliveryBackoff is the default value of the DeliveryBackoff struct field on
+	// the Config type.
+	DefaultDeliveryBackoff = delivery.DefaultBackoff
+
+	// DefaultDeliveryInterval is the default value of the DeliveryInterval struct field on
+	// the Config type.
+	DefaultDeliveryInterval = delivery.DefaultInterval
+
+	// DefaultWebhookTimeout is the default value of the WebhookTimeout struct field on the
+	// Config type.
+	DefaultWebhookTimeout = channel.DefaultWebhookTimeout
+
+	// DefaultTelegramAPIURL is the default value of the TelegramAPIURL struct field on the
+	// Config type.
+	DefaultTelegramAPIURL = channel.DefaultTelegramAPIURL
+
+	// DefaultPushoverAPIURL is the default value of the PushoverAPIURL struct field on the
+	// Config type.
+	DefaultPushoverAPIURL = channel.DefaultPushoverAPIURL
+
+	// DefaultPushoverRetry is the default value of the PushoverRetry struct field on the
+	// Config type.
+	DefaultPushoverRetry = channel.DefaultPushoverRetry
+
+	// DefaultPushoverExpire is the default value of the PushoverExpire struct field on the
+	// Config type.
+	DefaultPushoverExpire = channel.DefaultPushoverExpire
+
+	// DefaultVoiceTwilioAPIURL is the default value of the VoiceTwilioAPIURL struct field
+	// on the Config type.
+	DefaultVoiceTwilioAPIURL = channel.DefaultTwilioAPIURL
+
+	// DefaultTracingSampleRatio is the default value of the TracingSampleRatio struct
+	// field on the Config type.
+	DefaultTracingSampleRatio = 1.0
+
+	// DefaultPublicURL is the default value of the PublicURL struct field on the Config
+	// type.
+	DefaultPublicURL = "http://localhost:9000"
+
+	// DefaultVerificationTTL is the default value of the VerificationTTL struct field on
+	// the Config type.
+	DefaultVerificationTTL = 72 * time.Hour
+
+	// MinSigningKeyLength is the minimum length of the SigningKey struct field on the
+	// Config type.
+	MinSigningKeyLength = 32
+
 	// DefaultReadTimeout is the default value of the ReadTimeout struct field on the
 	// Config type.
 	DefaultReadTimeout = 10 * time.Second
@@ -61,28 +192,136 @@ const (
 	DefaultShutdownTimeout = 20 * time.Second
 )
 
+// telegramSecretPattern matches the secret tokens that Telegram accepts for webhooks.
+var telegramSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
 // Config is a struct that contains the struct fields necessary for running the
 // lorafication daemon.
 type Config struct {
-	LogLevel int `json:"logLevel" yaml:"logLevel" envconfig:"LOG_LEVEL"`
-	Port     int `json:"port" yaml:"port" envconfig:"PORT"`
+	LogLevel    int `json:"logLevel" yaml:"logLevel" envconfig:"LOG_LEVEL"`
+	Port        int `json:"port" yaml:"port" envconfig:"PORT"`
+	MetricsPort int `json:"metricsPort" yaml:"metricsPort" envconfig:"METRICS_PORT"`
 
 	DBUser string `json:"dbUser" yaml:"dbUser" envconfig:"DB_USER"`
 	DBPass string `json:"dbPass" yaml:"dbPass" envconfig:"DB_PASS"`
 	DBName string `json:"dbName" yaml:"dbName" envconfig:"DB_NAME"`
 	DBHost string `json:"dbHost" yaml:"dbHost" envconfig:"DB_HOST"`
 	DBPort int    `json:"dbPort" yaml:"dbPort" envconfig:"DB_PORT"`
+	DBURL  string `json:"dbURL" yaml:"dbURL" envconfig:"DB_URL"`
+
+	DBSSLMode     string `json:"dbSSLMode" yaml:"dbSSLMode" envconfig:"DB_SSL_MODE"`
+	DBSSLRootCert string `json:"dbSSLRootCert" yaml:"dbSSLRootCert" envconfig:"DB_SSL_ROOT_CERT"`
+	DBSSLCert     string `json:"dbSSLCert" yaml:"dbSSLCert" envconfig:"DB_SSL_CERT"`
+	DBSSLKey      string `json:"dbSSLKey" yaml:"dbSSLKey" envconfig:"DB_SSL_KEY"`
+
+	DBApplicationName  string            `json:"dbApplicationName" yaml:"dbApplicationName" envconfig:"DB_APPLICATION_NAME"`
+	DBStatementTimeout duration.Duration `json:"dbStatementTimeout" yaml:"dbStatementTimeout" envconfig:"DB_STATEMENT_TIMEOUT"`
+	DBMaxOpenConns     int               `json:"dbMaxOpenConns" yaml:"dbMaxOpenConns" envconfig:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns     int               `json:"dbMaxIdleConns" yaml:"dbMaxIdleConns" envconfig:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime  duration.Duration `json:"dbConnMaxLifetime" yaml:"dbConnMaxLifetime" envconfig:"DB_CONN_MAX_LIFETIME"`
+
+	MailTransport string `json:"mailTransport" yaml:"mailTransport" envconfig:"MAIL_TRANSPORT"`
+	MailPath      string `json:"mailPath" yaml:"mailPath" envconfig:"MAIL_PATH"`
 
 	SMTPHost string `json:"smtpHost" yaml:"smtpHost" envconfig:"SMTP_HOST"`
 	SMTPPort int    `json:"smtpPort" yaml:"smtpPort" envconfig:"SMTP_PORT"`
 	SMTPUser string `json:"smtpUser" yaml:"smtpUser" envconfig:"SMTP_USER"`
 	SMTPPass string `json:"smtpPass" yaml:"smtpPass" envconfig:"SMTP_PASS"`
 
+	SMTPFrom         string `json:"smtpFrom" yaml:"smtpFrom" envconfig:"SMTP_FROM"`
+	SMTPFromName     string `json:"smtpFromName" yaml:"smtpFromName" envconfig:"SMTP_FROM_NAME"`
+	SMTPReplyTo      string `json:"smtpReplyTo" yaml:"smtpReplyTo" envconfig:"SMTP_REPLY_TO"`
+	SMTPEnvelopeFrom string `json:"smtpEnvelopeFrom" yaml:"smtpEnvelopeFrom" envconfig:"SMTP_ENVELOPE_FROM"`
+
+	SMTPTLSMode            string            `json:"smtpTLSMode" yaml:"smtpTLSMode" envconfig:"SMTP_TLS_MODE"`
+	SMTPCAFile             string            `json:"smtpCAFile" yaml:"smtpCAFile" envconfig:"SMTP_CA_FILE"`
+	SMTPInsecureSkipVerify bool              `json:"smtpInsecureSkipVerify" yaml:"smtpInsecureSkipVerify" envconfig:"SMTP_INSECURE_SKIP_VERIFY"`
+	SMTPAuth               string            `json:"smtpAuth" yaml:"smtpAuth" envconfig:"SMTP_AUTH"`
+	SMTPHELOName           string            `json:"smtpHELOName" yaml:"smtpHELOName" envconfig:"SMTP_HELO_NAME"`
+	SMTPDialTimeout        duration.Duration `json:"smtpDialTimeout" yaml:"smtpDialTimeout" envconfig:"SMTP_DIAL_TIMEOUT"`
+	SMTPCommandTimeout     duration.Duration `json:"smtpCommandTimeout" yaml:"smtpCommandTimeout" envconfig:"SMTP_COMMAND_TIMEOUT"`
+	SMTPMaxSessions        int               `json:"smtpMaxSessions" yaml:"smtpMaxSessions" envconfig:"SMTP_MAX_SESSIONS"`
+	SMTPIdleTimeout        duration.Duration `json:"smtpIdleTimeout" yaml:"smtpIdleTimeout" envconfig:"SMTP_IDLE_TIMEOUT"`
+
+	DKIMDomain   string `json:"dkimDomain" yaml:"dkimDomain" envconfig:"DKIM_DOMAIN"`
+	DKIMSelector string `json:"dkimSelector" yaml:"dkimSelector" envconfig:"DKIM_SELECTOR"`
+	DKIMKeyFile  string `json:"dkimKeyFile" yaml:"dkimKeyFile" envconfig:"DKIM_KEY_FILE"`
+
+	SMTPPriority int               `json:"smtpPriority" yaml:"smtpPriority" envconfig:"SMTP_PRIORITY"`
+	SMTPRelays   SMTPRelays        `json:"smtpRelays" yaml:"smtpRelays" envconfig:"SMTP_RELAYS"`
+	SMTPCooldown duration.Duration `json:"smtpCooldown" yaml:"smtpCooldown" envconfig:"SMTP_COOLDOWN"`
+
+	BounceThreshold    int               `json:"bounceThreshold" yaml:"bounceThreshold" envconfig:"BOUNCE_THRESHOLD"`
+	BouncePOP3Host     string            `json:"bouncePOP3Host" yaml:"bouncePOP3Host" envconfig:"BOUNCE_POP3_HOST"`
+	BouncePOP3Port     int               `json:"bouncePOP3Port" yaml:"bouncePOP3Port" envconfig:"BOUNCE_POP3_PORT"`
+	BouncePOP3User     string            `json:"bouncePOP3User" yaml:"bouncePOP3User" envconfig:"BOUNCE_POP3_USER"`
+	BouncePOP3Pass     string            `json:"bouncePOP3Pass" yaml:"bouncePOP3Pass" envconfig:"BOUNCE_POP3_PASS"`
+	BouncePOP3TLSMode  string            `json:"bouncePOP3TLSMode" yaml:"bouncePOP3TLSMode" envconfig:"BOUNCE_POP3_TLS_MODE"`
+	BouncePOP3CAFile   string            `json:"bouncePOP3CAFile" yaml:"bouncePOP3CAFile" envconfig:"BOUNCE_POP3_CA_FILE"`
+	BouncePOP3Interval duration.Duration `json:"bouncePOP3Interval" yaml:"bouncePOP3Interval" envconfig:"BOUNCE_POP3_INTERVAL"`
+
+	DeliveryMaxAttempts int               `json:"deliveryMaxAttempts" yaml:"deliveryMaxAttempts" envconfig:"DELIVERY_MAX_ATTEMPTS"`
+	DeliveryBackoff     duration.Duration `json:"deliveryBackoff" yaml:"deliveryBackoff" envconfig:"DELIVERY_BACKOFF"`
+	DeliveryInterval    duration.Duration `json:"deliveryInterval" yaml:"deliveryInterval" envconfig:"DELIVERY_INTERVAL"`
+	WebhookTimeout      duration.Duration `json:"webhookTimeout" yaml:"webhookTimeout" envconfig:"WEBHOOK_TIMEOUT"`
+
+	TelegramAPIURL        string `json:"telegramAPIURL" yaml:"telegramAPIURL" envconfig:"TELEGRAM_API_URL"`
+	TelegramBotToken      string `json:"telegramBotToken" yaml:"telegramBotToken" envconfig:"TELEGRAM_BOT_TOKEN"`
+	TelegramWebhookSecret string `json:"telegramWebhookSecret" yaml:"telegramWebhookSecret" envconfig:"TELEGRAM_WEBHOOK_SECRET"`
+	MatrixHomeserverURL   string `json:"matrixHomeserverURL" yaml:"matrixHomeserverURL" envconfig:"MATRIX_HOMESERVER_URL"`
+	MatrixAccessToken     string `json:"matrixAccessToken" yaml:"matrixAccessToken" envconfig:"MATRIX_ACCESS_TOKEN"`
+
+	PushoverAPIURL   string            `json:"pushoverAPIURL" yaml:"pushoverAPIURL" envconfig:"PUSHOVER_API_URL"`
+	PushoverAppToken string            `json:"pushoverAppToken" yaml:"pushoverAppToken" envconfig:"PUSHOVER_APP_TOKEN"`
+	PushoverRetry    duration.Duration `json:"pushoverRetry" yaml:"pushoverRetry" envconfig:"PUSHOVER_RETRY"`
+	PushoverExpire   duration.Duration `json:"pushoverExpire" yaml:"pushoverExpire" envconfig:"PUSHOVER_EXPIRE"`
+
+	VoiceProvider         string `json:"voiceProvider" yaml:"voiceProvider" envconfig:"VOICE_PROVIDER"`
+	VoiceBridgeURL        string `json:"voiceBridgeURL" yaml:"voiceBridgeURL" envconfig:"VOICE_BRIDGE_URL"`
+	VoiceTwilioAPIURL     string `json:"voiceTwilioAPIURL" yaml:"voiceTwilioAPIURL" envconfig:"VOICE_TWILIO_API_URL"`
+	VoiceTwilioAccountSID string `json:"voiceTwilioAccountSID" yaml:"voiceTwilioAccountSID" envconfig:"VOICE_TWILIO_ACCOUNT_SID"`
+	VoiceTwilioAuthToken  string `json:"voiceTwilioAuthToken" yaml:"voiceTwilioAuthToken" envconfig:"VOICE_TWILIO_AUTH_TOKEN"`
+	VoiceTwilioFrom       string `json:"voiceTwilioFrom" yaml:"voiceTwilioFrom" envconfig:"VOICE_TWILIO_FROM"`
+
+	TracingEndpoint    string  `json:"tracingEndpoint" yaml:"tracingEndpoint" envconfig:"TRACING_ENDPOINT"`
+	TracingInsecure    bool    `json:"tracingInsecure" yaml:"tracingInsecure" envconfig:"TRACING_INSECURE"`
+	TracingSampleRatio float64 `json:"tracingSampleRatio" yaml:"tracingSampleRatio" envconfig:"TRACING_SAMPLE_RATIO"`
+
+	PublicURL       string            `json:"publicURL" yaml:"publicURL" envconfig:"PUBLIC_URL"`
+	SigningKey      string            `json:"signingKey" yaml:"signingKey" envconfig:"SIGNING_KEY"`
+	AdminToken      string            `json:"adminToken" yaml:"adminToken" envconfig:"ADMIN_TOKEN"`
+	VerificationTTL duration.Duration `json:"verificationTTL" yaml:"verificationTTL" envconfig:"VERIFICATION_TTL"`
+
 	ReadTimeout     duration.Duration `json:"readTimeout" yaml:"readTimeout" envconfig:"READ_TIMEOUT"`
 	WriteTimeout    duration.Duration `json:"writeTimeout" yaml:"writeTimeout" envconfig:"WRITE_TIMEOUT"`
 	ShutdownTimeout duration.Duration `json:"shutdownTimeout" yaml:"shutdownTimeout" envconfig:"SHUTDOWN_TIMEOUT"`
 }
 
+// SMTPRelay is the configuration of an SMTP relay that email fails over to, or from,
+// alongside the one configured by the SMTP struct fields of the Config type. Its timeouts
+// and session limits are those of the Config type.
+type SMTPRelay struct {
+	Host               string `json:"host" yaml:"host"`
+	Port               int    `json:"port" yaml:"port"`
+	User               string `json:"user" yaml:"user"`
+	Pass               string `json:"pass" yaml:"pass"`
+	Priority           int    `json:"priority" yaml:"priority"`
+	TLSMode            string `json:"tlsMode" yaml:"tlsMode"`
+	CAFile             string `json:"caFile" yaml:"caFile"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify" yaml:"insecureSkipVerify"`
+	Auth               string `json:"auth" yaml:"auth"`
+	HELOName           string `json:"heloName" yaml:"heloName"`
+}
+
+// SMTPRelays is a list of SMTP relays, given as a JSON array when it's pulled from the
+// environment.
+type SMTPRelays []SMTPRelay
+
+// Decode implements the envconfig.Decoder interface.
+func (r *SMTPRelays) Decode(value string) error {
+	return json.Unmarshal([]byte(value), (*[]SMTPRelay)(r))
+}
+
 // FromEnvironment gathers the configuration variables from the environment.
 func FromEnvironment() (Config, error) {
 	var c Config
@@ -159,6 +398,30 @@ func (c *Config) Defaults() {
 		c.DBPort = DefaultDBPort
 	}
 
+	if c.DBSSLMode == "" {
+		c.DBSSLMode = DefaultDBSSLMode
+	}
+
+	if c.DBApplicationName == "" {
+		c.DBApplicationName = DefaultDBApplicationName
+	}
+
+	if c.DBMaxOpenConns == 0 {
+		c.DBMaxOpenConns = DefaultDBMaxOpenConns
+	}
+
+	if c.DBMaxIdleConns == 0 {
+		c.DBMaxIdleConns = DefaultDBMaxIdleConns
+	}
+
+	if c.DBConnMaxLifetime.IsEmpty() {
+		c.DBConnMaxLifetime.Duration = DefaultDBConnMaxLifetime
+	}
+
+	if c.MailTransport == "" {
+		c.MailTransport = DefaultMailTransport
+	}
+
 	if c.SMTPHost == "" {
 		c.SMTPHost = DefaultSMTPHost
 	}
@@ -167,6 +430,127 @@ func (c *Config) Defaults() {
 		c.SMTPPort = DefaultSMTPPort
 	}
 
+	if c.SMTPFrom == "" {
+		c.SMTPFrom = c.SMTPUser
+	}
+
+	if c.SMTPFrom == "" && c.MailTransport != mail.TransportSMTP {
+		c.SMTPFrom = DefaultLocalSMTPFrom
+	}
+
+	if c.SMTPTLSMode == "" {
+		c.SMTPTLSMode = DefaultSMTPTLSMode
+	}
+
+	if c.SMTPAuth == "" {
+		c.SMTPAuth = DefaultSMTPAuth
+	}
+
+	if c.SMTPDialTimeout.IsEmpty() {
+		c.SMTPDialTimeout.Duration = DefaultSMTPDialTimeout
+	}
+
+	if c.SMTPCommandTimeout.IsEmpty() {
+		c.SMTPCommandTimeout.Duration = DefaultSMTPCommandTimeout
+	}
+
+	for i := range c.SMTPRelays {
+		relay := &c.SMTPRelays[i]
+
+		if relay.Port == 0 {
+			relay.Port = DefaultSMTPPort
+		}
+
+		if relay.TLSMode == "" {
+			relay.TLSMode = DefaultSMTPTLSMode
+		}
+
+		if relay.Auth == "" {
+			relay.Auth = DefaultSMTPAuth
+		}
+
+		if relay.HELOName == "" {
+			relay.HELOName = c.SMTPHELOName
+		}
+	}
+
+	if c.SMTPCooldown.IsEmpty() {
+		c.SMTPCooldown.Duration = DefaultSMTPCooldown
+	}
+
+	if c.SMTPMaxSessions == 0 {
+		c.SMTPMaxSessions = DefaultSMTPMaxSessions
+	}
+
+	if c.SMTPIdleTimeout.IsEmpty() {
+		c.SMTPIdleTimeout.Duration = DefaultSMTPIdleTimeout
+	}
+
+	if c.BounceThreshold == 0 {
+		c.BounceThreshold = DefaultBounceThreshold
+	}
+
+	if c.BouncePOP3Port == 0 {
+		c.BouncePOP3Port = DefaultBouncePOP3Port
+	}
+
+	if c.BouncePOP3TLSMode == "" {
+		c.BouncePOP3TLSMode = DefaultBouncePOP3TLSMode
+	}
+
+	if c.BouncePOP3Interval.IsEmpty() {
+		c.BouncePOP3Interval.Duration = DefaultBouncePOP3Interval
+	}
+
+	if c.DeliveryMaxAttempts == 0 {
+		c.DeliveryMaxAttempts = DefaultDeliveryMaxAttempts
+	}
+
+	if c.DeliveryBackoff.IsEmpty() {
+		c.DeliveryBackoff.Duration = DefaultDeliveryBackoff
+	}
+
+	if c.DeliveryInterval.IsEmpty() {
+		c.DeliveryInterval.Duration = DefaultDeliveryInterval
+	}
+
+	if c.WebhookTimeout.IsEmpty() {
+		c.WebhookTimeout.Duration = DefaultWebhookTimeout
+	}
+
+	if c.TelegramAPIURL == "" {
+		c.TelegramAPIURL = DefaultTelegramAPIURL
+	}
+
+	if c.PushoverAPIURL == "" {
+		c.PushoverAPIURL = DefaultPushoverAPIURL
+	}
+
+	if c.PushoverRetry.IsEmpty() {
+		c.PushoverRetry.Duration = DefaultPushoverRetry
+	}
+
+	if c.PushoverExpire.IsEmpty() {
+		c.PushoverExpire.Duration = DefaultPushoverExpire
+	}
+
+	if c.VoiceTwilioAPIURL == "" {
+		c.VoiceTwilioAPIURL = DefaultVoiceTwilioAPIURL
+	}
+
+	if c.TracingSampleRatio == 0 {
+		c.TracingSampleRatio = DefaultTracingSampleRatio
+	}
+
+	if c.PublicURL == "" {
+		c.PublicURL = DefaultPublicURL
+	}
+	c.PublicURL = strings.TrimSuffix(c.PublicURL, "/")
+
+	if c.VerificationTTL.IsEmpty() {
+		c.VerificationTTL.Duration = DefaultVerificationTTL
+	}
+
 	if c.ReadTimeout.IsEmpty() {
 		c.ReadTimeout.Duration = DefaultReadTimeout
 	}
@@ -191,6 +575,10 @@ func (c *Config) Validate() error {
 		return errors.New("port must be > 0")
 	}
 
+	if c.MetricsPort < 0 || c.MetricsPort == c.Port {
+		return errors.New("metrics port must be >= 0 and differ from port")
+	}
+
 	if c.DBUser == "" {
 		return errors.New("db user must be defined")
 	}
@@ -211,6 +599,38 @@ func (c *Config) Validate() error {
 		return errors.New("db port must be > 0 ")
 	}
 
+	if !contains(db.SSLModes, c.DBSSLMode) {
+		return fmt.Errorf("db ssl mode must be one of %v", db.SSLModes)
+	}
+
+	if (c.DBSSLCert == "") != (c.DBSSLKey == "") {
+		return errors.New("db ssl cert and db ssl key must be defined together")
+	}
+
+	if c.DBStatementTimeout.Duration < 0 {
+		return errors.New("db statement timeout must be >= 0ms")
+	}
+
+	if c.DBMaxOpenConns <= 0 {
+		return errors.New("db max open conns must be > 0")
+	}
+
+	if c.DBMaxIdleConns <= 0 || c.DBMaxIdleConns > c.DBMaxOpenConns {
+		return errors.New("db max idle conns must be (0, db max open conns]")
+	}
+
+	if c.DBConnMaxLifetime.Duration < 0 {
+		return errors.New("db conn max lifetime must be >= 0ms")
+	}
+
+	if !contains(mail.Transports, c.MailTransport) {
+		return fmt.Errorf("mail transport must be one of %v", mail.Transports)
+	}
+
+	if (c.MailTransport == mail.TransportMaildir || c.MailTransport == mail.TransportMbox) && c.MailPath == "" {
+		return fmt.Errorf("mail path must be defined when mail transport is %s", c.MailTransport)
+	}
+
 	if c.SMTPHost == "" {
 		return errors.New("smtp host must be defined")
 	}
@@ -219,12 +639,185 @@ func (c *Config) Validate() error {
 		return errors.New("smtp port must be > 0")
 	}
 
-	if c.SMTPUser == "" {
-		return errors.New("smtp user must be defined")
+	if (c.SMTPUser == "" || c.SMTPPass == "") && c.SMTPAuth != mail.AuthNone && c.MailTransport == mail.TransportSMTP {
+		return errors.New("smtp user and smtp pass must be defined unless smtp auth is none or mail transport isn't smtp")
+	}
+
+	if err := validate.Email(c.SMTPFrom); err != nil {
+		return fmt.Errorf("smtp from, or smtp user when it isn't set, must be an email address: %w", err)
+	}
+
+	if c.SMTPReplyTo != "" {
+		if err := validate.Email(c.SMTPReplyTo); err != nil {
+			return fmt.Errorf("smtp reply to must be an email address: %w", err)
+		}
+	}
+
+	if c.SMTPEnvelopeFrom != "" {
+		if err := validate.Email(c.SMTPEnvelopeFrom); err != nil {
+			return fmt.Errorf("smtp envelope from must be an email address: %w", err)
+		}
+	}
+
+	if !contains(mail.TLSModes, c.SMTPTLSMode) {
+		return fmt.Errorf("smtp tls mode must be one of %v", mail.TLSModes)
+	}
+
+	if !contains(mail.AuthMechanisms, c.SMTPAuth) {
+		return fmt.Errorf("smtp auth must be one of %v", mail.AuthMechanisms)
+	}
+
+	if c.SMTPDialTimeout.Duration <= 0 {
+		return errors.New("smtp dial timeout must be > 0ms")
+	}
+
+	if c.SMTPCommandTimeout.Duration <= 0 {
+		return errors.New("smtp command timeout must be > 0ms")
+	}
+
+	if (c.DKIMDomain == "") != (c.DKIMSelector == "") || (c.DKIMDomain == "") != (c.DKIMKeyFile == "") {
+		return errors.New("dkim domain, dkim selector and dkim key file must be defined together")
+	}
+
+	for i, relay := range c.SMTPRelays {
+		if relay.Host == "" {
+			return fmt.Errorf("smtp relay %d host must be defined", i)
+		}
+
+		if relay.Port <= 0 {
+			return fmt.Errorf("smtp relay %d port must be > 0", i)
+		}
+
+		if (relay.User == "" || relay.Pass == "") && relay.Auth != mail.AuthNone {
+			return fmt.Errorf("smtp relay %d user and pass must be defined unless its auth is none", i)
+		}
+
+		if !contains(mail.TLSModes, relay.TLSMode) {
+			return fmt.Errorf("smtp relay %d tls mode must be one of %v", i, mail.TLSModes)
+		}
+
+		if !contains(mail.AuthMechanisms, relay.Auth) {
+			return fmt.Errorf("smtp relay %d auth must be one of %v", i, mail.AuthMechanisms)
+		}
+	}
+
+	if c.SMTPCooldown.Duration <= 0 {
+		return errors.New("smtp cooldown must be > 0ms")
+	}
+
+	if c.SMTPMaxSessions <= 0 {
+		return errors.New("smtp max sessions must be > 0")
+	}
+
+	if c.SMTPIdleTimeout.Duration <= 0 {
+		return errors.New("smtp idle timeout must be > 0ms")
 	}
 
-	if c.SMTPPass == "" {
-		return errors.New("smtp pass must be defined")
+	if c.BounceThreshold <= 0 {
+		return errors.New("bounce threshold must be > 0")
+	}
+
+	if c.BouncePOP3Host != "" {
+		if c.BouncePOP3Port <= 0 {
+			return errors.New("bounce pop3 port must be > 0")
+		}
+
+		if c.BouncePOP3User == "" || c.BouncePOP3Pass == "" {
+			return errors.New("bounce pop3 user and bounce pop3 pass must be defined")
+		}
+
+		if !contains(mail.TLSModes, c.BouncePOP3TLSMode) {
+			return fmt.Errorf("bounce pop3 tls mode must be one of %v", mail.TLSModes)
+		}
+
+		if c.BouncePOP3Interval.Duration <= 0 {
+			return errors.New("bounce pop3 interval must be > 0ms")
+		}
+	}
+
+	if c.DeliveryMaxAttempts <= 0 {
+		return errors.New("delivery max attempts must be > 0")
+	}
+
+	if c.DeliveryBackoff.Duration <= 0 {
+		return errors.New("delivery backoff must be > 0ms")
+	}
+
+	if c.DeliveryInterval.Duration <= 0 {
+		return errors.New("delivery interval must be > 0ms")
+	}
+
+	if c.WebhookTimeout.Duration <= 0 {
+		return errors.New("webhook timeout must be > 0ms")
+	}
+
+	if u, err := url.Parse(c.TelegramAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("telegram api url must be an absolute http or https url")
+	}
+
+	if c.TelegramWebhookSecret != "" {
+		if c.TelegramBotToken == "" {
+			return errors.New("telegram bot token must be defined when telegram webhook secret is")
+		}
+
+		if !telegramSecretPattern.MatchString(c.TelegramWebhookSecret) {
+			return errors.New("telegram webhook secret must be 1-256 letters, digits, underscores or hyphens")
+		}
+	}
+
+	if c.MatrixHomeserverURL != "" {
+		if u, err := url.Parse(c.MatrixHomeserverURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("matrix homeserver url must be an absolute http or https url")
+		}
+	} else if c.MatrixAccessToken != "" {
+		return errors.New("matrix homeserver url must be defined when matrix access token is")
+	}
+
+	if u, err := url.Parse(c.PushoverAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("pushover api url must be an absolute http or https url")
+	}
+
+	if c.PushoverRetry.Duration < channel.MinPushoverRetry {
+		return fmt.Errorf("pushover retry must be >= %s", channel.MinPushoverRetry)
+	}
+
+	if c.PushoverExpire.Duration <= 0 || c.PushoverExpire.Duration > channel.MaxPushoverExpire {
+		return fmt.Errorf("pushover expire must be (0ms, %s]", channel.MaxPushoverExpire)
+	}
+
+	switch c.VoiceProvider {
+	case "":
+		// Voice contact points aren't called without a provider.
+	case channel.VoiceProviderTwilio:
+		if u, err := url.Parse(c.VoiceTwilioAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("voice twilio api url must be an absolute http or https url")
+		}
+
+		if c.VoiceTwilioAccountSID == "" || c.VoiceTwilioAuthToken == "" || c.VoiceTwilioFrom == "" {
+			return errors.New("voice twilio account sid, voice twilio auth token and voice twilio from must be defined")
+		}
+	case channel.VoiceProviderBridge:
+		if u, err := url.Parse(c.VoiceBridgeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("voice bridge url must be an absolute http or https url")
+		}
+	default:
+		return fmt.Errorf("voice provider must be one of %v", channel.VoiceProviders)
+	}
+
+	if c.TracingSampleRatio <= 0 || c.TracingSampleRatio > 1 {
+		return errors.New("tracing sample ratio must be (0, 1]")
+	}
+
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("public url must be an absolute http or https url")
+	}
+
+	if len(c.SigningKey) < MinSigningKeyLength {
+		return fmt.Errorf("signing key must be at least %d characters", MinSigningKeyLength)
+	}
+
+	if c.VerificationTTL.Duration <= 0 {
+		return errors.New("verification ttl must be > 0ms")
 	}
 
 	if c.ReadTimeout.IsEmpty() {
@@ -241,3 +834,14 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// contains reports whether or not value is within values.
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,77 +4,361 @@ package contract
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/internal/platform/tracing"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// SeverityChannels maps the names of severities to the channels, being types of contact
+// point, that notifications of that severity are sent through, stored as JSON.
+type SeverityChannels map[string][]string
+
+// Value implements the driver.Valuer interface. The JSON is given as a string, as byte
+// slices are sent to postgres as bytea.
+func (sc SeverityChannels) Value() (driver.Value, error) {
+	if sc == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(sc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal severity channels: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (sc *SeverityChannels) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*sc = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, sc)
+	case string:
+		return json.Unmarshal([]byte(src), sc)
+	default:
+		return fmt.Errorf("unsupported severity channels type %T", src)
+	}
+}
+
+// ClockLayout is the layout of the times of day that the active hours of a contract start
+// and end at.
+const ClockLayout = "15:04"
+
+// ActiveHours are the hours of the day that a contract is notified during, in its time
+// zone. Contracts without active hours are notified at any time.
+type ActiveHours struct {
+	// ActiveFrom and ActiveUntil are the times of day, formatted as ClockLayout, that the
+	// active hours start and end at. Active hours that end before they start span
+	// midnight.
+	ActiveFrom  *string `db:"active_from"`
+	ActiveUntil *string `db:"active_until"`
+
+	// Timezone is the name of the IANA time zone that the active hours are in.
+	Timezone string `db:"timezone"`
+}
+
+// Active reports whether or not the given time is within the active hours, which include
+// their start but not their end. Active hours that can't be parsed are treated as always
+// active, and those whose time zone can't be loaded as being in UTC, so that a
+// notification is never lost to them.
+func (h ActiveHours) Active(t time.Time) bool {
+	if h.ActiveFrom == nil || h.ActiveUntil == nil {
+		return true
+	}
+
+	from, err := time.Parse(ClockLayout, *h.ActiveFrom)
+	if err != nil {
+		return true
+	}
+
+	until, err := time.Parse(ClockLayout, *h.ActiveUntil)
+	if err != nil {
+		return true
+	}
+
+	loc, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	start := from.Hour()*60 + from.Minute()
+	end := until.Hour()*60 + until.Minute()
+
+	if start < end {
+		return now >= start && now < end
+	}
+
+	return now >= start || now < end
+}
+
+// Admits reports whether or not a notification sent at the given time is sent during the
+// active hours. Escalated notifications, being critical or above, are admitted regardless
+// of the active hours so that they're never dropped outside of them.
+func (h ActiveHours) Admits(t time.Time, escalated bool) bool {
+	return escalated || h.Active(t)
+}
+
+// Routing is how the notifications of a node are routed to the contact points of an
+// entity subscribed to it.
+type Routing struct {
+	// Enabled is whether or not the contract is notified at all. Unlike deactivated
+	// contracts, which have been unsubscribed from, disabled ones may be enabled again.
+	Enabled bool `db:"enabled"`
+
+	// Channels limits the channels, being types of contact point, that are notified, every
+	// channel being notified when it's nil.
+	Channels pq.StringArray `db:"channels"`
+
+	// MinSeverity is the name of the least severe severity that is notified, every
+	// severity being notified when it's nil.
+	MinSeverity *string `db:"min_severity"`
+
+	// SeverityChannels limits the channels that the severities it lists are notified
+	// through, every channel being notified of severities that it doesn't list.
+	SeverityChannels SeverityChannels `db:"severity_channels"`
+
+	// ActiveHours limits the hours that notifications below critical are sent during.
+	ActiveHours
+}
+
 // Contract is a struct representing the structure of a row in the contract table
-// of the database.
+// of the database. Only active contracts are notified.
 type Contract struct {
-	ID            int       `db:"id"`
-	NodePublicKey string    `db:"node_public_key"`
-	EntityID      int       `db:"entity_id"`
-	Created       time.Time `db:"created"`
-	Modified      time.Time `db:"modified"`
+	ID                 int        `db:"id"`
+	NodePublicKey      string     `db:"node_public_key"`
+	EntityID           int        `db:"entity_id"`
+	Active             bool       `db:"active"`
+	Deactivated        *time.Time `db:"deactivated"`
+	DeactivationReason *string    `db:"deactivation_reason"`
+	Routing
+	Created  time.Time `db:"created"`
+	Modified time.Time `db:"modified"`
+}
+
+// CreateContract takes a node public key, an entity ID and how the node's notifications
+// are routed to the entity, and creates a row in the contract table.
+func CreateContract(ctx context.Context, dbc *sqlx.DB, nodePublicKey string, entityID int, routing Routing) (err error) {
+	ctx, span := tracing.Start(ctx, "contract.CreateContract")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `INSERT INTO contract (
+  node_public_key,
+  entity_id,
+  enabled,
+  channels,
+  min_severity,
+  severity_channels,
+  active_from,
+  active_until,
+  timezone
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err = stmt.ExecContext(ctx, nodePublicKey, entityID, routing.Enabled, routing.Channels, routing.MinSeverity,
+		routing.SeverityChannels, routing.ActiveFrom, routing.ActiveUntil, routing.Timezone); err != nil {
+		return fmt.Errorf("execute statement: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateRouting replaces how the notifications of the node of the contract with the given
+// ID are routed to its entity. If no such contract exists, sql.ErrNoRows is returned.
+func UpdateRouting(ctx context.Context, dbc *sqlx.DB, id int, routing Routing) (err error) {
+	ctx, span := tracing.Start(ctx, "contract.UpdateRouting")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `UPDATE contract
+SET
+  enabled = $2,
+  channels = $3,
+  min_severity = $4,
+  severity_channels = $5,
+  active_from = $6,
+  active_until = $7,
+  timezone = $8,
+  modified = NOW()
+WHERE id = $1;`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, id, routing.Enabled, routing.Channels, routing.MinSeverity,
+		routing.SeverityChannels, routing.ActiveFrom, routing.ActiveUntil, routing.Timezone)
+	if err != nil {
+		return fmt.Errorf("execute statement: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
-// CreateContract takes a node public key and an entity ID and creates a row in the contract
-// table.
-func CreateContract(ctx context.Context, dbc *sqlx.DB, nodePublicKey string, entityID int) error {
-	stmt, err := dbc.Preparex("INSERT INTO contract (node_public_key, entity_id) VALUES ($1, $2);")
+// DeactivateContract deactivates the contract with the given ID, recording the reason
+// why. Contracts that are already deactivated keep their original deactivation time and
+// reason. If no such contract exists, sql.ErrNoRows is returned.
+func DeactivateContract(ctx context.Context, dbc *sqlx.DB, id int, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "contract.DeactivateContract")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `UPDATE contract
+SET
+  active = false,
+  deactivated = COALESCE(deactivated, NOW()),
+  deactivation_reason = COALESCE(deactivation_reason, $2),
+  modified = NOW()
+WHERE id = $1;`)
 	if err != nil {
 		return fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	if _, err = stmt.ExecContext(ctx, nodePublicKey, entityID); err != nil {
+	res, err := stmt.ExecContext(ctx, id, reason)
+	if err != nil {
 		return fmt.Errorf("execute statement: %w", err)
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
 
-// ResolvedContract represents a row returned in the complex query used in ResolveContracts.
+// ResolvedContract represents a row returned in the complex query used in ResolveContracts,
+// being a single contact point of an entity subscribed to a node.
 type ResolvedContract struct {
-	SMS   *int    `db:"sms"`
-	Email *string `db:"email"`
+	ContractID     int    `db:"contract_id"`
+	EntityID       int    `db:"entity_id"`
+	EntityName     string `db:"entity_name"`
+	ContactPointID int    `db:"contact_point_id"`
+	Type           string `db:"type"`
+	Address        string `db:"address"`
+	Priority       int    `db:"priority"`
+}
+
+// resolvedRow is a row returned by the query used in ResolveContracts, along with what's
+// needed to route the notification by the active hours of its contract.
+type resolvedRow struct {
+	ResolvedContract
+	ActiveHours
+
+	// Escalated is whether or not the notification is critical or above, which is sent
+	// outside of active hours.
+	Escalated bool `db:"escalated"`
 }
 
-// ResolveContracts takes a node public key and resolves all of the notification contracts
-// that are paired with it. The returned result is each entity that is subscribed to said
-// node's Email and/or SMS number.
-func ResolveContracts(ctx context.Context, dbc *sqlx.DB, nodePublicKey string) ([]ResolvedContract, error) {
-	stmt, err := dbc.PreparexContext(ctx, `SELECT
-  sms,
-  email
+// ResolveContracts takes a node public key and resolves the contact points that a
+// notification of the given severity, sent at the given time, is routed to. The returned
+// result is every verified and unsuspended contact point of each entity that is actively
+// subscribed to said node through an enabled contract, whose routing includes the
+// channel of the contact point and the severity, ordered by entity and then by the
+// priority of the contact point.
+//
+// Voice contact points are an escalation, only routed notifications that are critical or
+// above unless the contract lists voice as a channel of the severity. Notifications that
+// are critical or above are sent regardless of the active hours of a contract.
+func ResolveContracts(ctx context.Context, dbc *sqlx.DB, nodePublicKey string, sev severity.Severity, now time.Time) (_ []ResolvedContract, err error) {
+	ctx, span := tracing.Start(ctx, "contract.ResolveContracts")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `WITH critical AS (
+  SELECT level FROM severity WHERE name = $5
+)
+SELECT
+  contract.id AS contract_id,
+  entity.id AS entity_id,
+  entity.name AS entity_name,
+  contact_point.id AS contact_point_id,
+  contact_point.type,
+  contact_point.address,
+  contact_point.priority,
+  to_char(contract.active_from, 'HH24:MI') AS active_from,
+  to_char(contract.active_until, 'HH24:MI') AS active_until,
+  contract.timezone,
+  COALESCE($2 >= critical.level, false) AS escalated
 FROM
   contract
   INNER JOIN node ON contract.node_public_key = node.public_key
   INNER JOIN entity ON contract.entity_id = entity.id
+  INNER JOIN contact_point ON contact_point.entity_id = entity.id
+  LEFT JOIN severity AS min_severity ON contract.min_severity = min_severity.name
+  LEFT JOIN critical ON true
 WHERE
-  node.public_key = $1;`)
+  node.public_key = $1
+  AND contract.active
+  AND contract.enabled
+  AND contact_point.verified_at IS NOT NULL
+  AND contact_point.suspended_at IS NULL
+  AND (contract.channels IS NULL OR contact_point.type = ANY(contract.channels))
+  AND (min_severity.level IS NULL OR min_severity.level <= $2)
+  AND (
+    contract.severity_channels IS NULL
+    OR NOT (contract.severity_channels ? $3)
+    OR ((contract.severity_channels -> $3) ? contact_point.type)
+  )
+  AND (
+    contact_point.type <> $4
+    OR $2 >= critical.level
+    OR COALESCE((contract.severity_channels -> $3) ? $4, false)
+  )
+ORDER BY
+  entity.id,
+  contact_point.priority,
+  contact_point.id;`)
 
 	if err != nil {
 		return nil, fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryxContext(ctx, nodePublicKey)
+	rows, err := stmt.QueryxContext(ctx, nodePublicKey, sev.Level, sev.Name, entity.ContactTypeVoice, severity.Critical)
 	if err != nil {
 		return nil, fmt.Errorf("query rows: %w", err)
 	}
 	defer rows.Close()
 
-	var contract ResolvedContract
 	var contracts []ResolvedContract
 
 	for rows.Next() {
-		if err := rows.StructScan(&contract); err != nil {
+		var row resolvedRow
+		if err := rows.StructScan(&row); err != nil {
 			return nil, fmt.Errorf("scan row: %w", err)
 		}
-		contracts = append(contracts, contract)
+
+		if row.Admits(now, row.Escalated) {
+			contracts = append(contracts, row.ResolvedContract)
+		}
 	}
 
 	return contracts, nil
@@ -0,0 +1,75 @@
+// Package contract_test tests the contract package.
+package contract_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/contract"
+)
+
+// clock returns a reference to the given time of day, as the active hours of a contract
+// are stored.
+func clock(s string) *string {
+	return &s
+}
+
+// TestActiveHoursAdmits tests that active hours admit notifications sent within them in
+// their time zone, including those spanning midnight, and that escalated notifications
+// are admitted regardless of them.
+func TestActiveHoursAdmits(t *testing.T) {
+	t.Parallel()
+
+	day := contract.ActiveHours{ActiveFrom: clock("09:00"), ActiveUntil: clock("17:00"), Timezone: "UTC"}
+	night := contract.ActiveHours{ActiveFrom: clock("22:00"), ActiveUntil: clock("06:00"), Timezone: "Europe/London"}
+
+	tests := map[string]struct {
+		hours     contract.ActiveHours
+		at        string
+		escalated bool
+		expected  bool
+	}{
+		"no active hours":       {contract.ActiveHours{Timezone: "UTC"}, "2026-07-01T03:00:00Z", false, true},
+		"only a start":          {contract.ActiveHours{ActiveFrom: clock("09:00"), Timezone: "UTC"}, "2026-07-01T03:00:00Z", false, true},
+		"before day":            {day, "2026-07-01T08:59:00Z", false, false},
+		"start of day":          {day, "2026-07-01T09:00:00Z", false, true},
+		"during day":            {day, "2026-07-01T12:30:00Z", false, true},
+		"end of day":            {day, "2026-07-01T17:00:00Z", false, false},
+		"escalated after day":   {day, "2026-07-01T23:00:00Z", true, true},
+		"before night":          {night, "2026-07-01T20:59:00Z", false, false},
+		"start of night":        {night, "2026-07-01T21:00:00Z", false, true},
+		"after midnight":        {night, "2026-07-02T04:59:00Z", false, true},
+		"end of night":          {night, "2026-07-02T05:00:00Z", false, false},
+		"winter night":          {night, "2026-01-01T05:59:00Z", false, true},
+		"escalated after night": {night, "2026-07-02T12:00:00Z", true, true},
+		"unknown timezone": {
+			contract.ActiveHours{ActiveFrom: clock("09:00"), ActiveUntil: clock("17:00"), Timezone: "Nowhere/Atlantis"},
+			"2026-07-01T08:30:00Z", false, false,
+		},
+		"unknown timezone in utc hours": {
+			contract.ActiveHours{ActiveFrom: clock("09:00"), ActiveUntil: clock("17:00"), Timezone: "Nowhere/Atlantis"},
+			"2026-07-01T09:30:00Z", false, true,
+		},
+		"unparsable hours": {
+			contract.ActiveHours{ActiveFrom: clock("9am"), ActiveUntil: clock("17:00"), Timezone: "UTC"},
+			"2026-07-01T03:00:00Z", false, true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			at, err := time.Parse(time.RFC3339, test.at)
+			if err != nil {
+				t.Fatalf("parse time: %v", err)
+			}
+
+			if e, a := test.expected, test.hours.Admits(at, test.escalated); e != a {
+				t.Errorf("expected %t, got %t", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+// Package delivery interfaces between the notification and delivery tables in the
+// database and the lorafication daemon, and dispatches the deliveries of notifications
+// through their channels, retrying those that fail.
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Statuses of a delivery.
+const (
+	// StatusPending deliveries are yet to be delivered, and are attempted once their next
+	// attempt is due.
+	StatusPending = "pending"
+
+	// StatusDelivered deliveries were delivered.
+	StatusDelivered = "delivered"
+
+	// StatusFailed deliveries failed permanently or ran out of attempts.
+	StatusFailed = "failed"
+)
+
+// Payload is the optional structured data sent along with a notification, stored as
+// JSON.
+type Payload map[string]interface{}
+
+// Value implements the driver.Valuer interface. The JSON is given as a string, as byte
+// slices are sent to postgres as bytea.
+func (p Payload) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (p *Payload) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, p)
+	case string:
+		return json.Unmarshal([]byte(src), p)
+	default:
+		return fmt.Errorf("unsupported payload type %T", src)
+	}
+}
+
+// Notification is a struct representing the structure of a row in the notification table
+// of the database, along with its deliveries. A notification is acknowledged by the
+// contact point that first follows the acknowledge link of its delivery.
+type Notification struct {
+	ID             int        `db:"id"`
+	NodePublicKey  string     `db:"node_public_key"`
+	Message        string     `db:"message"`
+	Severity       string     `db:"severity"`
+	Payload        Payload    `db:"payload"`
+	AcknowledgedAt *time.Time `db:"acknowledged_at"`
+	AcknowledgedBy *int       `db:"acknowledged_by"` // The ID of the contact point.
+	Deliveries     []Delivery `db:"-"`
+	Created        time.Time  `db:"created"`
+}
+
+// Acknowledged reports whether or not the notification has been acknowledged.
+func (n *Notification) Acknowledged() bool {
+	return n.AcknowledgedAt != nil
+}
+
+// Delivery is a struct representing the structure of a row in the delivery table of the
+// database, being the delivery of a notification to a single contact point through the
+// channel of its type.
+type Delivery struct {
+	ID             int        `db:"id"`
+	NotificationID int        `db:"notification_id"`
+	ContractID     int        `db:"contract_id"`
+	ContactPointID int        `db:"contact_point_id"`
+	Channel        string     `db:"channel"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	LastError      string     `db:"last_error"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+	Created        time.Time  `db:"created"`
+	Modified       time.Time  `db:"modified"`
+}
+
+// CreateNotification creates a row in the notification table for the given notification,
+// along with a pending row in the delivery table for each of its deliveries, all within a
+// single transaction. Only the contract, contact point and channel of each delivery are
+// used. The notification is returned as created.
+func CreateNotification(ctx context.Context, dbc *sqlx.DB, n Notification) (_ *Notification, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.CreateNotification")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := dbc.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer tx.Rollback()
+
+	var created Notification
+	if err := tx.GetContext(ctx, &created, `INSERT INTO notification (node_public_key, message, severity, payload)
+VALUES ($1, $2, $3, $4) RETURNING *;`, n.NodePublicKey, n.Message, n.Severity, n.Payload); err != nil {
+		return nil, fmt.Errorf("insert notification: %w", err)
+	}
+
+	stmt, err := tx.PreparexContext(ctx, `INSERT INTO delivery (notification_id, contract_id, contact_point_id, channel)
+VALUES ($1, $2, $3, $4) RETURNING *;`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range n.Deliveries {
+		if err := stmt.GetContext(ctx, &d, created.ID, d.ContractID, d.ContactPointID, d.Channel); err != nil {
+			return nil, fmt.Errorf("insert delivery: %w", err)
+		}
+
+		created.Deliveries = append(created.Deliveries, d)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetNotification takes the ID of a notification and finds the corresponding row in the
+// notification table, along with its deliveries. If no such notification exists,
+// sql.ErrNoRows is returned.
+func GetNotification(ctx context.Context, dbc *sqlx.DB, id int) (_ *Notification, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.GetNotification")
+	defer func() { tracing.End(span, err) }()
+
+	var n Notification
+	if err := dbc.GetContext(ctx, &n, "SELECT * FROM notification WHERE id=$1;", id); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	if err := dbc.SelectContext(ctx, &n.Deliveries, "SELECT * FROM delivery WHERE notification_id=$1 ORDER BY id;", id); err != nil {
+		return nil, fmt.Errorf("retrieve deliveries: %w", err)
+	}
+
+	return &n, nil
+}
+
+// AcknowledgeNotification acknowledges the notification delivered by the delivery with the
+// given ID on behalf of the delivery's contact point. Notifications that are already
+// acknowledged keep their original acknowledgement. The notification is returned as
+// updated, or sql.ErrNoRows if no such delivery exists.
+func AcknowledgeNotification(ctx context.Context, dbc *sqlx.DB, deliveryID int) (_ *Notification, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.AcknowledgeNotification")
+	defer func() { tracing.End(span, err) }()
+
+	var n Notification
+	if err := dbc.GetContext(ctx, &n, `UPDATE notification
+SET
+  acknowledged_by = CASE WHEN acknowledged_at IS NULL THEN delivery.contact_point_id ELSE acknowledged_by END,
+  acknowledged_at = COALESCE(acknowledged_at, NOW())
+FROM delivery
+WHERE delivery.id = $1 AND notification.id = delivery.notification_id
+RETURNING notification.*;`, deliveryID); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	return &n, nil
+}
+
+// AcknowledgeNotificationFrom acknowledges the notification delivered by the delivery with
+// the given ID as AcknowledgeNotification does, but only when the delivery was made to a
+// contact point of the given type whose address is one of the given addresses, regardless
+// of case. It's used for callbacks that identify a delivery without a signed token, so
+// that they can't acknowledge notifications delivered elsewhere. The notification is
+// returned as updated, or sql.ErrNoRows if no such delivery exists.
+func AcknowledgeNotificationFrom(ctx context.Context, dbc *sqlx.DB, deliveryID int, contactType string, addresses []string) (_ *Notification, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.AcknowledgeNotificationFrom")
+	defer func() { tracing.End(span, err) }()
+
+	lowered := make(pq.StringArray, 0, len(addresses))
+	for _, address := range addresses {
+		lowered = append(lowered, strings.ToLower(address))
+	}
+
+	var n Notification
+	if err := dbc.GetContext(ctx, &n, `UPDATE notification
+SET
+  acknowledged_by = CASE WHEN acknowledged_at IS NULL THEN delivery.contact_point_id ELSE acknowledged_by END,
+  acknowledged_at = COALESCE(acknowledged_at, NOW())
+FROM
+  delivery
+  INNER JOIN contact_point ON contact_point.id = delivery.contact_point_id
+WHERE
+  delivery.id = $1 AND notification.id = delivery.notification_id
+  AND contact_point.type = $2 AND lower(contact_point.address) = ANY($3)
+RETURNING notification.*;`, deliveryID, contactType, lowered); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	return &n, nil
+}
+
+// Claimed is a delivery claimed by ClaimDeliveries, along with the address and secret of
+// the contact point it's delivered to.
+type Claimed struct {
+	Delivery
+	Address string `db:"address"`
+	Secret  string `db:"secret"`
+}
+
+// ClaimDeliveries claims up to limit pending deliveries whose next attempt is due, oldest
+// first, counting an attempt of each and deferring their next attempt by lease. Deliveries
+// claimed concurrently, such as by another daemon, are skipped, and a delivery whose
+// outcome is never recorded, such as when the daemon stops mid-attempt, is attempted again
+// once its lease is up. Due deliveries whose contract has since been deactivated or
+// disabled, or whose contact point has since been suspended, are failed rather than
+// claimed, recording why.
+func ClaimDeliveries(ctx context.Context, dbc *sqlx.DB, limit int, lease time.Duration) (_ []Claimed, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.ClaimDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	var claimed []Claimed
+	if err := dbc.SelectContext(ctx, &claimed, `WITH due AS (
+  SELECT
+    delivery.id,
+    CASE
+      WHEN NOT contract.active THEN 'contract deactivated'
+      WHEN NOT contract.enabled THEN 'contract disabled'
+      WHEN contact_point.suspended_at IS NOT NULL THEN 'contact point suspended'
+    END AS cancel_reason
+  FROM
+    delivery
+    INNER JOIN contract ON contract.id = delivery.contract_id
+    INNER JOIN contact_point ON contact_point.id = delivery.contact_point_id
+  WHERE delivery.status = 'pending' AND delivery.next_attempt_at <= NOW()
+  ORDER BY delivery.next_attempt_at
+  LIMIT $1
+  FOR UPDATE OF delivery SKIP LOCKED
+), cancelled AS (
+  UPDATE delivery
+  SET status = 'failed', last_error = due.cancel_reason, modified = NOW()
+  FROM due
+  WHERE delivery.id = due.id AND due.cancel_reason IS NOT NULL
+)
+UPDATE delivery
+SET
+  attempts = attempts + 1,
+  next_attempt_at = NOW() + $2 * interval '1 second',
+  modified = NOW()
+FROM due, contact_point
+WHERE
+  delivery.id = due.id
+  AND due.cancel_reason IS NULL
+  AND contact_point.id = delivery.contact_point_id
+RETURNING delivery.*, contact_point.address, contact_point.secret;`, limit, lease.Seconds()); err != nil {
+		return nil, fmt.Errorf("claim deliveries: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// CountPending returns the number of deliveries that are pending, whether or not their
+// next attempt is due.
+func CountPending(ctx context.Context, dbc *sqlx.DB) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "delivery.CountPending")
+	defer func() { tracing.End(span, err) }()
+
+	var n int
+	if err := dbc.GetContext(ctx, &n, "SELECT count(*) FROM delivery WHERE status = 'pending';"); err != nil {
+		return 0, fmt.Errorf("count pending deliveries: %w", err)
+	}
+
+	return n, nil
+}
+
+// CompleteDelivery marks the delivery with the given ID as delivered. If no such delivery
+// exists, sql.ErrNoRows is returned.
+func CompleteDelivery(ctx context.Context, dbc *sqlx.DB, id int) (err error) {
+	ctx, span := tracing.Start(ctx, "delivery.CompleteDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	return updateDelivery(ctx, dbc, `UPDATE delivery
+SET status = 'delivered', delivered_at = NOW(), last_error = '', modified = NOW()
+WHERE id = $1;`, id)
+}
+
+// RetryDelivery records why an attempt of the delivery with the given ID failed, leaving
+// it pending until its next attempt after the given backoff. If no such delivery exists,
+// sql.ErrNoRows is returned.
+func RetryDelivery(ctx context.Context, dbc *sqlx.DB, id int, reason string, backoff time.Duration) (err error) {
+	ctx, span := tracing.Start(ctx, "delivery.RetryDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	return updateDelivery(ctx, dbc, `UPDATE delivery
+SET last_error = $2, next_attempt_at = NOW() + $3 * interval '1 second', modified = NOW()
+WHERE id = $1;`, id, reason, backoff.Seconds())
+}
+
+// FailDelivery records why the delivery with the given ID failed, marking it as failed so
+// that it's no longer attempted. If no such delivery exists, sql.ErrNoRows is returned.
+func FailDelivery(ctx context.Context, dbc *sqlx.DB, id int, reason string) (err error) {
+	ctx, span := tracing.Start(ctx, "delivery.FailDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	return updateDelivery(ctx, dbc, `UPDATE delivery
+SET status = 'failed', last_error = $2, modified = NOW()
+WHERE id = $1;`, id, reason)
+}
+
+// updateDelivery executes the given statement updating a single delivery, returning
+// sql.ErrNoRows when no delivery was updated.
+func updateDelivery(ctx context.Context, dbc *sqlx.DB, query string, args ...interface{}) error {
+	res, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("update delivery: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("update delivery: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
@@ -0,0 +1,362 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/cmd/loraficationd/node"
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/cmd/loraficationd/template"
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Defaults of the fields of the Config type.
+const (
+	// DefaultMaxAttempts is the default value of the MaxAttempts field on the Config type.
+	DefaultMaxAttempts = 5
+
+	// DefaultBackoff is the default value of the Backoff field on the Config type.
+	DefaultBackoff = 30 * time.Second
+
+	// DefaultInterval is the default value of the Interval field on the Config type.
+	DefaultInterval = 10 * time.Second
+)
+
+const (
+	// batchSize is the number of deliveries claimed at once.
+	batchSize = 100
+
+	// concurrency is the number of deliveries of a batch attempted at once.
+	concurrency = 10
+
+	// lease is how long a claimed delivery is kept from being claimed again, which must
+	// outlast an attempt of any channel.
+	lease = 5 * time.Minute
+
+	// maxBackoff is the longest that a delivery is backed off for between attempts.
+	maxBackoff = time.Hour
+)
+
+// Config represents how a Dispatcher retries deliveries.
+type Config struct {
+	// MaxAttempts is the number of times a delivery is attempted before it fails.
+	MaxAttempts int
+
+	// Backoff is how long a delivery is backed off for after its first failed attempt,
+	// doubling after each attempt that follows.
+	Backoff time.Duration
+
+	// Interval is how often deliveries that are due are looked for when the Dispatcher
+	// isn't woken by a new notification, such as those being retried.
+	Interval time.Duration
+}
+
+// Links builds the signed links placed within notifications.
+type Links struct {
+	// Unsubscribe returns the URL that unsubscribes an entity from a node given the ID of
+	// the contract between them.
+	Unsubscribe func(contractID int) string
+
+	// Acknowledge returns the URL that acknowledges a notification given the ID of the
+	// delivery that it was delivered through.
+	Acknowledge func(deliveryID int) string
+}
+
+// Dispatcher attempts the pending deliveries of notifications through the channel of
+// each, retrying those that fail until they run out of attempts.
+type Dispatcher struct {
+	dbc      *sqlx.DB
+	logger   *zap.Logger
+	metrics  *metrics.Metrics
+	channels map[string]channel.Channel
+	links    Links
+	cfg      Config
+	wake     chan struct{}
+}
+
+// NewDispatcher returns a reference to a Dispatcher that delivers notifications through
+// the given channels, keyed by the type of contact point that they deliver to, with links
+// built by the given Links. Fields of cfg left as zero take their defaults.
+func NewDispatcher(dbc *sqlx.DB, logger *zap.Logger, m *metrics.Metrics, channels map[string]channel.Channel,
+	links Links, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+
+	return &Dispatcher{
+		dbc:      dbc,
+		logger:   logger,
+		metrics:  m,
+		channels: channels,
+		links:    links,
+		cfg:      cfg,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Supports reports whether or not the Dispatcher has a channel for the given type of
+// contact point.
+func (d *Dispatcher) Supports(contactType string) bool {
+	_, ok := d.channels[contactType]
+	return ok
+}
+
+// Wake has the Dispatcher look for deliveries that are due straight away, rather than at
+// its next interval, such as once a notification has been created.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run attempts the deliveries that are due every interval, or whenever the Dispatcher is
+// woken, until ctx is done. Deliveries being attempted when ctx is done are attempted
+// again once their lease is up.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Batches are dispatched back to back until there are no more deliveries due.
+		for {
+			n, err := d.dispatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					d.logger.Error("dispatch deliveries", zap.Error(err))
+				}
+				break
+			}
+
+			if n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch claims a batch of deliveries that are due and attempts them, returning the
+// number of deliveries claimed.
+func (d *Dispatcher) dispatch(ctx context.Context) (int, error) {
+	claimed, err := ClaimDeliveries(ctx, d.dbc, batchSize, lease)
+	if err != nil {
+		return 0, fmt.Errorf("claim deliveries: %w", err)
+	}
+
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+
+	severities, err := severity.ListSeverities(ctx, d.dbc)
+	if err != nil {
+		return 0, fmt.Errorf("list severities: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	notifications := make(map[int]*channel.Notification)
+
+	for _, c := range claimed {
+		n, ok := notifications[c.NotificationID]
+		if !ok {
+			if n, err = d.prepare(ctx, c.NotificationID, severities); err != nil {
+				d.record(ctx, c, fmt.Errorf("prepare notification: %w", err))
+				continue
+			}
+			notifications[c.NotificationID] = n
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c Claimed, n channel.Notification) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			d.deliver(ctx, c, n)
+		}(c, *n)
+	}
+	wg.Wait()
+
+	return len(claimed), nil
+}
+
+// prepare returns the notification with the given ID as it's delivered, rendered from the
+// templates of its node and ranked by the level of its severity amongst the given ones.
+// Templates that fail to render fall back to the defaults.
+func (d *Dispatcher) prepare(ctx context.Context, id int, severities severity.Severities) (*channel.Notification, error) {
+	var n Notification
+	if err := d.dbc.GetContext(ctx, &n, "SELECT * FROM notification WHERE id=$1;", id); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	nd, err := node.GetNode(ctx, d.dbc, n.NodePublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("get node: %w", err)
+	}
+
+	set, err := template.ResolveSet(ctx, d.dbc, nd.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("resolve templates: %w", err)
+	}
+
+	data := template.Data{
+		Node: template.Node{
+			PublicKey:   nd.PublicKey,
+			Name:        nd.Name,
+			Description: nd.Description,
+		},
+		Message:   n.Message,
+		Severity:  n.Severity,
+		Timestamp: n.Created,
+		Payload:   n.Payload,
+	}
+
+	rendered, err := set.Render(data)
+	if err != nil {
+		// A template that fails to render, such as one referring to a payload field the
+		// node didn't send, shouldn't stop entities from being notified.
+		d.logger.Warn("unable to render templates, falling back to defaults",
+			zap.Int("notificationID", n.ID), zap.Error(err))
+		if rendered, err = template.DefaultSet.Render(data); err != nil {
+			return nil, fmt.Errorf("render default templates: %w", err)
+		}
+	}
+
+	return &channel.Notification{
+		ID: n.ID,
+		Node: channel.Node{
+			PublicKey: nd.PublicKey,
+			Name:      nd.Name,
+		},
+		Message:   n.Message,
+		Severity:  n.Severity,
+		Urgency:   urgency(severities, n.Severity),
+		Timestamp: n.Created,
+		Payload:   n.Payload,
+		Subject:   rendered.Subject,
+		Text:      rendered.Text,
+		HTML:      rendered.HTML,
+		SMS:       rendered.SMS,
+		Sender: mail.Sender{
+			Address: nd.SenderAddress,
+			Name:    nd.SenderName,
+			ReplyTo: nd.ReplyTo,
+		},
+	}, nil
+}
+
+// urgency returns how urgent a notification of the severity with the given name is, by
+// its level against those of the built-in warning and critical severities, so that custom
+// severities are prioritised by channels as they're routed by contracts.
+func urgency(severities severity.Severities, name string) channel.Urgency {
+	sev, ok := severities.Find(name)
+	if !ok {
+		return channel.UrgencyNormal
+	}
+
+	if critical, ok := severities.Find(severity.Critical); ok && sev.Level >= critical.Level {
+		return channel.UrgencyCritical
+	}
+
+	if warning, ok := severities.Find(severity.Warning); ok && sev.Level >= warning.Level {
+		return channel.UrgencyWarning
+	}
+
+	return channel.UrgencyNormal
+}
+
+// deliver attempts the claimed delivery of the given notification through its channel and
+// records the outcome.
+func (d *Dispatcher) deliver(ctx context.Context, c Claimed, n channel.Notification) {
+	n.DeliveryID = c.ID
+	n.UnsubscribeURL = d.links.Unsubscribe(c.ContractID)
+	n.AcknowledgeURL = d.links.Acknowledge(c.ID)
+
+	ctx, span := tracing.Start(ctx, "delivery.Deliver",
+		attribute.Int("delivery.id", c.ID), attribute.String("delivery.channel", c.Channel))
+
+	var err error
+	if ch, ok := d.channels[c.Channel]; ok {
+		st := time.Now()
+		err = ch.Deliver(ctx, channel.ContactPoint{Address: c.Address, Secret: c.Secret}, n)
+
+		if c.Channel == entity.ContactTypeEmail {
+			d.metrics.ObserveSMTPSend(time.Since(st), err)
+		}
+	} else {
+		err = channel.Permanent(fmt.Errorf("no channel delivers to %s contact points", c.Channel))
+	}
+	tracing.End(span, err)
+
+	d.metrics.ObserveDelivery(c.Channel, n.Severity, err)
+	d.record(ctx, c, err)
+}
+
+// record records the outcome of an attempt of the claimed delivery: delivering it when
+// the attempt succeeded, failing it when the attempt failed permanently or was its last,
+// and retrying it after backing off otherwise.
+func (d *Dispatcher) record(ctx context.Context, c Claimed, err error) {
+	logger := d.logger.With(
+		zap.Int("deliveryID", c.ID),
+		zap.Int("notificationID", c.NotificationID),
+		zap.Int("contactPointID", c.ContactPointID),
+		zap.String("channel", c.Channel),
+		zap.Int("attempt", c.Attempts))
+
+	var recErr error
+	switch {
+	case err == nil:
+		recErr = CompleteDelivery(ctx, d.dbc, c.ID)
+		logger.Info("notification delivered")
+	case channel.IsPermanent(err) || c.Attempts >= d.cfg.MaxAttempts:
+		recErr = FailDelivery(ctx, d.dbc, c.ID, err.Error())
+		logger.Warn("notification delivery failed", zap.Error(err))
+	default:
+		backoff := d.backoff(c.Attempts)
+		recErr = RetryDelivery(ctx, d.dbc, c.ID, err.Error(), backoff)
+		logger.Warn("notification delivery attempt failed, retrying", zap.Duration("backoff", backoff), zap.Error(err))
+	}
+
+	if recErr != nil {
+		logger.Error("record delivery attempt", zap.Error(recErr))
+	}
+}
+
+// backoff returns how long a delivery is backed off for after the given number of failed
+// attempts, doubling with each attempt up to maxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.cfg.Backoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
@@ -0,0 +1,288 @@
+package entity
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/jmoiron/sqlx"
+)
+
+// Types of contact points that an entity can be notified through.
+const (
+	ContactTypeEmail      = "email"
+	ContactTypeSMS        = "sms"
+	ContactTypeWebhook    = "webhook"
+	ContactTypeSlack      = "slack"
+	ContactTypeTeams      = "teams"
+	ContactTypeMattermost = "mattermost"
+	ContactTypeTelegram   = "telegram"
+	ContactTypeMatrix     = "matrix"
+	ContactTypeNtfy       = "ntfy"
+	ContactTypeGotify     = "gotify"
+	ContactTypePushover   = "pushover"
+	ContactTypeVoice      = "voice"
+)
+
+// ContactTypes contains every type of contact point.
+var ContactTypes = []string{
+	ContactTypeEmail,
+	ContactTypeSMS,
+	ContactTypeWebhook,
+	ContactTypeSlack,
+	ContactTypeTeams,
+	ContactTypeMattermost,
+	ContactTypeTelegram,
+	ContactTypeMatrix,
+	ContactTypeNtfy,
+	ContactTypeGotify,
+	ContactTypePushover,
+	ContactTypeVoice,
+}
+
+// ContactPoint is a struct representing the structure of a row in the contact_point
+// table of the database. The address is an email address for email contact points, an
+// E.164 phone number for sms and voice contact points and an http or https URL for webhook contact
+// points, including the incoming webhooks of slack, teams and mattermost. It's the ID of
+// a chat for telegram contact points and of a room for matrix ones, whose secret is the
+// optional access token of the user that messages are sent as. Push contact points are
+// addressed by the URL of an ntfy topic or Gotify server, or a Pushover user key, their
+// secret being their token. Contact points aren't notified until they've been verified,
+// nor once they've been suspended for bouncing.
+type ContactPoint struct {
+	ID           int        `db:"id"`
+	EntityID     int        `db:"entity_id"`
+	Type         string     `db:"type"`
+	Address      string     `db:"address"`
+	VerifiedAt   *time.Time `db:"verified_at"`
+	Priority     int        `db:"priority"`     // Lower priorities are notified first.
+	BounceCount  int        `db:"bounce_count"` // Hard bounces since last verified.
+	BouncedAt    *time.Time `db:"bounced_at"`
+	BounceReason string     `db:"bounce_reason"`
+	SuspendedAt  *time.Time `db:"suspended_at"`
+	Secret       string     `db:"secret"` // Signs the events posted to webhooks.
+	Created      time.Time  `db:"created"`
+	Modified     time.Time  `db:"modified"`
+
+	// The hash of the code that verifies a phone contact point, when one has been sent.
+	VerificationCode        string     `db:"verification_code"`
+	VerificationCodeExpires *time.Time `db:"verification_code_expires"`
+	VerificationAttempts    int        `db:"verification_attempts"`
+}
+
+// Verified reports whether or not the contact point has been verified.
+func (cp *ContactPoint) Verified() bool {
+	return cp.VerifiedAt != nil
+}
+
+// Suspended reports whether or not the contact point has been suspended for bouncing.
+func (cp *ContactPoint) Suspended() bool {
+	return cp.SuspendedAt != nil
+}
+
+// VerifyContactPoint marks the contact point with the given ID, belonging to the entity
+// with the given ID, as verified. Contact points that are already verified keep their
+// original verification time. Verifying a suspended contact point lifts its suspension and
+// resets its bounce count. If no such contact point exists, sql.ErrNoRows is returned.
+func VerifyContactPoint(ctx context.Context, dbc *sqlx.DB, entityID, contactPointID int) (err error) {
+	ctx, span := tracing.Start(ctx, "entity.VerifyContactPoint")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `UPDATE contact_point
+SET verified_at = COALESCE(verified_at, NOW()), bounce_count = 0, suspended_at = NULL, modified = NOW()
+WHERE id = $1 AND entity_id = $2;`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, contactPointID, entityID)
+	if err != nil {
+		return fmt.Errorf("execute statement: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ErrInvalidCode is returned when a contact point is verified with a code that doesn't
+// match the one sent to it, or once that code has expired or been guessed too many times.
+var ErrInvalidCode = errors.New("invalid or expired verification code")
+
+// SetVerificationCode stores the hash of the code sent to the contact point with the given
+// ID, belonging to the entity with the given ID, which expires after the given TTL. Any code
+// sent to it before is replaced and the number of attempts made to guess it is reset. If
+// no such contact point exists, sql.ErrNoRows is returned.
+func SetVerificationCode(ctx context.Context, dbc *sqlx.DB, entityID, contactPointID int, code string, ttl time.Duration) (err error) {
+	ctx, span := tracing.Start(ctx, "entity.SetVerificationCode")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `UPDATE contact_point
+SET verification_code = $3, verification_code_expires = NOW() + $4 * interval '1 second', verification_attempts = 0, modified = NOW()
+WHERE id = $1 AND entity_id = $2;`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, contactPointID, entityID, hashCode(code), ttl.Seconds())
+	if err != nil {
+		return fmt.Errorf("execute statement: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// VerifyContactPointCode marks the contact point with the given ID, belonging to the entity
+// with the given ID, as verified if the given code matches the one sent to it, as
+// VerifyContactPoint does. Every attempt counts towards the given maximum, after which
+// ErrInvalidCode is returned even for the right code, so that codes can't be guessed. The
+// code can't be used again once the contact point is verified. If no such contact point
+// exists, sql.ErrNoRows is returned.
+func VerifyContactPointCode(ctx context.Context, dbc *sqlx.DB, entityID, contactPointID int, code string, maxAttempts int) (err error) {
+	ctx, span := tracing.Start(ctx, "entity.VerifyContactPointCode")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := dbc.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer tx.Rollback()
+
+	// The row is locked so that concurrent attempts are counted one after the other.
+	var (
+		hash     string
+		attempts int
+		live     bool
+	)
+	if err := tx.QueryRowxContext(ctx, `SELECT verification_code, verification_attempts, COALESCE(verification_code_expires > NOW(), false)
+FROM contact_point WHERE id = $1 AND entity_id = $2 FOR UPDATE;`, contactPointID, entityID).Scan(&hash, &attempts, &live); err != nil {
+		return fmt.Errorf("select contact point: %w", err)
+	}
+
+	if hash == "" || !live || attempts >= maxAttempts {
+		return ErrInvalidCode
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(hashCode(code))) != 1 {
+		if _, err := tx.ExecContext(ctx, `UPDATE contact_point
+SET verification_attempts = verification_attempts + 1, modified = NOW()
+WHERE id = $1;`, contactPointID); err != nil {
+			return fmt.Errorf("count attempt: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+
+		return ErrInvalidCode
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE contact_point
+SET verified_at = COALESCE(verified_at, NOW()), bounce_count = 0, suspended_at = NULL,
+  verification_code = '', verification_code_expires = NULL, verification_attempts = 0, modified = NOW()
+WHERE id = $1;`, contactPointID); err != nil {
+		return fmt.Errorf("verify contact point: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// hashCode returns the hex encoded SHA-256 hash of a verification code, as it's stored.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Bounced is a contact point as updated by a bounce recorded against it.
+type Bounced struct {
+	ContactPoint
+	NewlySuspended bool `db:"newly_suspended"` // Whether the bounce is what suspended it.
+}
+
+// RecordBounces records each of the given bounces, of one of the mail.Bounce kinds, for
+// every email contact point with the bounced address, regardless of case, all within a
+// single transaction so that a report is never partly recorded. Hard bounces count
+// towards the given threshold, suspending the contact point once it's reached, and
+// complaints suspend the contact point straight away. The contact points are returned as
+// updated for each bounce, none being returned when no contact point has the address.
+func RecordBounces(ctx context.Context, dbc *sqlx.DB, bounces []mail.Bounce, threshold int) (_ [][]Bounced, err error) {
+	ctx, span := tracing.Start(ctx, "entity.RecordBounces")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := dbc.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, `UPDATE contact_point
+SET
+  bounce_count = bounce_count + $2,
+  bounced_at = NOW(),
+  bounce_reason = $3,
+  suspended_at = COALESCE(suspended_at, CASE WHEN $4 OR bounce_count + $2 >= $5 THEN NOW() END),
+  modified = NOW()
+FROM (
+  SELECT id, suspended_at IS NULL AS active FROM contact_point
+  WHERE type = 'email' AND lower(address) = lower($1)
+  FOR UPDATE
+) AS previous
+WHERE contact_point.id = previous.id
+RETURNING contact_point.*, previous.active AND contact_point.suspended_at IS NOT NULL AS newly_suspended;`)
+	if err != nil {
+		return nil, fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	updated := make([][]Bounced, 0, len(bounces))
+	for _, b := range bounces {
+		var increment int
+		if b.Kind == mail.BounceHard {
+			increment = 1
+		}
+
+		var contactPoints []Bounced
+		if err := stmt.SelectContext(ctx, &contactPoints, b.Recipient, increment, b.Reason, b.Kind == mail.BounceComplaint, threshold); err != nil {
+			return nil, fmt.Errorf("execute statement: %w", err)
+		}
+
+		updated = append(updated, contactPoints)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return updated, nil
+}
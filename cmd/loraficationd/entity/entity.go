@@ -1,5 +1,5 @@
-// Package entity interfaces between the entity table in the database and the
-// lorafication daemon.
+// Package entity interfaces between the entity and contact_point tables in the database
+// and the lorafication daemon.
 package entity
 
 import (
@@ -7,38 +7,82 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/22arw/lorafication/internal/platform/tracing"
 	"github.com/jmoiron/sqlx"
 )
 
 // Entity is a struct representing the structure of a row in the entity table
-// of the database.
+// of the database, along with the contact points that belong to it.
 type Entity struct {
-	ID       int       `db:"id"`
-	Name     string    `db:"name"`
-	Email    *string   `db:"email"`
-	SMS      *int      `db:"sms"`
-	Created  time.Time `db:"created"`
-	Modified time.Time `db:"modified"`
+	ID            int            `db:"id"`
+	Name          string         `db:"name"`
+	ContactPoints []ContactPoint `db:"-"`
+	Created       time.Time      `db:"created"`
+	Modified      time.Time      `db:"modified"`
 }
 
-// CreateEntity takes a name, email, and sms where email and sms are both optional
-// (but at least one needs provided due to a database constraint) and creates a row
-// in the entity table in the database.
-func CreateEntity(ctx context.Context, dbc *sqlx.DB, name string, email *string, sms *int) (*Entity, error) {
-	stmt, err := dbc.Preparex("INSERT INTO entity (\"name\", email, sms) VALUES ($1, $2, $3);")
+// CreateEntity takes a name and the contact points the entity can be notified through
+// and creates a row in the entity table along with a row in the contact_point table
+// for each contact point, all within a single transaction. Contact points are only
+// created as verified if their VerifiedAt field is set.
+func CreateEntity(ctx context.Context, dbc *sqlx.DB, name string, contactPoints []ContactPoint) (_ *Entity, err error) {
+	ctx, span := tracing.Start(ctx, "entity.CreateEntity")
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := dbc.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	// Rolling back a committed transaction is a no-op.
+	defer tx.Rollback()
+
+	e := Entity{
+		Name: name,
+	}
+
+	if err := tx.QueryRowxContext(ctx, "INSERT INTO entity (\"name\") VALUES ($1) RETURNING id;", name).Scan(&e.ID); err != nil {
+		return nil, fmt.Errorf("insert entity: %w", err)
+	}
+
+	stmt, err := tx.PreparexContext(ctx, `INSERT INTO contact_point (entity_id, type, address, verified_at, priority, secret)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`)
 	if err != nil {
 		return nil, fmt.Errorf("prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	if _, err = stmt.ExecContext(ctx, name, email, sms); err != nil {
-		return nil, fmt.Errorf("execute statement: %w", err)
+	for _, cp := range contactPoints {
+		cp.EntityID = e.ID
+
+		if err := stmt.QueryRowxContext(ctx, cp.EntityID, cp.Type, cp.Address, cp.VerifiedAt, cp.Priority, cp.Secret).Scan(&cp.ID); err != nil {
+			return nil, fmt.Errorf("insert contact point: %w", err)
+		}
+
+		e.ContactPoints = append(e.ContactPoints, cp)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return &e, nil
+}
+
+// GetEntity takes the ID of an entity and finds the corresponding row in the entity table,
+// along with its contact points ordered by priority. If no such entity exists,
+// sql.ErrNoRows is returned.
+func GetEntity(ctx context.Context, dbc *sqlx.DB, id int) (_ *Entity, err error) {
+	ctx, span := tracing.Start(ctx, "entity.GetEntity")
+	defer func() { tracing.End(span, err) }()
+
+	var e Entity
+	if err := dbc.GetContext(ctx, &e, "SELECT * FROM entity WHERE id=$1;", id); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	if err := dbc.SelectContext(ctx, &e.ContactPoints, "SELECT * FROM contact_point WHERE entity_id=$1 ORDER BY priority, id;", id); err != nil {
+		return nil, fmt.Errorf("retrieve contact points: %w", err)
 	}
 
-	// None of the other fields should matter to the client.
-	return &Entity{
-		Name:  name,
-		Email: email,
-		SMS:   sms,
-	}, nil
+	return &e, nil
 }
@@ -10,10 +10,18 @@ import (
 	"os/signal"
 	"syscall"
 
+	// The time zone database is embedded, as contracts are notified during active hours in
+	// their own time zone and the runtime image doesn't include one.
+	_ "time/tzdata"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/bounce"
 	"github.com/22arw/lorafication/cmd/loraficationd/config"
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
 	"github.com/22arw/lorafication/cmd/loraficationd/server"
 	"github.com/22arw/lorafication/internal/mail"
 	"github.com/22arw/lorafication/internal/platform/db"
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/22arw/lorafication/internal/platform/tracing"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -89,26 +97,122 @@ func main() {
 		// Log the parsed configuration values.
 		logger.Info("values of configuration",
 			zap.Int("port", cfg.Port),
+			zap.Int("metricsPort", cfg.MetricsPort),
 			zap.Int("logLevel", cfg.LogLevel),
 			zap.String("dbUser", cfg.DBUser),
 			zap.String("dbName", cfg.DBName),
 			zap.String("dbHost", cfg.DBHost),
 			zap.Int("dbPort", cfg.DBPort),
+			zap.Bool("dbURLSet", cfg.DBURL != ""),
+			zap.String("dbSSLMode", cfg.DBSSLMode),
+			zap.String("dbSSLRootCert", cfg.DBSSLRootCert),
+			zap.String("dbSSLCert", cfg.DBSSLCert),
+			zap.String("dbSSLKey", cfg.DBSSLKey),
+			zap.String("dbApplicationName", cfg.DBApplicationName),
+			zap.Duration("dbStatementTimeout", cfg.DBStatementTimeout.Duration),
+			zap.Int("dbMaxOpenConns", cfg.DBMaxOpenConns),
+			zap.Int("dbMaxIdleConns", cfg.DBMaxIdleConns),
+			zap.Duration("dbConnMaxLifetime", cfg.DBConnMaxLifetime.Duration),
+			zap.String("mailTransport", cfg.MailTransport),
+			zap.String("mailPath", cfg.MailPath),
 			zap.String("smtpHost", cfg.SMTPHost),
 			zap.Int("smtpPort", cfg.SMTPPort),
 			zap.String("smtpUser", cfg.SMTPUser),
+			zap.String("smtpFrom", cfg.SMTPFrom),
+			zap.String("smtpFromName", cfg.SMTPFromName),
+			zap.String("smtpReplyTo", cfg.SMTPReplyTo),
+			zap.String("smtpEnvelopeFrom", cfg.SMTPEnvelopeFrom),
+			zap.String("smtpTLSMode", cfg.SMTPTLSMode),
+			zap.String("smtpCAFile", cfg.SMTPCAFile),
+			zap.Bool("smtpInsecureSkipVerify", cfg.SMTPInsecureSkipVerify),
+			zap.String("smtpAuth", cfg.SMTPAuth),
+			zap.String("smtpHELOName", cfg.SMTPHELOName),
+			zap.Duration("smtpDialTimeout", cfg.SMTPDialTimeout.Duration),
+			zap.Duration("smtpCommandTimeout", cfg.SMTPCommandTimeout.Duration),
+			zap.String("dkimDomain", cfg.DKIMDomain),
+			zap.String("dkimSelector", cfg.DKIMSelector),
+			zap.String("dkimKeyFile", cfg.DKIMKeyFile),
+			zap.Int("smtpPriority", cfg.SMTPPriority),
+			zap.Int("smtpRelays", len(cfg.SMTPRelays)),
+			zap.Duration("smtpCooldown", cfg.SMTPCooldown.Duration),
+			zap.Int("smtpMaxSessions", cfg.SMTPMaxSessions),
+			zap.Duration("smtpIdleTimeout", cfg.SMTPIdleTimeout.Duration),
+			zap.Int("bounceThreshold", cfg.BounceThreshold),
+			zap.String("bouncePOP3Host", cfg.BouncePOP3Host),
+			zap.Int("bouncePOP3Port", cfg.BouncePOP3Port),
+			zap.String("bouncePOP3User", cfg.BouncePOP3User),
+			zap.String("bouncePOP3TLSMode", cfg.BouncePOP3TLSMode),
+			zap.String("bouncePOP3CAFile", cfg.BouncePOP3CAFile),
+			zap.Duration("bouncePOP3Interval", cfg.BouncePOP3Interval.Duration),
+			zap.Int("deliveryMaxAttempts", cfg.DeliveryMaxAttempts),
+			zap.Duration("deliveryBackoff", cfg.DeliveryBackoff.Duration),
+			zap.Duration("deliveryInterval", cfg.DeliveryInterval.Duration),
+			zap.Duration("webhookTimeout", cfg.WebhookTimeout.Duration),
+			zap.String("telegramAPIURL", cfg.TelegramAPIURL),
+			zap.Bool("telegramBotTokenSet", cfg.TelegramBotToken != ""),
+			zap.Bool("telegramWebhookSecretSet", cfg.TelegramWebhookSecret != ""),
+			zap.String("matrixHomeserverURL", cfg.MatrixHomeserverURL),
+			zap.Bool("matrixAccessTokenSet", cfg.MatrixAccessToken != ""),
+			zap.String("pushoverAPIURL", cfg.PushoverAPIURL),
+			zap.Bool("pushoverAppTokenSet", cfg.PushoverAppToken != ""),
+			zap.Duration("pushoverRetry", cfg.PushoverRetry.Duration),
+			zap.Duration("pushoverExpire", cfg.PushoverExpire.Duration),
+			zap.String("voiceProvider", cfg.VoiceProvider),
+			zap.String("voiceBridgeURL", cfg.VoiceBridgeURL),
+			zap.String("voiceTwilioAPIURL", cfg.VoiceTwilioAPIURL),
+			zap.String("voiceTwilioAccountSID", cfg.VoiceTwilioAccountSID),
+			zap.String("voiceTwilioFrom", cfg.VoiceTwilioFrom),
+			zap.String("tracingEndpoint", cfg.TracingEndpoint),
+			zap.Bool("tracingInsecure", cfg.TracingInsecure),
+			zap.Float64("tracingSampleRatio", cfg.TracingSampleRatio),
+			zap.String("publicURL", cfg.PublicURL),
+			zap.Bool("adminTokenSet", cfg.AdminToken != ""),
+			zap.Duration("verificationTTL", cfg.VerificationTTL.Duration),
 			zap.Duration("readTimeout", cfg.ReadTimeout.Duration),
 			zap.Duration("writeTimeout", cfg.WriteTimeout.Duration),
 			zap.Duration("shutdownTimeout", cfg.ShutdownTimeout.Duration))
 	}
 
+	// Configure tracing, which exports spans over OTLP if an endpoint is configured.
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+		ServiceName: "loraficationd",
+	})
+	if err != nil {
+		logger.Error("initialize tracing", zap.Error(err))
+		exitCode = 1
+		return
+	}
+
+	// Defer the flushing of any unexported spans until after func main returns.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Warn("error shutting down tracing", zap.Error(err))
+		}
+	}()
+
 	// Construct database configuration struct to pass to the connection method.
 	dbCfg := db.Config{
-		User: cfg.DBUser,
-		Pass: cfg.DBPass,
-		Name: cfg.DBName,
-		Host: cfg.DBHost,
-		Port: cfg.DBPort,
+		URL:              cfg.DBURL,
+		User:             cfg.DBUser,
+		Pass:             cfg.DBPass,
+		Name:             cfg.DBName,
+		Host:             cfg.DBHost,
+		Port:             cfg.DBPort,
+		SSLMode:          cfg.DBSSLMode,
+		SSLRootCert:      cfg.DBSSLRootCert,
+		SSLCert:          cfg.DBSSLCert,
+		SSLKey:           cfg.DBSSLKey,
+		ApplicationName:  cfg.DBApplicationName,
+		StatementTimeout: cfg.DBStatementTimeout.Duration,
+		MaxOpenConns:     cfg.DBMaxOpenConns,
+		MaxIdleConns:     cfg.DBMaxIdleConns,
+		ConnMaxLifetime:  cfg.DBConnMaxLifetime.Duration,
 	}
 
 	// Connect to the database.
@@ -126,24 +230,164 @@ func main() {
 		}
 	}()
 
-	// Configure the mailer used to send emails over SMTP.
-	mailer := mail.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass)
+	// Configure the prometheus metrics of the daemon, including the statistics of the
+	// database connection pool and the depth of the delivery outbox.
+	m := metrics.New()
+	if err := m.RegisterDB(dbc.DB, cfg.DBName); err != nil {
+		logger.Error("register database metrics", zap.Error(err))
+		exitCode = 1
+		return
+	}
+
+	if err := m.RegisterPendingDeliveries(func(ctx context.Context) (int, error) {
+		return delivery.CountPending(ctx, dbc)
+	}); err != nil {
+		logger.Error("register delivery metrics", zap.Error(err))
+		exitCode = 1
+		return
+	}
+
+	// Configure the mailer used to send emails over SMTP, through the configured relay and
+	// any others that it fails over to.
+	relays := []mail.RelayConfig{{
+		Host:               cfg.SMTPHost,
+		Port:               cfg.SMTPPort,
+		User:               cfg.SMTPUser,
+		Pass:               cfg.SMTPPass,
+		Priority:           cfg.SMTPPriority,
+		TLSMode:            cfg.SMTPTLSMode,
+		CAFile:             cfg.SMTPCAFile,
+		InsecureSkipVerify: cfg.SMTPInsecureSkipVerify,
+		Auth:               cfg.SMTPAuth,
+		HELOName:           cfg.SMTPHELOName,
+	}}
+	for _, relay := range cfg.SMTPRelays {
+		relays = append(relays, mail.RelayConfig{
+			Host:               relay.Host,
+			Port:               relay.Port,
+			User:               relay.User,
+			Pass:               relay.Pass,
+			Priority:           relay.Priority,
+			TLSMode:            relay.TLSMode,
+			CAFile:             relay.CAFile,
+			InsecureSkipVerify: relay.InsecureSkipVerify,
+			Auth:               relay.Auth,
+			HELOName:           relay.HELOName,
+		})
+	}
+
+	for i := range relays {
+		relays[i].DialTimeout = cfg.SMTPDialTimeout.Duration
+		relays[i].CommandTimeout = cfg.SMTPCommandTimeout.Duration
+		relays[i].MaxSessions = cfg.SMTPMaxSessions
+		relays[i].IdleTimeout = cfg.SMTPIdleTimeout.Duration
+	}
+
+	var dkim *mail.DKIMSigner
+	if cfg.DKIMKeyFile != "" {
+		key, err := mail.LoadDKIMKey(cfg.DKIMKeyFile)
+		if err != nil {
+			logger.Error("load dkim key", zap.Error(err))
+			exitCode = 1
+			return
+		}
+
+		if dkim, err = mail.NewDKIMSigner(cfg.DKIMDomain, cfg.DKIMSelector, key); err != nil {
+			logger.Error("configure dkim signer", zap.Error(err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Email is sent through the SMTP relays unless another transport is configured, such
+	// as when running the daemon locally.
+	var transport mail.Transport
+	switch cfg.MailTransport {
+	case mail.TransportMaildir:
+		transport, err = mail.NewMaildirTransport(cfg.MailPath)
+	case mail.TransportMbox:
+		transport, err = mail.NewMboxTransport(cfg.MailPath)
+	case mail.TransportMemory:
+		transport = mail.NewMemoryTransport(0)
+	}
+	if err != nil {
+		logger.Error("configure mail transport", zap.Error(err))
+		exitCode = 1
+		return
+	}
+
+	mailer, err := mail.NewMailer(mail.Config{
+		Relays:    relays,
+		Transport: transport,
+		Sender: mail.Sender{
+			Address:  cfg.SMTPFrom,
+			Name:     cfg.SMTPFromName,
+			ReplyTo:  cfg.SMTPReplyTo,
+			Envelope: cfg.SMTPEnvelopeFrom,
+		},
+		DKIM:     dkim,
+		Cooldown: cfg.SMTPCooldown.Duration,
+	}, logger)
+	if err != nil {
+		logger.Error("configure mailer", zap.Error(err))
+		exitCode = 1
+		return
+	}
+	defer mailer.Close()
+
+	// Configure the mailbox that bounce reports are retrieved from, if there is one.
+	var mailbox *mail.Mailbox
+	if cfg.BouncePOP3Host != "" {
+		if mailbox, err = mail.NewMailbox(mail.MailboxConfig{
+			Host:    cfg.BouncePOP3Host,
+			Port:    cfg.BouncePOP3Port,
+			User:    cfg.BouncePOP3User,
+			Pass:    cfg.BouncePOP3Pass,
+			TLSMode: cfg.BouncePOP3TLSMode,
+			CAFile:  cfg.BouncePOP3CAFile,
+			Timeout: cfg.SMTPCommandTimeout.Duration,
+		}); err != nil {
+			logger.Error("configure bounce mailbox", zap.Error(err))
+			exitCode = 1
+			return
+		}
+	}
+
+	// Bounces are recorded by the same processor whether they're posted to the API or
+	// polled from the bounce mailbox.
+	bounces := bounce.NewProcessor(dbc, logger, m, cfg.BounceThreshold)
 
 	// Configure the HTTP server that this daemon will expose.
+	srv := server.NewServer(&cfg, logger, dbc, mailer, m, bounces)
 	api := http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Port),
-		Handler:      server.NewServer(&cfg, logger, dbc, mailer),
+		Handler:      srv,
 		ReadTimeout:  cfg.ReadTimeout.Duration,
 		WriteTimeout: cfg.WriteTimeout.Duration,
 	}
 
+	// Configure the admin HTTP server that exposes metrics, if they are meant to be kept
+	// off of the API's port.
+	var admin *http.Server
+	if cfg.MetricsPort != 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+
+		admin = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.MetricsPort),
+			Handler:      mux,
+			ReadTimeout:  cfg.ReadTimeout.Duration,
+			WriteTimeout: cfg.WriteTimeout.Duration,
+		}
+	}
+
 	// Create a channel for interrupt and termination signals to be caught on
 	// to possibly facilitate graceful shutdowns.
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
 	// Create a channel to catch API-related non-recoverable errors.
-	apiErr := make(chan error, 1)
+	apiErr := make(chan error, 2)
 
 	// Start the HTTP server.
 	go func() {
@@ -151,6 +395,34 @@ func main() {
 		apiErr <- api.ListenAndServe()
 	}()
 
+	// Start the admin HTTP server.
+	if admin != nil {
+		go func() {
+			logger.Info("admin server started", zap.Int("port", cfg.MetricsPort))
+			apiErr <- admin.ListenAndServe()
+		}()
+	}
+
+	// Start delivering notifications until the daemon shuts down.
+	deliveryCtx, stopDeliveries := context.WithCancel(context.Background())
+	defer stopDeliveries()
+
+	go func() {
+		logger.Info("notification delivery started")
+		srv.RunDeliveries(deliveryCtx)
+	}()
+
+	// Start polling the bounce mailbox until the daemon shuts down.
+	if mailbox != nil {
+		pollCtx, stopPolling := context.WithCancel(context.Background())
+		defer stopPolling()
+
+		go func() {
+			logger.Info("bounce mailbox polling started", zap.String("host", cfg.BouncePOP3Host))
+			bounces.Poll(pollCtx, mailbox, cfg.BouncePOP3Interval.Duration)
+		}()
+	}
+
 	// Block until either a shutdown signal or an API-related non-recoverable error
 	// is encountered.
 	select {
@@ -166,6 +438,14 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
 	defer cancel()
 
+	// Attempt to gracefully shutdown the admin server, metrics being scraped at the time
+	// of shutdown aren't worth forcefully waiting on.
+	if admin != nil {
+		if err := admin.Shutdown(ctx); err != nil {
+			logger.Warn("admin server graceful shutdown error", zap.Error(err))
+		}
+	}
+
 	// Attempt to gracefully shutdown.
 	if err := api.Shutdown(ctx); err != nil {
 		logger.Error("graceful shutdown error", zap.Error(err))
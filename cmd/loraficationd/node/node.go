@@ -4,9 +4,11 @@ package node
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
+	"github.com/22arw/lorafication/internal/platform/tracing"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,18 +17,28 @@ import (
 // Node is a struct representing the structure of a row in the node table
 // of the database.
 type Node struct {
-	PublicKey   string    `db:"public_key"` // Primary key (it's a UUID).
-	Secret      string    `db:"secret"`
-	Name        string    `db:"name"`
-	Description string    `db:"description"`
-	Created     time.Time `db:"created"`
-	Modified    time.Time `db:"modified"`
+	PublicKey   string `db:"public_key"` // Primary key (it's a UUID).
+	Secret      string `db:"secret"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+
+	// SenderAddress, SenderName and ReplyTo override those of the daemon's sender for the
+	// node's notifications when they're not empty.
+	SenderAddress string `db:"sender_address"`
+	SenderName    string `db:"sender_name"`
+	ReplyTo       string `db:"reply_to"`
+
+	Created  time.Time `db:"created"`
+	Modified time.Time `db:"modified"`
 }
 
 // AuthenticateNode takes the key and secret of a node and finds the corresponding
 // row in the node table.
-func AuthenticateNode(ctx context.Context, dbc *sqlx.DB, key, secret string) (*Node, error) {
-	stmt, err := dbc.Preparex("SELECT * FROM node WHERE public_key=$1 AND secret=$2;")
+func AuthenticateNode(ctx context.Context, dbc *sqlx.DB, key, secret string) (_ *Node, err error) {
+	ctx, span := tracing.Start(ctx, "node.AuthenticateNode")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, "SELECT * FROM node WHERE public_key=$1 AND secret=$2;")
 	if err != nil {
 		return nil, fmt.Errorf("prepare statement: %w", err)
 	}
@@ -42,10 +54,27 @@ func AuthenticateNode(ctx context.Context, dbc *sqlx.DB, key, secret string) (*N
 	return &node, nil
 }
 
+// GetNode takes the public key of a node and finds the corresponding row in the node
+// table.
+func GetNode(ctx context.Context, dbc *sqlx.DB, key string) (_ *Node, err error) {
+	ctx, span := tracing.Start(ctx, "node.GetNode")
+	defer func() { tracing.End(span, err) }()
+
+	var node Node
+	if err := dbc.GetContext(ctx, &node, "SELECT * FROM node WHERE public_key=$1;", key); err != nil {
+		return nil, fmt.Errorf("retrieve record from table: %w", err)
+	}
+
+	return &node, nil
+}
+
 // CreateNode takes a name and a description and returns a created node with the key
 // and secret filled out.
-func CreateNode(ctx context.Context, dbc *sqlx.DB, name, description string) (*Node, error) {
-	stmt, err := dbc.Preparex("INSERT INTO node (\"name\", description) VALUES ($1, $2) RETURNING public_key, secret;")
+func CreateNode(ctx context.Context, dbc *sqlx.DB, name, description string) (_ *Node, err error) {
+	ctx, span := tracing.Start(ctx, "node.CreateNode")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, "INSERT INTO node (\"name\", description) VALUES ($1, $2) RETURNING public_key, secret;")
 	if err != nil {
 		return nil, fmt.Errorf("prepare statement: %w", err)
 	}
@@ -64,3 +93,28 @@ func CreateNode(ctx context.Context, dbc *sqlx.DB, name, description string) (*N
 
 	return &node, nil
 }
+
+// UpdateSender sets the sender address, sender name and reply-to address that the node
+// with the given public key sends its notifications from, empty values falling back to
+// those of the daemon. sql.ErrNoRows is returned when there is no such node.
+func UpdateSender(ctx context.Context, dbc *sqlx.DB, key, address, name, replyTo string) (err error) {
+	ctx, span := tracing.Start(ctx, "node.UpdateSender")
+	defer func() { tracing.End(span, err) }()
+
+	res, err := dbc.ExecContext(ctx, `UPDATE node SET sender_address = $2, sender_name = $3, reply_to = $4, modified = NOW()
+WHERE public_key = $1;`, key, address, name, replyTo)
+	if err != nil {
+		return fmt.Errorf("update node sender: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("retrieve rows affected: %w", err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("update node sender: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
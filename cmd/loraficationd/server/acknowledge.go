@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/platform/web"
+	"go.uber.org/zap"
+)
+
+// purposeAcknowledge is the purpose of tokens that acknowledge a notification, their ID
+// being the ID of the delivery that the notification was delivered through.
+const purposeAcknowledge = "acknowledge"
+
+// acknowledgeTTL is the amount of time that an acknowledge link is valid for.
+const acknowledgeTTL = 7 * 24 * time.Hour
+
+// acknowledgeURL returns a signed URL that acknowledges the notification delivered by the
+// delivery with the given ID.
+func (s *Server) acknowledgeURL(deliveryID int) string {
+	tok := s.signer.Sign(purposeAcknowledge, deliveryID, time.Now().Add(acknowledgeTTL))
+
+	return fmt.Sprintf("%s/acknowledge?token=%s", s.config.PublicURL, url.QueryEscape(tok))
+}
+
+// AcknowledgeResponse is the type that represents the response body for
+// *Server.Acknowledge.
+type AcknowledgeResponse struct {
+	Message        string    `json:"message"`
+	NotificationID int       `json:"notificationID"`
+	AcknowledgedAt time.Time `json:"acknowledgedAt"`
+}
+
+// Acknowledge acknowledges the notification delivered by the delivery identified by the
+// signed token within the query string, on behalf of the delivery's contact point. A GET,
+// made when the link or button within a notification is followed, only shows a page whose
+// form POSTs the token back, so that link previews and scanners don't acknowledge the
+// notification on behalf of its recipient. A POST may also be made by integrations that
+// call back into the daemon. A POST of a form holding Digits is the keypress of a voice
+// call, which is handled by *Server.acknowledgeCall.
+func (s *Server) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	tok := r.URL.Query().Get("token")
+	if tok == "" {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("token must be provided", nil))
+		return
+	}
+
+	deliveryID, err := s.signer.Verify(purposeAcknowledge, tok, time.Now())
+	if err != nil {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("invalid or expired token", err))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		confirm(w, r, confirmation{
+			Title:  "Acknowledge alert",
+			Prompt: "Confirm that you're handling this alert, so that nobody else is notified about it.",
+			Button: "Acknowledge",
+		}, tok)
+		return
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("deliveryID", deliveryID))
+
+	if digits, ok := keypress(r); ok {
+		s.acknowledgeCall(w, r, deliveryID, digits)
+		return
+	}
+
+	n, err := delivery.AcknowledgeNotification(r.Context(), s.dbc, deliveryID)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("acknowledge notification: %w", translateError(err)))
+		return
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("notificationID", n.ID))
+
+	web.Respond(w, r, http.StatusOK, AcknowledgeResponse{
+		Message:        "The notification has been acknowledged.",
+		NotificationID: n.ID,
+		AcknowledgedAt: *n.AcknowledgedAt,
+	})
+}
+
+// keypress returns the digits pressed during a voice call, posted by its provider as the
+// Digits field of a form, and whether or not the request holds any.
+func keypress(r *http.Request) (string, bool) {
+	if err := r.ParseForm(); err != nil {
+		return "", false
+	}
+
+	digits, ok := r.PostForm["Digits"]
+	if !ok || len(digits) == 0 {
+		return "", false
+	}
+
+	return digits[0], true
+}
+
+// acknowledgeCall acknowledges the notification delivered by the delivery with the given
+// ID when the callee pressed channel.AcknowledgeDigit, responding with TwiML that tells
+// them whether or not it was acknowledged. Failures are read out to the callee rather
+// than responded with as errors, which the provider would read out as its own.
+func (s *Server) acknowledgeCall(w http.ResponseWriter, r *http.Request, deliveryID int, digits string) {
+	web.AddLogFields(r.Context(), zap.String("digits", digits))
+
+	speech := "The alert has been acknowledged. Goodbye."
+	if digits != channel.AcknowledgeDigit {
+		speech = "That key doesn't acknowledge the alert. Goodbye."
+	} else if n, err := delivery.AcknowledgeNotification(r.Context(), s.dbc, deliveryID); err != nil {
+		web.Logger(r.Context()).Error("acknowledge notification", zap.Error(err))
+		speech = "The alert could not be acknowledged. Goodbye."
+	} else {
+		web.AddLogFields(r.Context(), zap.Int("notificationID", n.ID))
+	}
+
+	b, err := channel.SayTwiML(speech)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("marshal twiml: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
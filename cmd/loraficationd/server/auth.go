@@ -0,0 +1,25 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearerPrefix is the prefix of the Authorization header value that carries a bearer token.
+const bearerPrefix = "Bearer "
+
+// isAdmin reports whether or not the request carries the configured admin token as its
+// bearer token. No request is an admin request if no admin token is configured.
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.config.AdminToken == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, bearerPrefix)), []byte(s.config.AdminToken)) == 1
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/web"
+)
+
+// maxReportBytes is the maximum size of a report that *Server.RecordBounces will read,
+// larger than that of JSON request bodies as reports may hold the email that bounced.
+const maxReportBytes = 10 << 20
+
+// Bounce is the type that represents a bounce within response bodies.
+type Bounce struct {
+	Recipient string `json:"recipient"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// RecordBouncesResponse is the type that represents the response body for
+// *Server.RecordBounces.
+type RecordBouncesResponse struct {
+	Bounces []Bounce `json:"bounces"`
+}
+
+// RecordBounces records the bounces reported by the delivery status notification or
+// feedback report within the request body, as a raw email, for inbound email services
+// to forward reports to. Only admins may record bounces, as they suspend contact points.
+func (s *Server) RecordBounces(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to record bounces", nil))
+		return
+	}
+
+	bounces, err := mail.ParseReport(http.MaxBytesReader(w, r.Body, maxReportBytes))
+	if err != nil {
+		web.RespondError(w, r, http.StatusBadRequest,
+			web.NewValidationError("request body must be a delivery status notification or feedback report", err))
+		return
+	}
+
+	if err := s.bounces.Record(r.Context(), bounces); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("record bounces: %w", err))
+		return
+	}
+
+	resData := RecordBouncesResponse{
+		Bounces: make([]Bounce, 0, len(bounces)),
+	}
+	for _, b := range bounces {
+		resData.Bounces = append(resData.Bounces, Bounce{
+			Recipient: b.Recipient,
+			Kind:      b.Kind,
+			Status:    b.Status,
+			Reason:    b.Reason,
+		})
+	}
+	web.Respond(w, r, http.StatusOK, resData)
+}
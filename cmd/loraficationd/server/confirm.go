@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/22arw/lorafication/internal/platform/web"
+)
+
+// confirmTemplate is the page that links within notifications and verification messages
+// lead to. Link scanners and mail clients open links before their recipient does, so
+// links only ever show this page, whose form is what acts upon them.
+var confirmTemplate = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Prompt}}</p>
+<form method="post" action="{{.Action}}">
+<button type="submit">{{.Button}}</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmation is the content of a confirmTemplate page.
+type confirmation struct {
+	Title  string
+	Prompt string
+	Button string
+	Action string
+}
+
+// confirm responds with a page asking the recipient of a link to confirm it, whose form
+// posts the given token back to the path of the request.
+func confirm(w http.ResponseWriter, r *http.Request, c confirmation, tok string) {
+	c.Action = fmt.Sprintf("%s?token=%s", r.URL.Path, url.QueryEscape(tok))
+
+	var b bytes.Buffer
+	if err := confirmTemplate.Execute(&b, c); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("execute confirm template: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
@@ -1,32 +1,304 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/22arw/lorafication/cmd/loraficationd/contract"
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/internal/platform/validate"
 	"github.com/22arw/lorafication/internal/platform/web"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
 )
 
-// CreateContractRequest is the type that represents the request body for *Server.CreateContract.
+// purposeUnsubscribe is the purpose of tokens that unsubscribe an entity from a node,
+// their ID being the ID of the contract between the two.
+const purposeUnsubscribe = "unsubscribe"
+
+// unsubscribeTTL is the amount of time that an unsubscribe link is valid for. Emails may
+// be acted on long after they're received, so links are valid for a long time.
+const unsubscribeTTL = 365 * 24 * time.Hour
+
+// Reasons recorded against a contract deactivated through *Server.Unsubscribe.
+const (
+	reasonUnsubscribeLink     = "unsubscribe link"
+	reasonUnsubscribeOneClick = "one-click unsubscribe"
+)
+
+// RoutingRequest is the type that represents how the notifications of a node are routed
+// to an entity within request bodies. Every field is optional.
+//
+// Channels limits the channels, being types of contact point, that the entity is notified
+// through. MinSeverity limits the entity to notifications of at least that severity, and
+// SeverityChannels limits the channels that the entity is notified through of each
+// severity it lists. ActiveFrom and ActiveUntil, given together as HH:MM in the Timezone,
+// defaulting to UTC, limit the hours that notifications below critical are sent during.
+// Enabled defaults to true.
+type RoutingRequest struct {
+	Enabled          *bool               `json:"enabled"`
+	Channels         []string            `json:"channels"`
+	MinSeverity      string              `json:"minSeverity"`
+	SeverityChannels map[string][]string `json:"severityChannels"`
+	ActiveFrom       string              `json:"activeFrom"`
+	ActiveUntil      string              `json:"activeUntil"`
+	Timezone         string              `json:"timezone"`
+}
+
+// validate checks the fields of the request that can be checked without the database.
+func (req RoutingRequest) validate(fields *web.FieldErrors) {
+	for i, c := range req.Channels {
+		if !contains(entity.ContactTypes, c) {
+			fields.Check(fmt.Sprintf("channels[%d]", i), fmt.Errorf("must be one of %v", entity.ContactTypes))
+		}
+	}
+
+	for sev, channels := range req.SeverityChannels {
+		for i, c := range channels {
+			if !contains(entity.ContactTypes, c) {
+				fields.Check(fmt.Sprintf("severityChannels.%s[%d]", sev, i), fmt.Errorf("must be one of %v", entity.ContactTypes))
+			}
+		}
+	}
+
+	if (req.ActiveFrom == "") != (req.ActiveUntil == "") {
+		fields.Check("activeUntil", errors.New("must be provided along with activeFrom"))
+	} else if req.ActiveFrom != "" {
+		fields.Check("activeFrom", clock(req.ActiveFrom))
+		fields.Check("activeUntil", clock(req.ActiveUntil))
+		if req.ActiveFrom == req.ActiveUntil {
+			fields.Check("activeUntil", errors.New("must not be the same as activeFrom"))
+		}
+	}
+
+	// The local time zone is that of the daemon, which isn't meaningful to an entity.
+	if req.Timezone == "Local" {
+		fields.Check("timezone", errors.New("must be an IANA time zone"))
+	} else if _, err := time.LoadLocation(req.Timezone); err != nil {
+		fields.Check("timezone", errors.New("must be an IANA time zone"))
+	}
+}
+
+// clock returns an error if the given value isn't a time of day formatted as HH:MM.
+func clock(value string) error {
+	if _, err := time.Parse(contract.ClockLayout, value); err != nil {
+		return errors.New("must be a time of day formatted as HH:MM")
+	}
+
+	return nil
+}
+
+// routing checks the severities of the request, which can't be validated until they've
+// been retrieved as custom ones are stored alongside the built-in ones, and returns the
+// routing it represents. Severities that don't exist are returned as a validation error.
+func (s *Server) routing(ctx context.Context, req RoutingRequest) (contract.Routing, error) {
+	severities, err := severity.ListSeverities(ctx, s.dbc)
+	if err != nil {
+		return contract.Routing{}, fmt.Errorf("list severities: %w", err)
+	}
+
+	routing := contract.Routing{
+		Enabled:  true,
+		Channels: req.Channels,
+		ActiveHours: contract.ActiveHours{
+			Timezone: req.Timezone,
+		},
+	}
+
+	if req.Enabled != nil {
+		routing.Enabled = *req.Enabled
+	}
+
+	if routing.Timezone == "" {
+		routing.Timezone = "UTC"
+	}
+
+	var fields web.FieldErrors
+	if req.MinSeverity != "" {
+		checkSeverity(&fields, "minSeverity", severities, req.MinSeverity)
+		routing.MinSeverity = &req.MinSeverity
+	}
+
+	if len(req.SeverityChannels) > 0 {
+		for sev := range req.SeverityChannels {
+			checkSeverity(&fields, "severityChannels."+sev, severities, sev)
+		}
+		routing.SeverityChannels = req.SeverityChannels
+	}
+
+	if req.ActiveFrom != "" {
+		routing.ActiveFrom = &req.ActiveFrom
+		routing.ActiveUntil = &req.ActiveUntil
+	}
+
+	return routing, fields.Err()
+}
+
+// CreateContractRequest is the type that represents the request body for *Server.CreateContract,
+// along with how the node's notifications are routed to the entity.
 type CreateContractRequest struct {
 	NodePublicKey string `json:"nodePublicKey"`
 	EntityID      int    `json:"entityID"`
+	RoutingRequest
+}
+
+// Validate implements the web.Validator interface.
+func (req CreateContractRequest) Validate() error {
+	var fields web.FieldErrors
+	fields.Check("nodePublicKey", validate.Required(req.NodePublicKey), validate.UUID(req.NodePublicKey))
+	fields.Check("entityID", validate.Positive(req.EntityID))
+	req.RoutingRequest.validate(&fields)
+
+	return fields.Err()
 }
 
 // CreateContract creates a contract between an entity and a node on the lorafication server.
 func (s *Server) CreateContract(w http.ResponseWriter, r *http.Request) {
 	var reqData CreateContractRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("decode request body: %w", err))
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	routing, err := s.routing(r.Context(), reqData.RoutingRequest)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("resolve routing: %w", err))
 		return
 	}
 
-	if err := contract.CreateContract(r.Context(), s.dbc, reqData.NodePublicKey, reqData.EntityID); err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("create entity: %w", err))
+	if err := contract.CreateContract(r.Context(), s.dbc, reqData.NodePublicKey, reqData.EntityID, routing); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("create contract: %w", translateError(err)))
 		return
 	}
 
-	web.Respond(w, r, s.logger, http.StatusCreated, nil)
+	web.Respond(w, r, http.StatusCreated, nil)
+}
+
+// UpdateRoutingRequest is the type that represents the request body for
+// *Server.UpdateRouting.
+type UpdateRoutingRequest struct {
+	RoutingRequest
+}
+
+// Validate implements the web.Validator interface.
+func (req UpdateRoutingRequest) Validate() error {
+	var fields web.FieldErrors
+	req.RoutingRequest.validate(&fields)
+
+	return fields.Err()
+}
+
+// UpdateRouting replaces how the notifications of a node are routed to the entity of the
+// contract whose ID is within the path, fields left out of the request body being reset
+// to their defaults. Only admins may update the routing of a contract.
+func (s *Server) UpdateRouting(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to update a contract's routing", nil))
+		return
+	}
+
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil {
+		web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("contract not found", err))
+		return
+	}
+
+	var reqData UpdateRoutingRequest
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	routing, err := s.routing(r.Context(), reqData.RoutingRequest)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("resolve routing: %w", err))
+		return
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("contractID", id))
+
+	if err := contract.UpdateRouting(r.Context(), s.dbc, id, routing); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("update contract routing: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusNoContent, nil)
+}
+
+// unsubscribeURL returns a signed URL that deactivates the contract with the given ID.
+func (s *Server) unsubscribeURL(contractID int) string {
+	tok := s.signer.Sign(purposeUnsubscribe, contractID, time.Now().Add(unsubscribeTTL))
+
+	return fmt.Sprintf("%s/unsubscribe?token=%s", s.config.PublicURL, url.QueryEscape(tok))
+}
+
+// UnsubscribeResponse is the type that represents the response body for *Server.Unsubscribe.
+type UnsubscribeResponse struct {
+	Message string `json:"message"`
+}
+
+// Unsubscribe deactivates the contract identified by the signed token within the query
+// string, recording why. A GET is made when a recipient follows the link within an email,
+// which only shows a page whose form POSTs the token back, as link scanners and mail
+// clients open links before their recipient does. An RFC 8058 one-click POST is made by
+// mail clients through the List-Unsubscribe header.
+func (s *Server) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	tok := r.URL.Query().Get("token")
+	if tok == "" {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("token must be provided", nil))
+		return
+	}
+
+	contractID, err := s.signer.Verify(purposeUnsubscribe, tok, time.Now())
+	if err != nil {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("invalid or expired token", err))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		confirm(w, r, confirmation{
+			Title:  "Unsubscribe",
+			Prompt: "Confirm that you would no longer like to receive these LoRafication notifications.",
+			Button: "Unsubscribe",
+		}, tok)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, web.NewBadRequestError(fmt.Errorf("parse form: %w", err)))
+		return
+	}
+
+	reason := reasonUnsubscribeLink
+	if r.PostForm.Get("List-Unsubscribe") == "One-Click" {
+		reason = reasonUnsubscribeOneClick
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("contractID", contractID), zap.String("reason", reason))
+
+	if err := contract.DeactivateContract(r.Context(), s.dbc, contractID, reason); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("deactivate contract: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusOK, UnsubscribeResponse{
+		Message: "You have been unsubscribed and will no longer receive these notifications.",
+	})
+}
+
+// contains reports whether or not value is within values.
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,46 +1,468 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/validate"
 	"github.com/22arw/lorafication/internal/platform/web"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
 )
 
+// purposeVerify is the purpose of tokens that verify a contact point, their ID being the
+// ID of the contact point.
+const purposeVerify = "verify"
+
+// Phone contact points are verified with a code of verificationCodeDigits digits, which
+// expires after verificationCodeTTL and may be guessed verificationCodeAttempts times.
+const (
+	verificationCodeDigits   = 6
+	verificationCodeTTL      = 15 * time.Minute
+	verificationCodeAttempts = 5
+)
+
+// webhookSecretBytes is the number of random bytes that the secrets generated for webhook
+// contact points are made of.
+const webhookSecretBytes = 32
+
+// ContactPoint is the type that represents a contact point of an entity within response
+// bodies. The bounce fields are only set for email contact points that have bounced, and
+// the secret of webhook contact points is only set when they're created.
+type ContactPoint struct {
+	ID           int        `json:"id"`
+	Type         string     `json:"type"`
+	Address      string     `json:"address"`
+	Verified     bool       `json:"verified"`
+	Priority     int        `json:"priority"`
+	Suspended    bool       `json:"suspended"`
+	BounceCount  int        `json:"bounceCount,omitempty"`
+	BouncedAt    *time.Time `json:"bouncedAt,omitempty"`
+	BounceReason string     `json:"bounceReason,omitempty"`
+	Secret       string     `json:"secret,omitempty"`
+}
+
+// newContactPoint returns the response body representation of the given contact point.
+func newContactPoint(cp entity.ContactPoint) ContactPoint {
+	return ContactPoint{
+		ID:           cp.ID,
+		Type:         cp.Type,
+		Address:      cp.Address,
+		Verified:     cp.Verified(),
+		Priority:     cp.Priority,
+		Suspended:    cp.Suspended(),
+		BounceCount:  cp.BounceCount,
+		BouncedAt:    cp.BouncedAt,
+		BounceReason: cp.BounceReason,
+	}
+}
+
 // CreateEntityRequest is the type that represents the request body for *Server.CreateEntity.
 type CreateEntityRequest struct {
-	Name  string  `json:"name"`
-	Email *string `json:"email"`
-	SMS   *int    `json:"sms"`
+	Name          string                `json:"name"`
+	ContactPoints []ContactPointRequest `json:"contactPoints"`
+}
+
+// ContactPointRequest is the type that represents a contact point within the request body
+// for *Server.CreateEntity. Verified may only be set by admin requests. Secret is the
+// optional secret that events posted to a webhook contact point are signed with, one
+// being generated when it isn't given, or the access token or application token that
+// messages are sent to a matrix or push contact point with.
+type ContactPointRequest struct {
+	Type     string `json:"type"`
+	Address  string `json:"address"`
+	Priority int    `json:"priority"`
+	Verified bool   `json:"verified"`
+	Secret   string `json:"secret"`
+}
+
+// Validate implements the web.Validator interface.
+func (req CreateEntityRequest) Validate() error {
+	var fields web.FieldErrors
+	fields.Check("name", validate.Required(req.Name), validate.MaxLength(req.Name, validate.MaxVarchar))
+
+	if len(req.ContactPoints) == 0 {
+		fields.Check("contactPoints", errors.New("at least one contact point must be provided"))
+	}
+
+	for i, cp := range req.ContactPoints {
+		field := fmt.Sprintf("contactPoints[%d]", i)
+
+		switch cp.Type {
+		case entity.ContactTypeEmail:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.Email(cp.Address))
+		case entity.ContactTypeSMS, entity.ContactTypeVoice:
+			fields.Check(field+".address", validate.E164(cp.Address))
+		case entity.ContactTypeWebhook:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.HTTPURL(cp.Address))
+			fields.Check(field+".secret", validate.MaxLength(cp.Secret, validate.MaxVarchar))
+		case entity.ContactTypeSlack, entity.ContactTypeTeams, entity.ContactTypeMattermost:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.HTTPURL(cp.Address))
+		case entity.ContactTypeTelegram:
+			fields.Check(field+".address", validate.TelegramChatID(cp.Address))
+		case entity.ContactTypeMatrix:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.MatrixRoomID(cp.Address))
+			fields.Check(field+".secret", validate.MaxLength(cp.Secret, validate.MaxVarchar))
+		case entity.ContactTypeNtfy:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.HTTPURL(cp.Address))
+			fields.Check(field+".secret", validate.MaxLength(cp.Secret, validate.MaxVarchar))
+		case entity.ContactTypeGotify:
+			fields.Check(field+".address", validate.MaxLength(cp.Address, validate.MaxVarchar), validate.HTTPURL(cp.Address))
+			fields.Check(field+".secret", validate.Required(cp.Secret), validate.MaxLength(cp.Secret, validate.MaxVarchar))
+		case entity.ContactTypePushover:
+			fields.Check(field+".address", validate.PushoverKey(cp.Address))
+			fields.Check(field+".secret", validate.MaxLength(cp.Secret, validate.MaxVarchar))
+		default:
+			fields.Check(field+".type", fmt.Errorf("must be one of %v", entity.ContactTypes))
+		}
+	}
+
+	return fields.Err()
 }
 
 // CreateEntityResponse is the type that represents the response body for *Server.CreateEntity.
 type CreateEntityResponse struct {
-	Name  string  `json:"name"`
-	Email *string `json:"email"`
-	SMS   *int    `json:"sms"`
+	ID            int            `json:"id"`
+	Name          string         `json:"name"`
+	ContactPoints []ContactPoint `json:"contactPoints"`
 }
 
-// CreateEntity creates an entity on the lorafication server.
+// CreateEntity creates an entity on the lorafication server and sends a verification link
+// to each of its email contact points that isn't already verified, and a verification code
+// to each of its phone contact points that one can be sent to. The secrets of webhook
+// contact points are responded with, as they can't be retrieved later.
 func (s *Server) CreateEntity(w http.ResponseWriter, r *http.Request) {
 	var reqData CreateEntityRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("decode request body: %w", err))
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
 		return
 	}
 
-	e, err := entity.CreateEntity(r.Context(), s.dbc, reqData.Name, reqData.Email, reqData.SMS)
+	now := time.Now()
+	contactPoints := make([]entity.ContactPoint, 0, len(reqData.ContactPoints))
+	for _, cp := range reqData.ContactPoints {
+		contactPoint := entity.ContactPoint{
+			Type:     cp.Type,
+			Address:  cp.Address,
+			Priority: cp.Priority,
+		}
+
+		switch cp.Type {
+		case entity.ContactTypeMatrix, entity.ContactTypeNtfy, entity.ContactTypeGotify, entity.ContactTypePushover:
+			contactPoint.Secret = cp.Secret
+		case entity.ContactTypeWebhook:
+			contactPoint.Secret = cp.Secret
+			if contactPoint.Secret == "" {
+				secret, err := newWebhookSecret()
+				if err != nil {
+					web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("generate webhook secret: %w", err))
+					return
+				}
+				contactPoint.Secret = secret
+			}
+		}
+
+		// Only administrators may vouch for contact points, such as when bulk importing
+		// trusted contacts.
+		if cp.Verified {
+			if !s.isAdmin(r) {
+				web.RespondError(w, r, http.StatusUnauthorized,
+					web.NewUnauthorizedError("an admin token is required to create verified contact points", nil))
+				return
+			}
+			contactPoint.VerifiedAt = &now
+		}
+
+		contactPoints = append(contactPoints, contactPoint)
+	}
+
+	e, err := entity.CreateEntity(r.Context(), s.dbc, reqData.Name, contactPoints)
 	if err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("create entity: %w", err))
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("create entity: %w", translateError(err)))
 		return
 	}
 
+	var errs []error
 	resData := CreateEntityResponse{
-		Name:  e.Name,
-		Email: e.Email,
-		SMS:   e.SMS,
+		ID:            e.ID,
+		Name:          e.Name,
+		ContactPoints: make([]ContactPoint, 0, len(e.ContactPoints)),
 	}
-	web.Respond(w, r, s.logger, http.StatusCreated, resData)
+	for _, cp := range e.ContactPoints {
+		if !cp.Verified() && s.sendsVerification(cp.Type) {
+			// Failing to send a verification doesn't undo the creation of the entity, the
+			// client is told which contact points weren't sent one without the details.
+			if err := s.sendVerification(r.Context(), e, cp); err != nil {
+				web.Logger(r.Context()).Warn("send verification", zap.Int("contactPointID", cp.ID), zap.Error(err))
+				errs = append(errs, fmt.Errorf("unable to send verification to contact point %d", cp.ID))
+			}
+		}
+
+		// Only the secrets of webhooks, which may have been generated, are responded with,
+		// rather than the tokens of matrix and push contact points.
+		resCP := newContactPoint(cp)
+		if cp.Type == entity.ContactTypeWebhook {
+			resCP.Secret = cp.Secret
+		}
+		resData.ContactPoints = append(resData.ContactPoints, resCP)
+	}
+	web.Respond(w, r, http.StatusCreated, resData, errs...)
+}
+
+// newWebhookSecret returns a random, hex encoded secret for a webhook contact point.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// GetEntityResponse is the type that represents the response body for *Server.GetEntity.
+type GetEntityResponse struct {
+	ID            int            `json:"id"`
+	Name          string         `json:"name"`
+	ContactPoints []ContactPoint `json:"contactPoints"`
+}
+
+// GetEntity responds with the entity whose ID is within the path, along with its contact
+// points and whether they're bouncing. As contact points are personal details, only admins
+// may get entities.
+func (s *Server) GetEntity(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to get an entity", nil))
+		return
+	}
+
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil {
+		web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("entity not found", err))
+		return
+	}
+
+	e, err := entity.GetEntity(r.Context(), s.dbc, id)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("get entity: %w", translateError(err)))
+		return
+	}
+
+	resData := GetEntityResponse{
+		ID:            e.ID,
+		Name:          e.Name,
+		ContactPoints: make([]ContactPoint, 0, len(e.ContactPoints)),
+	}
+	for _, cp := range e.ContactPoints {
+		resData.ContactPoints = append(resData.ContactPoints, newContactPoint(cp))
+	}
+	web.Respond(w, r, http.StatusOK, resData)
+}
+
+// sendsVerification reports whether or not contact points of the given type are sent a
+// verification, rather than being verified by an administrator.
+func (s *Server) sendsVerification(contactType string) bool {
+	switch contactType {
+	case entity.ContactTypeEmail:
+		return true
+	case entity.ContactTypeSMS:
+		return s.texter != nil
+	case entity.ContactTypeVoice:
+		return s.caller != nil
+	default:
+		return false
+	}
+}
+
+// sendVerification sends a verification to the given contact point of the given entity,
+// being a link for email contact points and a code for phone ones.
+func (s *Server) sendVerification(ctx context.Context, e *entity.Entity, cp entity.ContactPoint) error {
+	switch cp.Type {
+	case entity.ContactTypeSMS, entity.ContactTypeVoice:
+		return s.sendVerificationCode(ctx, e, cp)
+	default:
+		return s.sendVerificationEmail(ctx, e, cp)
+	}
+}
+
+// sendVerificationCode texts, or for voice contact points reads out, a new code that
+// verifies the given contact point of the given entity.
+func (s *Server) sendVerificationCode(ctx context.Context, e *entity.Entity, cp entity.ContactPoint) error {
+	code, err := newVerificationCode()
+	if err != nil {
+		return fmt.Errorf("generate code: %w", err)
+	}
+
+	if err := entity.SetVerificationCode(ctx, s.dbc, e.ID, cp.ID, code, verificationCodeTTL); err != nil {
+		return fmt.Errorf("set code: %w", err)
+	}
+
+	if cp.Type == entity.ContactTypeVoice {
+		// The digits are read out one at a time, rather than as a number.
+		return s.caller.Call(ctx, channel.Call{
+			To: cp.Address,
+			Speech: fmt.Sprintf("Your LoRafication verification code for %s is %s. Again, your code is %s.",
+				e.Name, spellDigits(code), spellDigits(code)),
+		})
+	}
+
+	return s.texter.Text(ctx, cp.Address, fmt.Sprintf("Your LoRafication verification code for %s is %s. It expires in %d minutes.",
+		e.Name, code, int(verificationCodeTTL.Minutes())))
+}
+
+// newVerificationCode returns a random code of verificationCodeDigits decimal digits.
+func newVerificationCode() (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(verificationCodeDigits), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
+
+// spellDigits separates the digits of a code so that they're read out one at a time.
+func spellDigits(code string) string {
+	return strings.Join(strings.Split(code, ""), " ")
+}
+
+// sendVerificationEmail emails a link that verifies the given contact point of the given
+// entity.
+func (s *Server) sendVerificationEmail(ctx context.Context, e *entity.Entity, cp entity.ContactPoint) error {
+	expires := time.Now().Add(s.config.VerificationTTL.Duration)
+	link := fmt.Sprintf("%s/entity/%d/verify?token=%s", s.config.PublicURL, e.ID,
+		url.QueryEscape(s.signer.Sign(purposeVerify, cp.ID, expires)))
+
+	expiry := expires.UTC().Format(time.RFC1123)
+
+	text := fmt.Sprintf("This email address has been registered to receive notifications from LoRafication on behalf of %s.\n\n"+
+		"Verify this email address to start receiving them by following the link below. The link expires on %s.\n\n%s\n\n"+
+		"If you weren't expecting this email, it can safely be ignored.\n",
+		e.Name, expiry, link)
+
+	htmlBody := fmt.Sprintf("<p>This email address has been registered to receive notifications from LoRafication on behalf of %s.</p>"+
+		"<p><a href=\"%s\">Verify this email address</a> to start receiving them. The link expires on %s.</p>"+
+		"<p>If you weren't expecting this email, it can safely be ignored.</p>",
+		html.EscapeString(e.Name), html.EscapeString(link), expiry)
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      cp.Address,
+		Subject: "LoRafication: Verify your email address",
+		Text:    text,
+		HTML:    htmlBody,
+	})
+}
+
+// VerifyContactPointRequest is the type that represents the request body for
+// *Server.VerifyContactPoint. Either a token, a contact point ID along with the code sent
+// to it, or for admin requests a contact point ID alone, must be provided.
+type VerifyContactPointRequest struct {
+	Token          string `json:"token"`
+	ContactPointID int    `json:"contactPointID"`
+	Code           string `json:"code"`
+}
+
+// Validate implements the web.Validator interface.
+func (req VerifyContactPointRequest) Validate() error {
+	var fields web.FieldErrors
+
+	if req.Token == "" && req.ContactPointID == 0 {
+		fields.Check("token", errors.New("token or contactPointID must be provided"))
+	}
+
+	if req.ContactPointID != 0 {
+		fields.Check("contactPointID", validate.Positive(req.ContactPointID))
+	}
+
+	if req.Code != "" {
+		fields.Check("code", validate.MaxLength(req.Code, verificationCodeDigits))
+
+		if req.ContactPointID == 0 {
+			fields.Check("contactPointID", errors.New("must be provided along with a code"))
+		}
+	}
+
+	return fields.Err()
+}
+
+// VerifyContactPoint verifies a contact point of an entity, allowing it to be notified. The
+// token sent to the contact point may be given as a query parameter, so that it can be
+// linked to, or in the request body. A GET of the link only shows a page whose form POSTs
+// the token back, so that link scanners opening it don't verify the contact point on
+// behalf of its owner. Phone contact points are instead verified by POSTing the code sent
+// to them along with their ID.
+func (s *Server) VerifyContactPoint(w http.ResponseWriter, r *http.Request) {
+	entityID, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil {
+		web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("entity not found", err))
+		return
+	}
+
+	reqData := VerifyContactPointRequest{
+		Token: r.URL.Query().Get("token"),
+	}
+
+	if reqData.Token == "" {
+		if err := web.Decode(w, r, &reqData); err != nil {
+			web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+			return
+		}
+	}
+
+	contactPointID := reqData.ContactPointID
+	if reqData.Token != "" {
+		if contactPointID, err = s.signer.Verify(purposeVerify, reqData.Token, time.Now()); err != nil {
+			web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("invalid or expired token", err))
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			confirm(w, r, confirmation{
+				Title:  "Verify your contact details",
+				Prompt: "Confirm that you would like to receive LoRafication notifications here.",
+				Button: "Verify",
+			}, reqData.Token)
+			return
+		}
+	} else if reqData.Code != "" {
+		web.AddLogFields(r.Context(), zap.Int("entityID", entityID), zap.Int("contactPointID", contactPointID))
+
+		if err := entity.VerifyContactPointCode(r.Context(), s.dbc, entityID, contactPointID, reqData.Code, verificationCodeAttempts); err != nil {
+			if errors.Is(err, entity.ErrInvalidCode) {
+				web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("invalid or expired code", err))
+				return
+			}
+
+			web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("verify contact point code: %w", translateError(err)))
+			return
+		}
+
+		web.Respond(w, r, http.StatusNoContent, nil)
+		return
+	} else if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized,
+			web.NewUnauthorizedError("an admin token is required to verify a contact point without a token", nil))
+		return
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("entityID", entityID), zap.Int("contactPointID", contactPointID))
+
+	if err := entity.VerifyContactPoint(r.Context(), s.dbc, entityID, contactPointID); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("verify contact point: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusNoContent, nil)
 }
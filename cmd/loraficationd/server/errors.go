@@ -0,0 +1,54 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/22arw/lorafication/internal/platform/db"
+	"github.com/22arw/lorafication/internal/platform/web"
+)
+
+// constraintFields maps the names of database constraints to the request field that
+// violated them.
+var constraintFields = map[string]web.FieldError{
+	"contract_node_public_key_fkey": {Field: "nodePublicKey", Message: "must reference an existing node"},
+	"contract_entity_id_fkey":       {Field: "entityID", Message: "must reference an existing entity"},
+	"template_node_public_key_fkey": {Field: "publicKey", Message: "must reference an existing node"},
+	"contact_point_address_unique":  {Field: "contactPoints", Message: "must not contain duplicate contact points"},
+	"severity_pkey":                 {Field: "name", Message: "must not be the name of another severity"},
+	"severity_level_unique":         {Field: "level", Message: "must not be the level of another severity"},
+	"contract_min_severity_fkey":    {Field: "minSeverity", Message: "must reference an existing severity"},
+}
+
+// translateError translates errors returned from the repository packages into the typed
+// errors of the web package so that they are responded to with the correct status code.
+// Errors that can't be translated are returned as is.
+func translateError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return web.NewNotFoundError("resource not found", err)
+	}
+
+	dbErr := db.Classify(err)
+	if dbErr == nil {
+		return err
+	}
+
+	var fields []web.FieldError
+	if field, ok := constraintFields[dbErr.Constraint]; ok {
+		fields = append(fields, field)
+	}
+
+	switch {
+	case errors.Is(dbErr, db.ErrUniqueViolation):
+		return web.NewConflictError("resource already exists", err, fields...)
+	case errors.Is(dbErr, db.ErrForeignKeyViolation):
+		return web.NewNotFoundError("referenced resource not found", err, fields...)
+	case errors.Is(dbErr, db.ErrNotNullViolation):
+		fields = append(fields, web.FieldError{Field: dbErr.Column, Message: "must be provided"})
+		return web.NewValidationError("request failed validation", err, fields...)
+	case errors.Is(dbErr, db.ErrCheckViolation):
+		return web.NewValidationError("request failed validation", err, fields...)
+	default:
+		return web.NewValidationError("request contains an invalid value", err, fields...)
+	}
+}
@@ -1,12 +1,13 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/22arw/lorafication/cmd/loraficationd/node"
+	"github.com/22arw/lorafication/internal/platform/validate"
 	"github.com/22arw/lorafication/internal/platform/web"
+	"github.com/julienschmidt/httprouter"
 )
 
 // CreateNodeRequest is the type that represents the request body for *Server.CreateNode.
@@ -15,6 +16,14 @@ type CreateNodeRequest struct {
 	Description string `json:"description"`
 }
 
+// Validate implements the web.Validator interface.
+func (req CreateNodeRequest) Validate() error {
+	var fields web.FieldErrors
+	fields.Check("name", validate.Required(req.Name), validate.MaxLength(req.Name, validate.MaxVarchar))
+
+	return fields.Err()
+}
+
 // CreateNodeResponse is the type that represents the response body for *Server.CreateNode.
 type CreateNodeResponse struct {
 	Name        string `json:"name"`
@@ -26,14 +35,14 @@ type CreateNodeResponse struct {
 // CreateNode creates a node on the lorafication server.
 func (s *Server) CreateNode(w http.ResponseWriter, r *http.Request) {
 	var reqData CreateNodeRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("decode request body: %w", err))
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
 		return
 	}
 
 	n, err := node.CreateNode(r.Context(), s.dbc, reqData.Name, reqData.Description)
 	if err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("create node: %w", err))
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("create node: %w", translateError(err)))
 		return
 	}
 
@@ -43,5 +52,57 @@ func (s *Server) CreateNode(w http.ResponseWriter, r *http.Request) {
 		PublicKey:   n.PublicKey,
 		Secret:      n.Secret,
 	}
-	web.Respond(w, r, s.logger, http.StatusCreated, resData)
+	web.Respond(w, r, http.StatusCreated, resData)
+}
+
+// UpdateNodeSenderRequest is the type that represents the request body for
+// *Server.UpdateNodeSender. Empty fields fall back to the daemon's sender.
+type UpdateNodeSenderRequest struct {
+	SenderAddress string `json:"senderAddress"`
+	SenderName    string `json:"senderName"`
+	ReplyTo       string `json:"replyTo"`
+}
+
+// Validate implements the web.Validator interface.
+func (req UpdateNodeSenderRequest) Validate() error {
+	var fields web.FieldErrors
+
+	if req.SenderAddress != "" {
+		fields.Check("senderAddress", validate.Email(req.SenderAddress), validate.MaxLength(req.SenderAddress, validate.MaxVarchar))
+	}
+	fields.Check("senderName", validate.MaxLength(req.SenderName, validate.MaxVarchar))
+	if req.ReplyTo != "" {
+		fields.Check("replyTo", validate.Email(req.ReplyTo), validate.MaxLength(req.ReplyTo, validate.MaxVarchar))
+	}
+
+	return fields.Err()
+}
+
+// UpdateNodeSender sets who the node whose public key is within the path sends its
+// notifications from. As the sender address must be one the SMTP server is allowed to
+// send from, only admins may set it.
+func (s *Server) UpdateNodeSender(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to set a node's sender", nil))
+		return
+	}
+
+	key := httprouter.ParamsFromContext(r.Context()).ByName("publicKey")
+	if err := validate.UUID(key); err != nil {
+		web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("node not found", err))
+		return
+	}
+
+	var reqData UpdateNodeSenderRequest
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	if err := node.UpdateSender(r.Context(), s.dbc, key, reqData.SenderAddress, reqData.SenderName, reqData.ReplyTo); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("update node sender: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusNoContent, nil)
 }
@@ -2,14 +2,22 @@ package server
 
 import (
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/22arw/lorafication/cmd/loraficationd/contract"
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
 	"github.com/22arw/lorafication/cmd/loraficationd/node"
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/internal/platform/db"
+	"github.com/22arw/lorafication/internal/platform/validate"
 	"github.com/22arw/lorafication/internal/platform/web"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
 )
 
 // NotifyRequest is a representation of the request body for the *Server.Notify handler.
@@ -17,48 +25,208 @@ type NotifyRequest struct {
 	PublicKey string `json:"publicKey"` // PublicKey corresponds to a node public key (primary key of a node).
 	Secret    string `json:"secret"`    // Secret corresponds to the secret stored in the same row^.
 	Message   string `json:"message"`
+
+	// Severity is the severity of the notification, one of info, warning, critical or a
+	// custom severity, defaulting to info. Contracts may route notifications by it.
+	Severity string `json:"severity"`
+
+	// Payload is optional structured data that templates may refer to as {{.Payload}}.
+	Payload map[string]interface{} `json:"payload"`
 }
 
-// Notify notifies all entities subscribed to a node using the provided message.
+// Validate implements the web.Validator interface.
+func (req NotifyRequest) Validate() error {
+	var fields web.FieldErrors
+	fields.Check("publicKey", validate.Required(req.PublicKey))
+	fields.Check("secret", validate.Required(req.Secret))
+	fields.Check("message", validate.Required(req.Message))
+	fields.Check("severity", validate.MaxLength(req.Severity, maxSeverityLength))
+
+	return fields.Err()
+}
+
+// maxSeverityLength is the maximum length of the severity of a notification, as stored in
+// the notification table.
+const maxSeverityLength = 32
+
+// NotifyResponse is the type that represents the response body for *Server.Notify.
+type NotifyResponse struct {
+	NotificationID int `json:"notificationID"`
+	Deliveries     int `json:"deliveries"`
+}
+
+// Notify notifies all entities subscribed to a node using the provided message. The
+// notification is queued for delivery to every contact point that the contracts of the
+// node route it to and that a channel delivers to, and is delivered in the background, its
+// deliveries being retried until they succeed or run out of attempts.
 func (s *Server) Notify(w http.ResponseWriter, r *http.Request) {
 	var reqData NotifyRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqData); err != nil {
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("decode request body: %w", err))
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
 		return
 	}
 
 	n, err := node.AuthenticateNode(r.Context(), s.dbc, reqData.PublicKey, reqData.Secret)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if errors.Is(err, sql.ErrNoRows) {
-			statusCode = http.StatusUnauthorized
+		// Malformed public keys or secrets fail to be parsed as UUIDs by the database,
+		// which is just as unauthorized as ones that don't match a node.
+		if errors.Is(err, sql.ErrNoRows) || db.Classify(err) != nil {
+			err = web.NewUnauthorizedError("invalid public key or secret", err)
 		}
 
-		web.RespondError(w, r, s.logger, statusCode, fmt.Errorf("resolve node from public key and secret: %w", err))
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("resolve node from public key and secret: %w", err))
 		return
 	}
 
-	contracts, err := contract.ResolveContracts(r.Context(), s.dbc, reqData.PublicKey)
+	web.AddLogFields(r.Context(), zap.String("nodePublicKey", n.PublicKey))
+
+	severities, err := severity.ListSeverities(r.Context(), s.dbc)
 	if err != nil {
-		statusCode := http.StatusInternalServerError
-		if errors.Is(err, sql.ErrNoRows) {
-			statusCode = http.StatusNotFound
-		}
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("list severities: %w", err))
+		return
+	}
 
-		web.RespondError(w, r, s.logger, statusCode, fmt.Errorf("resolve contracts from node id: %w", err))
+	name := strings.ToLower(reqData.Severity)
+	if name == "" {
+		name = severity.Default
+	}
+
+	var fields web.FieldErrors
+	checkSeverity(&fields, "severity", severities, name)
+	if err := fields.Err(); err != nil {
+		web.RespondError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	sev, _ := severities.Find(name)
+
+	web.AddLogFields(r.Context(), zap.String("severity", sev.Name))
+	s.metrics.IncNotifications(n.PublicKey, sev.Name)
+
+	contracts, err := contract.ResolveContracts(r.Context(), s.dbc, reqData.PublicKey, sev, time.Now())
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("resolve contracts from node id: %w", translateError(err)))
 		return
 	}
 
-	subject := fmt.Sprintf("LoRafication: Notification from %s Node", n.Name)
-	for i := range contracts {
-		if contracts[i].Email != nil {
-			if err := s.mailer.Send(*contracts[i].Email, subject, reqData.Message); err != nil {
-				// TODO: This should have a failsafe mechanism
-				web.RespondError(w, r, s.logger, http.StatusInternalServerError, fmt.Errorf("send notification: %w", err))
-				return
-			}
+	notification := delivery.Notification{
+		NodePublicKey: n.PublicKey,
+		Message:       reqData.Message,
+		Severity:      sev.Name,
+		Payload:       reqData.Payload,
+	}
+	for _, c := range contracts {
+		// Contact points that no channel delivers to, such as sms ones, are skipped.
+		if s.deliveries.Supports(c.Type) {
+			notification.Deliveries = append(notification.Deliveries, delivery.Delivery{
+				ContractID:     c.ContractID,
+				ContactPointID: c.ContactPointID,
+				Channel:        c.Type,
+			})
 		}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	created, err := delivery.CreateNotification(r.Context(), s.dbc, notification)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("create notification: %w", translateError(err)))
+		return
+	}
+	s.deliveries.Wake()
+
+	web.AddLogFields(r.Context(), zap.Int("notificationID", created.ID))
+	web.Respond(w, r, http.StatusAccepted, NotifyResponse{
+		NotificationID: created.ID,
+		Deliveries:     len(created.Deliveries),
+	})
+}
+
+// Delivery is the type that represents a delivery of a notification within response
+// bodies.
+type Delivery struct {
+	ID             int        `json:"id"`
+	ContractID     int        `json:"contractID"`
+	ContactPointID int        `json:"contactPointID"`
+	Channel        string     `json:"channel"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"lastError,omitempty"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty"`
+	Created        time.Time  `json:"created"`
+	Modified       time.Time  `json:"modified"`
+}
+
+// GetNotificationResponse is the type that represents the response body for
+// *Server.GetNotification.
+type GetNotificationResponse struct {
+	ID            int                    `json:"id"`
+	NodePublicKey string                 `json:"nodePublicKey"`
+	Message       string                 `json:"message"`
+	Severity      string                 `json:"severity,omitempty"`
+	Payload       map[string]interface{} `json:"payload,omitempty"`
+	Created       time.Time              `json:"created"`
+
+	// AcknowledgedAt and AcknowledgedBy, the ID of the contact point that acknowledged the
+	// notification, are only set once it has been acknowledged.
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy *int       `json:"acknowledgedBy,omitempty"`
+
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// GetNotification responds with the notification whose ID is within the path along with
+// the log of its deliveries: their status, how many times they've been attempted and why
+// the last attempt failed, along with who acknowledged it. Only admins may get
+// notifications.
+func (s *Server) GetNotification(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to get a notification", nil))
+		return
+	}
+
+	id, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	if err != nil {
+		web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("notification not found", err))
+		return
+	}
+
+	n, err := delivery.GetNotification(r.Context(), s.dbc, id)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("get notification: %w", translateError(err)))
+		return
+	}
+
+	resData := GetNotificationResponse{
+		ID:             n.ID,
+		NodePublicKey:  n.NodePublicKey,
+		Message:        n.Message,
+		Severity:       n.Severity,
+		Payload:        n.Payload,
+		Created:        n.Created,
+		AcknowledgedAt: n.AcknowledgedAt,
+		AcknowledgedBy: n.AcknowledgedBy,
+		Deliveries:     make([]Delivery, 0, len(n.Deliveries)),
+	}
+	for _, d := range n.Deliveries {
+		resD := Delivery{
+			ID:             d.ID,
+			ContractID:     d.ContractID,
+			ContactPointID: d.ContactPointID,
+			Channel:        d.Channel,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastError:      d.LastError,
+			DeliveredAt:    d.DeliveredAt,
+			Created:        d.Created,
+			Modified:       d.Modified,
+		}
+
+		// The next attempt is only meaningful while the delivery is pending.
+		if d.Status == delivery.StatusPending {
+			nextAttemptAt := d.NextAttemptAt
+			resD.NextAttemptAt = &nextAttemptAt
+		}
+
+		resData.Deliveries = append(resData.Deliveries, resD)
+	}
+	web.Respond(w, r, http.StatusOK, resData)
 }
@@ -2,12 +2,19 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"runtime"
 
+	"github.com/22arw/lorafication/cmd/loraficationd/bounce"
 	"github.com/22arw/lorafication/cmd/loraficationd/config"
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/internal/channel"
 	"github.com/22arw/lorafication/internal/mail"
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/22arw/lorafication/internal/platform/token"
 	"github.com/22arw/lorafication/internal/platform/web"
 	"github.com/jmoiron/sqlx"
 	"github.com/julienschmidt/httprouter"
@@ -16,48 +23,154 @@ import (
 
 // Server implements http.Handler and handles incoming HTTP connections.
 type Server struct {
-	config *config.Config
-	logger *zap.Logger
-	dbc    *sqlx.DB
-	mailer *mail.Mailer
+	config     *config.Config
+	logger     *zap.Logger
+	dbc        *sqlx.DB
+	mailer     *mail.Mailer
+	metrics    *metrics.Metrics
+	signer     *token.Signer
+	bounces    *bounce.Processor
+	deliveries *delivery.Dispatcher
+
+	// The providers that verification codes are sent to phone contact points through,
+	// each being nil unless it's configured.
+	texter channel.Texter
+	caller channel.VoiceProvider
+
+	// telegram answers the callback queries of the bot's acknowledge buttons, being nil
+	// unless the bot is configured.
+	telegram *channel.Telegram
 
 	http.Handler
 }
 
 // NewServer returns a reference to a Server type with the fields and handlers properly
-// set.
-func NewServer(cfg *config.Config, logger *zap.Logger, dbc *sqlx.DB, mailer *mail.Mailer) *Server {
+// set, recording the bounces posted to it through the given processor.
+func NewServer(cfg *config.Config, logger *zap.Logger, dbc *sqlx.DB, mailer *mail.Mailer, m *metrics.Metrics, bounces *bounce.Processor) *Server {
 	s := Server{
-		config: cfg,
-		logger: logger,
-		dbc:    dbc,
-		mailer: mailer,
+		config:  cfg,
+		logger:  logger,
+		dbc:     dbc,
+		mailer:  mailer,
+		metrics: m,
+		signer:  token.NewSigner([]byte(cfg.SigningKey)),
+		bounces: bounces,
 	}
 
+	// Notifications are delivered through the channel for the type of each contact point.
+	channels := map[string]channel.Channel{
+		entity.ContactTypeEmail:      channel.NewEmail(mailer),
+		entity.ContactTypeWebhook:    channel.NewWebhook(cfg.WebhookTimeout.Duration),
+		entity.ContactTypeSlack:      channel.NewSlack(cfg.WebhookTimeout.Duration),
+		entity.ContactTypeTeams:      channel.NewTeams(cfg.WebhookTimeout.Duration),
+		entity.ContactTypeMattermost: channel.NewMattermost(cfg.WebhookTimeout.Duration),
+		entity.ContactTypeNtfy:       channel.NewNtfy(cfg.WebhookTimeout.Duration),
+		entity.ContactTypeGotify:     channel.NewGotify(cfg.WebhookTimeout.Duration),
+		entity.ContactTypePushover: channel.NewPushover(channel.PushoverConfig{
+			APIURL:   cfg.PushoverAPIURL,
+			AppToken: cfg.PushoverAppToken,
+			Retry:    cfg.PushoverRetry.Duration,
+			Expire:   cfg.PushoverExpire.Duration,
+		}, cfg.WebhookTimeout.Duration),
+	}
+
+	// Bots are only delivered through once they've been configured, so that contact points
+	// of their type aren't queued otherwise.
+	if cfg.TelegramBotToken != "" {
+		s.telegram = channel.NewTelegram(channel.TelegramConfig{
+			APIURL:    cfg.TelegramAPIURL,
+			BotToken:  cfg.TelegramBotToken,
+			Callbacks: cfg.TelegramWebhookSecret != "",
+		}, cfg.WebhookTimeout.Duration)
+		channels[entity.ContactTypeTelegram] = s.telegram
+	}
+
+	if cfg.MatrixHomeserverURL != "" {
+		channels[entity.ContactTypeMatrix] = channel.NewMatrix(cfg.MatrixHomeserverURL, cfg.MatrixAccessToken, cfg.WebhookTimeout.Duration)
+	}
+
+	// Twilio also texts the codes that verify sms contact points, which a bridge can't.
+	switch cfg.VoiceProvider {
+	case channel.VoiceProviderTwilio:
+		twilio := channel.NewTwilio(channel.TwilioConfig{
+			APIURL:     cfg.VoiceTwilioAPIURL,
+			AccountSID: cfg.VoiceTwilioAccountSID,
+			AuthToken:  cfg.VoiceTwilioAuthToken,
+			From:       cfg.VoiceTwilioFrom,
+		}, cfg.WebhookTimeout.Duration)
+		s.texter, s.caller = twilio, twilio
+	case channel.VoiceProviderBridge:
+		s.caller = channel.NewVoiceBridge(cfg.VoiceBridgeURL, cfg.WebhookTimeout.Duration)
+	}
+
+	if s.caller != nil {
+		channels[entity.ContactTypeVoice] = channel.NewVoice(s.caller)
+	}
+	links := delivery.Links{
+		Unsubscribe: s.unsubscribeURL,
+		Acknowledge: s.acknowledgeURL,
+	}
+	s.deliveries = delivery.NewDispatcher(dbc, logger, m, channels, links, delivery.Config{
+		MaxAttempts: cfg.DeliveryMaxAttempts,
+		Backoff:     cfg.DeliveryBackoff.Duration,
+		Interval:    cfg.DeliveryInterval.Duration,
+	})
+
 	r := httprouter.New()
 
 	// Boilerplate Routes
 	s.boilerplate(r)
 
 	// Entity Routes
-	r.HandlerFunc(http.MethodPost, "/entity", s.CreateEntity)
+	s.handle(r, http.MethodPost, "/entity", s.CreateEntity)
+	s.handle(r, http.MethodGet, "/entity/:id", s.GetEntity)
+	s.handle(r, http.MethodGet, "/entity/:id/verify", s.VerifyContactPoint)
+	s.handle(r, http.MethodPost, "/entity/:id/verify", s.VerifyContactPoint)
 
 	// Node Routes
-	r.HandlerFunc(http.MethodPost, "/node", s.CreateNode)
+	s.handle(r, http.MethodPost, "/node", s.CreateNode)
+	s.handle(r, http.MethodPut, "/node/:publicKey/sender", s.UpdateNodeSender)
+
+	// Template Routes
+	s.handle(r, http.MethodPut, "/templates", s.PutTemplate)
+	s.handle(r, http.MethodPut, "/templates/:publicKey", s.PutTemplate)
+	s.handle(r, http.MethodPost, "/templates/preview", s.PreviewTemplate)
 
 	// Node/Entity Contract Routes
-	r.HandlerFunc(http.MethodPost, "/contract", s.CreateContract)
+	s.handle(r, http.MethodPost, "/contract", s.CreateContract)
+	s.handle(r, http.MethodPut, "/contract/:id/routing", s.UpdateRouting)
+	s.handle(r, http.MethodGet, "/unsubscribe", s.Unsubscribe)
+	s.handle(r, http.MethodPost, "/unsubscribe", s.Unsubscribe)
+
+	// Bounce Routes
+	s.handle(r, http.MethodPost, "/bounces", s.RecordBounces)
 
 	// Notification Routes
-	r.HandlerFunc(http.MethodPost, "/notify", s.Notify)
+	s.handle(r, http.MethodGet, "/severities", s.ListSeverities)
+	s.handle(r, http.MethodPost, "/severities", s.CreateSeverity)
+	s.handle(r, http.MethodPost, "/notify", s.Notify)
+	s.handle(r, http.MethodGet, "/notifications/:id", s.GetNotification)
+	s.handle(r, http.MethodGet, "/acknowledge", s.Acknowledge)
+	s.handle(r, http.MethodPost, "/acknowledge", s.Acknowledge)
+
+	// Bot Routes
+	if s.telegram != nil && cfg.TelegramWebhookSecret != "" {
+		s.handle(r, http.MethodPost, "/telegram/webhook", s.TelegramWebhook)
+	}
 
-	// Wrap handler in middleware that handles logging and verification of the
-	// RequestID.
-	s.Handler = web.RequestMW(logger, r)
+	// Wrap handler in middleware that handles logging, metrics and verification of
+	// the RequestID.
+	s.Handler = web.RequestMW(logger, m, r)
 
 	return &s
 }
 
+// RunDeliveries delivers the notifications received by the Server in the background,
+// retrying deliveries that fail, until ctx is done.
+func (s *Server) RunDeliveries(ctx context.Context) {
+	s.deliveries.Run(ctx)
+}
+
 // boilerplate initializes boilerplate routes on the given router for things like panic handling,
 // kubernetes probes, etc.
 func (s *Server) boilerplate(r *httprouter.Router) {
@@ -66,20 +179,20 @@ func (s *Server) boilerplate(r *httprouter.Router) {
 		stack := make([]byte, 4096)
 		stack = stack[:runtime.Stack(stack, false)]
 
-		s.logger.Error("captured http panic",
+		web.Logger(r.Context()).Error("captured http panic",
 			zap.Reflect("panic", i), zap.String("stack", string(stack)))
 
-		web.RespondError(w, r, s.logger, http.StatusInternalServerError,
+		web.RespondError(w, r, http.StatusInternalServerError,
 			errors.New(http.StatusText(http.StatusInternalServerError)))
 	}
 
 	// Not found (404) handler being overridden for logging purposes. This helps distinguish 404s
 	// due to resources not being found from unregistered routes being reached in terms of logging.
 	r.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s.logger.Error("unregistered route attempting to be reached",
-			zap.String("requestURI", r.RequestURI))
+		web.Logger(r.Context()).Error("unregistered route attempting to be reached",
+			zap.String("requestURI", web.RedactedURI(r)))
 
-		web.RespondError(w, r, s.logger, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		web.RespondError(w, r, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
 	})
 
 	// probeHandler is for kubernetes probes.
@@ -99,6 +212,18 @@ func (s *Server) boilerplate(r *httprouter.Router) {
 	}
 
 	// Routes for Kubernetes probes.
-	r.HandlerFunc(http.MethodGet, "/ready", probeHandler)
-	r.HandlerFunc(http.MethodGet, "/healthy", probeHandler)
+	s.handle(r, http.MethodGet, "/ready", probeHandler)
+	s.handle(r, http.MethodGet, "/healthy", probeHandler)
+
+	// Metrics are only served alongside the API when they aren't configured to be served
+	// on a separate admin port.
+	if s.config.MetricsPort == 0 {
+		s.handle(r, http.MethodGet, "/metrics", s.metrics.Handler().ServeHTTP)
+	}
+}
+
+// handle registers a handler on the router under the given method and path, recording
+// the path as the route of the request for logging and metrics purposes.
+func (s *Server) handle(r *httprouter.Router, method, path string, h http.HandlerFunc) {
+	r.Handler(method, path, web.Route(path, h))
 }
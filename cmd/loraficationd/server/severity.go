@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/internal/platform/validate"
+	"github.com/22arw/lorafication/internal/platform/web"
+)
+
+// checkSeverity records a field error against the given field unless the given name is
+// that of one of the given severities.
+func checkSeverity(fields *web.FieldErrors, field string, severities severity.Severities, name string) {
+	if _, ok := severities.Find(name); !ok {
+		fields.Check(field, fmt.Errorf("must be one of %v", severities.Names()))
+	}
+}
+
+// Severity is the type that represents a severity within response bodies.
+type Severity struct {
+	Name    string    `json:"name"`
+	Level   int       `json:"level"`
+	Created time.Time `json:"created"`
+}
+
+// ListSeveritiesResponse is the type that represents the response body for
+// *Server.ListSeverities.
+type ListSeveritiesResponse struct {
+	Severities []Severity `json:"severities"`
+}
+
+// ListSeverities lists every severity that notifications may be sent with, ordered by
+// level from the least severe.
+func (s *Server) ListSeverities(w http.ResponseWriter, r *http.Request) {
+	severities, err := severity.ListSeverities(r.Context(), s.dbc)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("list severities: %w", err))
+		return
+	}
+
+	resData := ListSeveritiesResponse{Severities: make([]Severity, 0, len(severities))}
+	for _, sev := range severities {
+		resData.Severities = append(resData.Severities, Severity(sev))
+	}
+	web.Respond(w, r, http.StatusOK, resData)
+}
+
+// CreateSeverityRequest is the type that represents the request body for
+// *Server.CreateSeverity.
+type CreateSeverityRequest struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+// Validate implements the web.Validator interface.
+func (req CreateSeverityRequest) Validate() error {
+	var fields web.FieldErrors
+
+	var lowercase error
+	if req.Name != strings.ToLower(req.Name) {
+		lowercase = errors.New("must be lowercase")
+	}
+	fields.Check("name", validate.Required(req.Name), validate.MaxLength(req.Name, maxSeverityLength), lowercase)
+	fields.Check("level", validate.Positive(req.Level))
+
+	return fields.Err()
+}
+
+// CreateSeverity creates a custom severity, ranked between or beyond the built-in
+// severities by its level. Only admins may create severities.
+func (s *Server) CreateSeverity(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to create severities", nil))
+		return
+	}
+
+	var reqData CreateSeverityRequest
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	sev, err := severity.CreateSeverity(r.Context(), s.dbc, reqData.Name, reqData.Level)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("create severity: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusCreated, Severity(*sev))
+}
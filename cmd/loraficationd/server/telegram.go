@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/delivery"
+	"github.com/22arw/lorafication/cmd/loraficationd/entity"
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/platform/web"
+	"go.uber.org/zap"
+)
+
+// telegramSecretHeader is the header that Telegram sends the secret token of the bot's
+// webhook within.
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// TelegramUpdateRequest is the type that represents the request body for
+// *Server.TelegramWebhook, being the fields of a Telegram update that the daemon uses.
+type TelegramUpdateRequest struct {
+	UpdateID      int                    `json:"update_id"`
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query"`
+}
+
+// TelegramCallbackQuery is the callback query sent when a button of a message is pressed,
+// along with the chat of the message.
+type TelegramCallbackQuery struct {
+	ID      string `json:"id"`
+	Data    string `json:"data"`
+	Message *struct {
+		Chat struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// TelegramWebhook receives the updates of the Telegram bot, acknowledging notifications
+// when their Acknowledge button is pressed and answering the callback query with whether
+// or not it was. Only updates carrying the secret token of the webhook are accepted, and
+// a notification is only acknowledged from the chat that it was delivered to. Other
+// updates are ignored, as Telegram retries those that aren't responded to with a 2xx.
+func (s *Server) TelegramWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(telegramSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(s.config.TelegramWebhookSecret)) != 1 {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("invalid webhook secret", nil))
+		return
+	}
+
+	// Updates hold many more fields than those used, so they aren't decoded strictly.
+	var reqData TelegramUpdateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, web.MaxBodyBytes)).Decode(&reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, web.NewBadRequestError(fmt.Errorf("decode request body: %w", err)))
+		return
+	}
+
+	cq := reqData.CallbackQuery
+	if cq == nil || cq.Message == nil {
+		web.Respond(w, r, http.StatusNoContent, nil)
+		return
+	}
+
+	deliveryID, ok := channel.ParseTelegramCallback(cq.Data)
+	if !ok {
+		web.Respond(w, r, http.StatusNoContent, nil)
+		return
+	}
+
+	web.AddLogFields(r.Context(), zap.Int("deliveryID", deliveryID), zap.Int64("chatID", cq.Message.Chat.ID))
+
+	// Channels may be addressed by their username rather than their ID.
+	addresses := []string{strconv.FormatInt(cq.Message.Chat.ID, 10)}
+	if cq.Message.Chat.Username != "" {
+		addresses = append(addresses, "@"+cq.Message.Chat.Username)
+	}
+
+	answer := "The alert has been acknowledged."
+	n, err := delivery.AcknowledgeNotificationFrom(r.Context(), s.dbc, deliveryID, entity.ContactTypeTelegram, addresses)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		answer = "This alert can't be acknowledged from this chat."
+	case err != nil:
+		web.Logger(r.Context()).Error("acknowledge notification", zap.Error(err))
+		answer = "The alert could not be acknowledged."
+	default:
+		web.AddLogFields(r.Context(), zap.Int("notificationID", n.ID))
+	}
+
+	if err := s.telegram.AnswerCallback(r.Context(), cq.ID, answer); err != nil {
+		web.Logger(r.Context()).Warn("answer telegram callback", zap.Error(err))
+	}
+
+	web.Respond(w, r, http.StatusNoContent, nil)
+}
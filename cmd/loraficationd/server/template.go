@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/node"
+	"github.com/22arw/lorafication/cmd/loraficationd/severity"
+	"github.com/22arw/lorafication/cmd/loraficationd/template"
+	"github.com/22arw/lorafication/internal/platform/validate"
+	"github.com/22arw/lorafication/internal/platform/web"
+	"github.com/julienschmidt/httprouter"
+)
+
+// exampleNode is the node that templates are previewed with when no node is given.
+var exampleNode = template.Node{
+	PublicKey:   "00000000-0000-0000-0000-000000000000",
+	Name:        "Example",
+	Description: "An example node used to preview templates.",
+}
+
+// checkTemplates records a field error for each template of the set that fails to parse,
+// prefixing the field names with prefix.
+func checkTemplates(fields *web.FieldErrors, prefix string, set template.Set) {
+	for field, err := range set.Parse() {
+		fields.Check(prefix+field, err)
+	}
+}
+
+// PutTemplateRequest is the type that represents the request body for *Server.PutTemplate.
+// Empty templates fall back to the organisation default template and then to the built-in
+// defaults.
+type PutTemplateRequest struct {
+	template.Set
+}
+
+// Validate implements the web.Validator interface.
+func (req PutTemplateRequest) Validate() error {
+	var fields web.FieldErrors
+	checkTemplates(&fields, "", req.Set)
+
+	return fields.Err()
+}
+
+// PutTemplate creates or replaces the notification templates of the node whose public key
+// is within the path, or the organisation default templates if there is none.
+func (s *Server) PutTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to manage templates", nil))
+		return
+	}
+
+	var nodePublicKey *string
+	if key := httprouter.ParamsFromContext(r.Context()).ByName("publicKey"); key != "" {
+		if err := validate.UUID(key); err != nil {
+			web.RespondError(w, r, http.StatusNotFound, web.NewNotFoundError("node not found", err))
+			return
+		}
+		nodePublicKey = &key
+	}
+
+	var reqData PutTemplateRequest
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	if err := template.UpsertTemplate(r.Context(), s.dbc, nodePublicKey, reqData.Set); err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("upsert template: %w", translateError(err)))
+		return
+	}
+
+	web.Respond(w, r, http.StatusNoContent, nil)
+}
+
+// PreviewTemplateRequest is the type that represents the request body for
+// *Server.PreviewTemplate. The given templates take precedence over those stored for the
+// node, if one is given.
+type PreviewTemplateRequest struct {
+	NodePublicKey string                 `json:"nodePublicKey"`
+	Template      template.Set           `json:"template"`
+	Message       string                 `json:"message"`
+	Severity      string                 `json:"severity"`
+	Payload       map[string]interface{} `json:"payload"`
+}
+
+// Validate implements the web.Validator interface.
+func (req PreviewTemplateRequest) Validate() error {
+	var fields web.FieldErrors
+
+	if req.NodePublicKey != "" {
+		fields.Check("nodePublicKey", validate.UUID(req.NodePublicKey))
+	}
+	checkTemplates(&fields, "template.", req.Template)
+
+	return fields.Err()
+}
+
+// PreviewTemplate renders a notification as it would be sent for the given node, message,
+// severity and payload, without sending it. The severity defaults to info.
+func (s *Server) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		web.RespondError(w, r, http.StatusUnauthorized, web.NewUnauthorizedError("an admin token is required to manage templates", nil))
+		return
+	}
+
+	var reqData PreviewTemplateRequest
+	if err := web.Decode(w, r, &reqData); err != nil {
+		web.RespondError(w, r, http.StatusBadRequest, fmt.Errorf("decode request body: %w", err))
+		return
+	}
+
+	data := template.Data{
+		Node:      exampleNode,
+		Message:   reqData.Message,
+		Severity:  strings.ToLower(reqData.Severity),
+		Timestamp: time.Now(),
+		Payload:   reqData.Payload,
+	}
+
+	if reqData.NodePublicKey != "" {
+		n, err := node.GetNode(r.Context(), s.dbc, reqData.NodePublicKey)
+		if err != nil {
+			web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("get node: %w", translateError(err)))
+			return
+		}
+		data.Node = templateNode(n)
+	}
+
+	if data.Severity == "" {
+		data.Severity = severity.Default
+	}
+
+	set, err := template.ResolveSet(r.Context(), s.dbc, reqData.NodePublicKey)
+	if err != nil {
+		web.RespondError(w, r, http.StatusInternalServerError, fmt.Errorf("resolve templates: %w", err))
+		return
+	}
+
+	rendered, err := reqData.Template.Merge(set).Render(data)
+	if err != nil {
+		web.RespondError(w, r, http.StatusUnprocessableEntity, web.NewValidationError("unable to render templates", err,
+			web.FieldError{Field: "template", Message: err.Error()}))
+		return
+	}
+
+	web.Respond(w, r, http.StatusOK, rendered)
+}
+
+// templateNode returns the metadata of the given node that templates are rendered with.
+func templateNode(n *node.Node) template.Node {
+	return template.Node{
+		PublicKey:   n.PublicKey,
+		Name:        n.Name,
+		Description: n.Description,
+	}
+}
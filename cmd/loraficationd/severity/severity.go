@@ -0,0 +1,81 @@
+// Package severity interfaces between the severity table in the database and the
+// lorafication daemon.
+package severity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/jmoiron/sqlx"
+)
+
+// Built-in severities, which the schema creates. Custom severities are ranked between or
+// beyond them by their level.
+const (
+	Info     = "info"
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+// Default is the severity of notifications that don't give one.
+const Default = Info
+
+// Severity is a struct representing the structure of a row in the severity table of the
+// database. Severities are ranked by their level, a higher level being more severe.
+type Severity struct {
+	Name    string    `db:"name"`
+	Level   int       `db:"level"`
+	Created time.Time `db:"created"`
+}
+
+// Severities is a list of severities ordered by their level.
+type Severities []Severity
+
+// Find returns the severity with the given name and whether or not there is one.
+func (s Severities) Find(name string) (Severity, bool) {
+	for _, sev := range s {
+		if sev.Name == name {
+			return sev, true
+		}
+	}
+
+	return Severity{}, false
+}
+
+// Names returns the names of the severities.
+func (s Severities) Names() []string {
+	names := make([]string, 0, len(s))
+	for _, sev := range s {
+		names = append(names, sev.Name)
+	}
+
+	return names
+}
+
+// ListSeverities returns every severity, ordered by level from the least severe.
+func ListSeverities(ctx context.Context, dbc *sqlx.DB) (_ Severities, err error) {
+	ctx, span := tracing.Start(ctx, "severity.ListSeverities")
+	defer func() { tracing.End(span, err) }()
+
+	var severities Severities
+	if err := dbc.SelectContext(ctx, &severities, "SELECT * FROM severity ORDER BY level;"); err != nil {
+		return nil, fmt.Errorf("retrieve severities: %w", err)
+	}
+
+	return severities, nil
+}
+
+// CreateSeverity creates a custom severity with the given name and level.
+func CreateSeverity(ctx context.Context, dbc *sqlx.DB, name string, level int) (_ *Severity, err error) {
+	ctx, span := tracing.Start(ctx, "severity.CreateSeverity")
+	defer func() { tracing.End(span, err) }()
+
+	var sev Severity
+	if err := dbc.GetContext(ctx, &sev, "INSERT INTO severity (name, level) VALUES ($1, $2) RETURNING *;", name, level); err != nil {
+		return nil, fmt.Errorf("insert severity: %w", err)
+	}
+
+	return &sev, nil
+}
@@ -0,0 +1,223 @@
+// Package template interfaces between the template table in the database and the
+// lorafication daemon, and renders the notifications sent for a node from its templates.
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/jmoiron/sqlx"
+)
+
+// Template is a struct representing the structure of a row in the template table of the
+// database. A template without a node public key is the organisation default, used for
+// every node without a template of its own. Empty fields fall back to the organisation
+// default and then to DefaultSet.
+type Template struct {
+	ID            int       `db:"id"`
+	NodePublicKey *string   `db:"node_public_key"`
+	Subject       string    `db:"subject"`
+	HTMLBody      string    `db:"html_body"`
+	TextBody      string    `db:"text_body"`
+	SMSText       string    `db:"sms_text"`
+	Created       time.Time `db:"created"`
+	Modified      time.Time `db:"modified"`
+}
+
+// Set is the set of template sources that a notification is rendered from, one for each
+// part of the notification. The subject, plain-text body and SMS text are text/template
+// sources while the HTML body is a html/template source, escaping the data it's rendered
+// with.
+type Set struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"htmlBody"`
+	TextBody string `json:"textBody"`
+	SMSText  string `json:"smsText"`
+}
+
+// DefaultSet is the set of templates used for every field that neither a node nor the
+// organisation default template sets.
+var DefaultSet = Set{
+	Subject:  "[{{.Severity}}] LoRafication: Notification from {{.Node.Name}} Node",
+	HTMLBody: "<p>{{.Message}}</p>",
+	TextBody: "{{.Message}}",
+	SMSText:  "{{.Node.Name}} ({{.Severity}}): {{.Message}}",
+}
+
+// Node is the node metadata that templates are rendered with.
+type Node struct {
+	PublicKey   string
+	Name        string
+	Description string
+}
+
+// Data is the data that templates are rendered with. Severity is the name of the severity
+// of the notification, such as critical.
+type Data struct {
+	Node      Node
+	Message   string
+	Severity  string
+	Timestamp time.Time
+	Payload   map[string]interface{}
+}
+
+// Rendered is a notification rendered from a Set.
+type Rendered struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+	SMS     string `json:"sms"`
+}
+
+// Merge returns a copy of the receiver with its empty fields filled in from fallback.
+func (s Set) Merge(fallback Set) Set {
+	if s.Subject == "" {
+		s.Subject = fallback.Subject
+	}
+
+	if s.HTMLBody == "" {
+		s.HTMLBody = fallback.HTMLBody
+	}
+
+	if s.TextBody == "" {
+		s.TextBody = fallback.TextBody
+	}
+
+	if s.SMSText == "" {
+		s.SMSText = fallback.SMSText
+	}
+
+	return s
+}
+
+// Parse parses every template of the set, returning the errors of those that fail to
+// parse keyed by the JSON name of their field.
+func (s Set) Parse() map[string]error {
+	errs := make(map[string]error)
+
+	for field, src := range map[string]string{"subject": s.Subject, "textBody": s.TextBody, "smsText": s.SMSText} {
+		if _, err := texttemplate.New(field).Option("missingkey=zero").Parse(src); err != nil {
+			errs[field] = err
+		}
+	}
+
+	if _, err := htmltemplate.New("htmlBody").Option("missingkey=zero").Parse(s.HTMLBody); err != nil {
+		errs["htmlBody"] = err
+	}
+
+	return errs
+}
+
+// Render renders every template of the set with the given data. Line breaks are removed
+// from the rendered subject so that it can't spill into other email headers.
+func (s Set) Render(data Data) (Rendered, error) {
+	var rendered Rendered
+	var err error
+
+	if rendered.Subject, err = renderText("subject", s.Subject, data); err != nil {
+		return Rendered{}, err
+	}
+	rendered.Subject = strings.Join(strings.Fields(rendered.Subject), " ")
+
+	if rendered.Text, err = renderText("textBody", s.TextBody, data); err != nil {
+		return Rendered{}, err
+	}
+
+	if rendered.SMS, err = renderText("smsText", s.SMSText, data); err != nil {
+		return Rendered{}, err
+	}
+
+	t, err := htmltemplate.New("htmlBody").Option("missingkey=zero").Parse(s.HTMLBody)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("parse htmlBody template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return Rendered{}, fmt.Errorf("execute htmlBody template: %w", err)
+	}
+	rendered.HTML = buf.String()
+
+	return rendered, nil
+}
+
+// renderText parses and executes a text/template source with the given data.
+func renderText(name, src string, data Data) (string, error) {
+	t, err := texttemplate.New(name).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute %s template: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// ResolveSet returns the set of templates that notifications of the node with the given
+// public key are rendered from, falling back from the node's template to the organisation
+// default template and then to DefaultSet for each field. An empty public key resolves
+// the organisation default template alone.
+func ResolveSet(ctx context.Context, dbc *sqlx.DB, nodePublicKey string) (_ Set, err error) {
+	ctx, span := tracing.Start(ctx, "template.ResolveSet")
+	defer func() { tracing.End(span, err) }()
+
+	var key *string
+	if nodePublicKey != "" {
+		key = &nodePublicKey
+	}
+
+	var templates []Template
+	if err := dbc.SelectContext(ctx, &templates, `SELECT * FROM template
+WHERE node_public_key = $1 OR node_public_key IS NULL
+ORDER BY node_public_key NULLS LAST;`, key); err != nil {
+		return Set{}, fmt.Errorf("select templates: %w", err)
+	}
+
+	var set Set
+	for _, t := range templates {
+		set = set.Merge(Set{
+			Subject:  t.Subject,
+			HTMLBody: t.HTMLBody,
+			TextBody: t.TextBody,
+			SMSText:  t.SMSText,
+		})
+	}
+
+	return set.Merge(DefaultSet), nil
+}
+
+// UpsertTemplate creates or replaces the template of the node with the given public key,
+// or the organisation default template if it is nil.
+func UpsertTemplate(ctx context.Context, dbc *sqlx.DB, nodePublicKey *string, set Set) (err error) {
+	ctx, span := tracing.Start(ctx, "template.UpsertTemplate")
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := dbc.PreparexContext(ctx, `INSERT INTO template (node_public_key, subject, html_body, text_body, sms_text)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT ((COALESCE(node_public_key, '00000000-0000-0000-0000-000000000000')))
+DO UPDATE SET
+  subject = EXCLUDED.subject,
+  html_body = EXCLUDED.html_body,
+  text_body = EXCLUDED.text_body,
+  sms_text = EXCLUDED.sms_text,
+  modified = NOW();`)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, nodePublicKey, set.Subject, set.HTMLBody, set.TextBody, set.SMSText); err != nil {
+		return fmt.Errorf("execute statement: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package template_test tests the template package.
+package template_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/cmd/loraficationd/template"
+)
+
+// data returns the data that the templates within the tests are rendered with.
+func data() template.Data {
+	return template.Data{
+		Node:      template.Node{PublicKey: "3f1c0b2e-7d4a-4b8e-9a5f-2c6d8e0f1a3b", Name: "Pump 4"},
+		Message:   "Pressure high",
+		Severity:  "critical",
+		Timestamp: time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC),
+		Payload:   map[string]interface{}{"reading": "<script>alert(1)</script>"},
+	}
+}
+
+// TestSetRender_Default tests that DefaultSet renders every part of a notification.
+func TestSetRender_Default(t *testing.T) {
+	t.Parallel()
+
+	rendered, err := template.DefaultSet.Render(data())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if e, a := "[critical] LoRafication: Notification from Pump 4 Node", rendered.Subject; e != a {
+		t.Errorf("expected subject to be %q, got %q", e, a)
+	}
+
+	if e, a := "<p>Pressure high</p>", rendered.HTML; e != a {
+		t.Errorf("expected html to be %q, got %q", e, a)
+	}
+
+	if e, a := "Pressure high", rendered.Text; e != a {
+		t.Errorf("expected text to be %q, got %q", e, a)
+	}
+
+	if e, a := "Pump 4 (critical): Pressure high", rendered.SMS; e != a {
+		t.Errorf("expected sms to be %q, got %q", e, a)
+	}
+}
+
+// TestSetRender_EscapesHTML tests that the payload is escaped within the HTML body but
+// not within the plain-text body.
+func TestSetRender_EscapesHTML(t *testing.T) {
+	t.Parallel()
+
+	set := template.Set{
+		HTMLBody: "<p>{{.Payload.reading}}</p>",
+		TextBody: "{{.Payload.reading}}",
+	}.Merge(template.DefaultSet)
+
+	rendered, err := set.Render(data())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if strings.Contains(rendered.HTML, "<script>") {
+		t.Errorf("expected html to escape the payload, got %q", rendered.HTML)
+	}
+
+	if e, a := "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>", rendered.HTML; e != a {
+		t.Errorf("expected html to be %q, got %q", e, a)
+	}
+
+	if e, a := "<script>alert(1)</script>", rendered.Text; e != a {
+		t.Errorf("expected text to be %q, got %q", e, a)
+	}
+}
+
+// TestSetRender_SubjectLineBreaks tests that line breaks within the rendered subject are
+// collapsed so that it can't inject other email headers.
+func TestSetRender_SubjectLineBreaks(t *testing.T) {
+	t.Parallel()
+
+	d := data()
+	d.Message = "Pressure high\r\nBcc: attacker@example.com\n"
+
+	set := template.Set{Subject: "Alert: {{.Message}}"}.Merge(template.DefaultSet)
+
+	rendered, err := set.Render(d)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	if e, a := "Alert: Pressure high Bcc: attacker@example.com", rendered.Subject; e != a {
+		t.Errorf("expected subject to be %q, got %q", e, a)
+	}
+
+	if strings.ContainsAny(rendered.Subject, "\r\n") {
+		t.Errorf("expected subject to contain no line breaks, got %q", rendered.Subject)
+	}
+}
+
+// TestSetMerge tests that a node's template falls back to the organisation default
+// template and then to DefaultSet for each field it doesn't set, including when the node
+// has no template at all.
+func TestSetMerge(t *testing.T) {
+	t.Parallel()
+
+	org := template.Set{Subject: "Org: {{.Node.Name}}", SMSText: "Org SMS"}
+
+	tests := []struct {
+		name     string
+		node     template.Set
+		expected template.Set
+	}{
+		{
+			name: "no node template",
+			expected: template.Set{
+				Subject:  org.Subject,
+				HTMLBody: template.DefaultSet.HTMLBody,
+				TextBody: template.DefaultSet.TextBody,
+				SMSText:  org.SMSText,
+			},
+		},
+		{
+			name: "partial node template",
+			node: template.Set{Subject: "Node: {{.Message}}", TextBody: "Node text"},
+			expected: template.Set{
+				Subject:  "Node: {{.Message}}",
+				HTMLBody: template.DefaultSet.HTMLBody,
+				TextBody: "Node text",
+				SMSText:  org.SMSText,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if e, a := tt.expected, tt.node.Merge(org).Merge(template.DefaultSet); e != a {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, e, a)
+		}
+	}
+}
+
+// TestSetParse tests that Parse reports the templates that fail to parse by the JSON name
+// of their field.
+func TestSetParse(t *testing.T) {
+	t.Parallel()
+
+	if errs := template.DefaultSet.Parse(); len(errs) != 0 {
+		t.Errorf("expected default set to parse, got %v", errs)
+	}
+
+	errs := template.Set{Subject: "{{.Node.Name", HTMLBody: "<p>{{if}}</p>"}.Merge(template.DefaultSet).Parse()
+
+	if e, a := 2, len(errs); e != a {
+		t.Fatalf("expected %d errors, got %d: %v", e, a, errs)
+	}
+
+	for _, field := range []string{"subject", "htmlBody"} {
+		if _, ok := errs[field]; !ok {
+			t.Errorf("expected an error for %s", field)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// VoiceBridge is the VoiceProvider that posts calls as JSON to a bridge that places them,
+// such as a gateway in front of a SIP trunk or a local stand-in during development. The
+// bridge posts the digit pressed by the callee to the acknowledge URL of the call.
+type VoiceBridge struct {
+	client *http.Client
+	url    string
+}
+
+// NewVoiceBridge returns a reference to a VoiceBridge that hands calls to the bridge
+// listening at the given URL. A bridge that hasn't accepted a call within the timeout is
+// treated as unreachable, so the call is retried.
+func NewVoiceBridge(url string, timeout time.Duration) *VoiceBridge {
+	return &VoiceBridge{client: newHTTPClient(timeout), url: url}
+}
+
+// Call implements the VoiceProvider interface. The call has been placed once the bridge
+// responds with a 2xx status.
+func (vb *VoiceBridge) Call(ctx context.Context, call Call) error {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal call: %w", err))
+	}
+
+	return postJSON(ctx, vb.client, vb.url, body, nil)
+}
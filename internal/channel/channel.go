@@ -0,0 +1,114 @@
+// Package channel delivers the notifications of nodes to the contact points of entities,
+// with one Channel for each type of contact point, such as email or webhooks.
+package channel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/22arw/lorafication/internal/mail"
+)
+
+// Channel delivers notifications to contact points of a single type.
+type Channel interface {
+	// Deliver delivers the notification to the contact point. Errors that retrying the
+	// delivery won't resolve, such as the contact point rejecting the notification, are
+	// returned wrapped with Permanent.
+	Deliver(ctx context.Context, cp ContactPoint, n Notification) error
+}
+
+// ContactPoint is a contact point that a notification is delivered to.
+type ContactPoint struct {
+	// Address is where the notification is delivered to, e.g. an email address, the URL
+	// of a webhook or the ID of a chat.
+	Address string
+
+	// Secret is the optional secret shared with the contact point, such as the key that
+	// webhook events are signed with or the access token of a Matrix user.
+	Secret string
+}
+
+// Node is the node that a notification was sent by.
+type Node struct {
+	PublicKey string `json:"publicKey"`
+	Name      string `json:"name"`
+}
+
+// Urgency is how urgent a notification is, given by the level of its severity against
+// those of the built-in warning and critical severities, which channels prioritise and
+// colour notifications by so that custom severities are ranked alongside the built-in ones.
+type Urgency int
+
+// Urgencies of notifications, from least to most urgent.
+const (
+	UrgencyNormal Urgency = iota
+	UrgencyWarning
+	UrgencyCritical
+)
+
+// Notification is a notification sent by a node, along with its parts rendered from the
+// node's templates.
+type Notification struct {
+	ID        int
+	Node      Node
+	Message   string
+	Severity  string
+	Timestamp time.Time
+	Payload   map[string]interface{}
+
+	// Urgency is how urgent the notification is by the level of its severity.
+	Urgency Urgency
+
+	// Subject, Text, HTML and SMS are the rendered parts of the notification, each channel
+	// delivering those that suit it.
+	Subject string
+	Text    string
+	HTML    string
+	SMS     string
+
+	// Sender optionally overrides, field by field, who email notifications are sent from.
+	Sender mail.Sender
+
+	// UnsubscribeURL is the URL that unsubscribes the entity being notified from the node.
+	UnsubscribeURL string
+
+	// DeliveryID is the ID of the delivery of the notification to the contact point, which
+	// channels may use to make retried attempts idempotent.
+	DeliveryID int
+
+	// AcknowledgeURL is the URL that acknowledges the notification on behalf of the
+	// contact point it's delivered to, for channels that offer a way of acknowledging it.
+	AcknowledgeURL string
+}
+
+// permanentError is the error returned by Permanent.
+type permanentError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error that was marked as permanent.
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the given error as one that retrying a delivery won't resolve.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether or not the given error, or one that it wraps, was marked as
+// permanent with Permanent.
+func IsPermanent(err error) bool {
+	var permErr *permanentError
+	return errors.As(err, &permErr)
+}
@@ -0,0 +1,82 @@
+// Package channel_test tests the channel package.
+package channel_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// notification is the notification delivered through channels within tests. Its text
+// holds characters that channels formatting markup must escape.
+var notification = channel.Notification{
+	ID:             7,
+	Node:           channel.Node{PublicKey: "7b8a3c1e-8a0e-4c43-9b8e-2a1f5f1c2d3e", Name: "Reservoir"},
+	Message:        "Water level high",
+	Severity:       "critical",
+	Urgency:        channel.UrgencyCritical,
+	Timestamp:      time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC),
+	Payload:        map[string]interface{}{"level": 9.5},
+	Subject:        "Water level high",
+	Text:           "Water level <high> & rising",
+	AcknowledgeURL: "https://example.com/acknowledge?token=abc",
+}
+
+// request is a request received by a receiver within tests.
+type request struct {
+	method string
+	path   string // Escaped, so that the escaping of IDs within paths can be tested.
+	header http.Header
+	body   []byte
+}
+
+// receive starts an httptest server, closed once the test finishes, that responds to every
+// request with the given status and body. It returns the URL of the server along with the
+// last request it received, which is filled in once the server has responded.
+func receive(t *testing.T, status int, response string) (string, *request) {
+	t.Helper()
+
+	var req request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+
+		req = request{method: r.Method, path: r.URL.EscapedPath(), header: r.Header, body: body}
+
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL, &req
+}
+
+// decode unmarshals the JSON body of the request into v, failing the test when it isn't
+// JSON.
+func (r *request) decode(t *testing.T, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.body, v); err != nil {
+		t.Fatalf("unmarshal body %s: %v", r.body, err)
+	}
+}
+
+// form returns the URL encoded form that is the body of the request.
+func (r *request) form(t *testing.T) url.Values {
+	t.Helper()
+
+	form, err := url.ParseQuery(string(r.body))
+	if err != nil {
+		t.Fatalf("parse form %s: %v", r.body, err)
+	}
+
+	return form
+}
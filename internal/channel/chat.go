@@ -0,0 +1,41 @@
+package channel
+
+import (
+	"time"
+)
+
+// Colours that chat messages are marked with, by the urgency of their notification.
+const (
+	colourCritical = "#D0021B"
+	colourWarning  = "#F5A623"
+	colourNormal   = "#4A90E2"
+)
+
+// urgencyColour returns the hex colour that a chat message for a notification of the
+// given urgency is marked with.
+func urgencyColour(u Urgency) string {
+	switch u {
+	case UrgencyCritical:
+		return colourCritical
+	case UrgencyWarning:
+		return colourWarning
+	default:
+		return colourNormal
+	}
+}
+
+// chatTime formats the time of a notification within chat messages.
+func chatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123)
+}
+
+// truncate returns s cut down to at most max runes, ending with an ellipsis when it's
+// cut, so that it fits within the limits that chat services place on text.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max-1]) + "…"
+}
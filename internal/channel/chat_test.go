@@ -0,0 +1,92 @@
+package channel_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// deliverChat delivers the notification through the given channel to a receiver,
+// returning the JSON body that it received.
+func deliverChat(t *testing.T, ch channel.Channel) string {
+	t.Helper()
+
+	u, req := receive(t, http.StatusOK, "ok")
+	if err := ch.Deliver(context.Background(), channel.ContactPoint{Address: u}, notification); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if e, a := "application/json", req.header.Get("Content-Type"); e != a {
+		t.Errorf("expected content type %q, got %q", e, a)
+	}
+
+	if !json.Valid(req.body) {
+		t.Fatalf("expected a JSON body, got %s", req.body)
+	}
+
+	return string(req.body)
+}
+
+// TestChatDeliver tests that the chat channels post the subject, node name, escaped text,
+// severity colour and acknowledge link of a notification in the format of their service.
+func TestChatDeliver(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		channel  channel.Channel
+		expected []string
+	}{
+		"slack": {
+			channel: channel.NewSlack(time.Second),
+			expected: []string{
+				`"text":"Water level high","attachments"`,
+				`"color":"#D0021B"`,
+				`{"type":"header","text":{"type":"plain_text","text":"Reservoir"}}`,
+				`"text":"Water level \u0026lt;high\u0026gt; \u0026amp; rising"`,
+				`"text":"Severity *critical* | Received Thu, 04 Mar 2021 05:06:07 UTC"`,
+				`"url":"https://example.com/acknowledge?token=abc"`,
+			},
+		},
+		"teams": {
+			channel: channel.NewTeams(time.Second),
+			expected: []string{
+				`"contentType":"application/vnd.microsoft.card.adaptive"`,
+				`"type":"AdaptiveCard"`,
+				`"text":"Reservoir","size":"Large","weight":"Bolder","color":"Attention"`,
+				`{"title":"Severity","value":"critical"}`,
+				`{"type":"Action.OpenUrl","title":"Acknowledge","url":"https://example.com/acknowledge?token=abc"}`,
+			},
+		},
+		"mattermost": {
+			channel: channel.NewMattermost(time.Second),
+			expected: []string{
+				`"fallback":"Water level high"`,
+				`"color":"#D0021B"`,
+				`"title":"Reservoir"`,
+				`"text":"Water level \\\u003chigh\\\u003e \\\u0026 rising`,
+				`[Acknowledge](https://example.com/acknowledge?token=abc)`,
+				`{"short":true,"title":"Severity","value":"critical"}`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := deliverChat(t, test.channel)
+			for _, e := range test.expected {
+				if !strings.Contains(body, e) {
+					t.Errorf("expected body to contain %s, got %s", e, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/textproto"
+
+	"github.com/22arw/lorafication/internal/mail"
+)
+
+// Email is the Channel that emails notifications, with a link that unsubscribes the
+// recipient from the node in their footer.
+type Email struct {
+	mailer *mail.Mailer
+}
+
+// NewEmail returns a reference to an Email channel that sends email through the given
+// Mailer.
+func NewEmail(mailer *mail.Mailer) *Email {
+	return &Email{mailer: mailer}
+}
+
+// Deliver implements the Channel interface. Emails that the SMTP server rejects outright,
+// or that can't be composed, fail permanently.
+func (e *Email) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	err := e.mailer.Send(ctx, mail.Message{
+		To:      cp.Address,
+		Subject: n.Subject,
+		Text: n.Text + fmt.Sprintf("\n\nUnsubscribe from notifications from the %s Node: %s\n",
+			n.Node.Name, n.UnsubscribeURL),
+		HTML: n.HTML + fmt.Sprintf("<p><a href=\"%s\">Unsubscribe</a> from notifications from the %s Node.</p>",
+			html.EscapeString(n.UnsubscribeURL), html.EscapeString(n.Node.Name)),
+		Sender:         n.Sender,
+		UnsubscribeURL: n.UnsubscribeURL,
+	})
+
+	var tpErr *textproto.Error
+	if (errors.As(err, &tpErr) && tpErr.Code >= 500) || errors.Is(err, mail.ErrHeaderInjection) {
+		return Permanent(err)
+	}
+
+	return err
+}
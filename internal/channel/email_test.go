@@ -0,0 +1,75 @@
+package channel_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/22arw/lorafication/internal/channel"
+	"github.com/22arw/lorafication/internal/mail"
+	"go.uber.org/zap"
+)
+
+// newMailer returns a reference to a Mailer, closed once the test finishes, that sends
+// email from alerts@example.com through the given transport.
+func newMailer(t *testing.T, transport mail.Transport) *mail.Mailer {
+	t.Helper()
+
+	mailer, err := mail.NewMailer(mail.Config{
+		Transport: transport,
+		Sender:    mail.Sender{Address: "alerts@example.com"},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	t.Cleanup(func() { mailer.Close() })
+
+	return mailer
+}
+
+// TestEmailDeliver tests that an Email channel emails the rendered notification with a
+// footer that links to the unsubscribe URL.
+func TestEmailDeliver(t *testing.T) {
+	t.Parallel()
+
+	transport := mail.NewMemoryTransport(0)
+
+	n := notification
+	n.HTML = "<p>Water level high</p>"
+	n.Sender = mail.Sender{Name: "Reservoir Alerts"}
+	n.UnsubscribeURL = "https://example.com/unsubscribe?token=abc"
+
+	if err := channel.NewEmail(newMailer(t, transport)).Deliver(context.Background(), channel.ContactPoint{Address: "user@example.com"}, n); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	captured := transport.Captured()
+	if e, a := 1, len(captured); e != a {
+		t.Fatalf("expected %d emails, got %d", e, a)
+	}
+	email := string(captured[0].Email)
+
+	for _, e := range []string{
+		"To: user@example.com\r\n",
+		"Subject: Water level high\r\n",
+		"From: \"Reservoir Alerts\" <alerts@example.com>\r\n",
+		"List-Unsubscribe: <https://example.com/unsubscribe?token=abc>\r\n",
+		"Unsubscribe from notifications from the Reservoir Node",
+	} {
+		if !strings.Contains(email, e) {
+			t.Errorf("expected email to contain %q", e)
+		}
+	}
+}
+
+// TestEmailDeliverHeaderInjection tests that an email that can't be composed fails the
+// delivery permanently.
+func TestEmailDeliverHeaderInjection(t *testing.T) {
+	t.Parallel()
+
+	email := channel.NewEmail(newMailer(t, mail.NewMemoryTransport(0)))
+	err := email.Deliver(context.Background(), channel.ContactPoint{Address: "user@example.com\r\nBcc: victim@example.com"}, notification)
+	if !channel.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Priorities of Gotify messages, which range from 0 to 10, those of 8 and above being
+// shown as high priority by the Android client.
+const (
+	gotifyPriorityDefault = 5
+	gotifyPriorityHigh    = 8
+	gotifyPriorityMax     = 10
+)
+
+// gotifyMessage is the JSON body of a message created on a Gotify server.
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras"`
+}
+
+// Gotify is the Channel that sends notifications to the Gotify server whose URL is the
+// address of a contact point, as the application whose token is its secret.
+type Gotify struct {
+	client *http.Client
+}
+
+// NewGotify returns a reference to a Gotify channel. As every contact point names its own
+// Gotify server and application token, only the timeout of pushing a message is shared.
+func NewGotify(timeout time.Duration) *Gotify {
+	return &Gotify{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The message is rendered as markdown, its
+// priority being that of the severity of the notification, and opens the link that
+// acknowledges it when it's tapped.
+func (g *Gotify) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	if cp.Secret == "" {
+		return Permanent(errors.New("no gotify application token for contact point"))
+	}
+
+	text := n.Text
+	extras := map[string]interface{}{
+		"client::display": map[string]string{"contentType": "text/markdown"},
+	}
+
+	if n.AcknowledgeURL != "" {
+		text += fmt.Sprintf("\n\n[Acknowledge](%s)", n.AcknowledgeURL)
+		extras["client::notification"] = map[string]interface{}{
+			"click": map[string]string{"url": n.AcknowledgeURL},
+		}
+	}
+
+	body, err := json.Marshal(gotifyMessage{
+		Title:    n.Subject,
+		Message:  text,
+		Priority: gotifyPriority(n.Urgency),
+		Extras:   extras,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal gotify message: %w", err))
+	}
+
+	return postJSON(ctx, g.client, strings.TrimRight(cp.Address, "/")+"/message", body, http.Header{
+		"X-Gotify-Key": {cp.Secret},
+	})
+}
+
+// gotifyPriority returns the priority of a Gotify message for a notification of the given
+// urgency.
+func gotifyPriority(u Urgency) int {
+	switch u {
+	case UrgencyCritical:
+		return gotifyPriorityMax
+	case UrgencyWarning:
+		return gotifyPriorityHigh
+	default:
+		return gotifyPriorityDefault
+	}
+}
@@ -0,0 +1,104 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultWebhookTimeout is the timeout of the channels that post to webhooks, such as
+// Webhook and Slack, when they're created with a timeout of zero.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// newHTTPClient returns the client that channels post to webhooks with, whose requests
+// time out after the given timeout, defaulting to DefaultWebhookTimeout.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+
+		// Redirects aren't followed, so that a webhook can't send notifications to an
+		// address other than the one that was verified.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// postJSON posts the given JSON body to the given URL along with the given headers,
+// returning the error corresponding to the status that the URL responded with.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	return sendJSON(ctx, client, http.MethodPost, url, body, header)
+}
+
+// sendJSON sends the given JSON body to the given URL with the given method along with
+// the given headers, returning the error corresponding to the status that the URL
+// responded with.
+func sendJSON(ctx context.Context, client *http.Client, method, rawURL string, body []byte, header http.Header) error {
+	return send(ctx, client, method, rawURL, "application/json", body, header)
+}
+
+// postForm posts the given form to the given URL along with the given headers, returning
+// the error corresponding to the status that the URL responded with.
+func postForm(ctx context.Context, client *http.Client, rawURL string, form url.Values, header http.Header) error {
+	return send(ctx, client, http.MethodPost, rawURL, "application/x-www-form-urlencoded", []byte(form.Encode()), header)
+}
+
+// send sends the given body of the given content type to the given URL with the given
+// method along with the given headers, returning the error corresponding to the status
+// that the URL responded with.
+func send(ctx context.Context, client *http.Client, method, rawURL, contentType string, body []byte,
+	header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(errors.New("create request: invalid url"))
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "loraficationd")
+
+	res, err := client.Do(req)
+	if err != nil {
+		// The URL is left out of the error, which is recorded against the delivery, as
+		// those of incoming webhooks and bot APIs hold their credentials.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("send notification: %w", err)
+	}
+	defer res.Body.Close()
+
+	// Reading the rest of the body allows the connection to be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4<<10))
+
+	return statusError(res.StatusCode)
+}
+
+// statusError returns the error corresponding to the status a webhook responded with,
+// being nil for 2xx statuses and permanent unless the status is worth retrying.
+func statusError(code int) error {
+	if code >= 200 && code < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("webhook responded with status %d", code)
+	if code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return err
+	}
+
+	return Permanent(err)
+}
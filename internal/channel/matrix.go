@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// matrixMessage is the content of an m.room.message event, with its text as markdown
+// and HTML for clients that render it.
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// Matrix is the Channel that sends notifications as messages to the Matrix room whose ID
+// is the address of a contact point, through the client-server API of a homeserver.
+type Matrix struct {
+	client        *http.Client
+	homeserverURL string
+	accessToken   string
+}
+
+// NewMatrix returns a reference to a Matrix channel that sends messages into rooms through
+// the homeserver at the given URL. It sends them as the user with the given access token,
+// unless a contact point carries its own token as its secret.
+func NewMatrix(homeserverURL, accessToken string, timeout time.Duration) *Matrix {
+	return &Matrix{
+		client:        newHTTPClient(timeout),
+		homeserverURL: strings.TrimRight(homeserverURL, "/"),
+		accessToken:   accessToken,
+	}
+}
+
+// Deliver implements the Channel interface. The message is headed by the name of the node
+// and shows its severity in the colour of the severity, with a link that acknowledges it.
+// The transaction ID of the message is that of the delivery, so that the homeserver
+// doesn't send a message twice when an attempt is retried after it was sent.
+func (m *Matrix) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	token := cp.Secret
+	if token == "" {
+		token = m.accessToken
+	}
+
+	if token == "" {
+		return Permanent(errors.New("no matrix access token for contact point"))
+	}
+
+	footer := fmt.Sprintf("Received %s", chatTime(n.Timestamp))
+	htmlFooter := html.EscapeString(footer)
+	if n.Severity != "" {
+		footer = fmt.Sprintf("Severity **%s** | %s", n.Severity, footer)
+		htmlFooter = fmt.Sprintf(`<font data-mx-color="%s">Severity <b>%s</b></font> | %s`,
+			urgencyColour(n.Urgency), html.EscapeString(n.Severity), htmlFooter)
+	}
+
+	text := fmt.Sprintf("**%s**\n\n%s\n\n%s", n.Node.Name, n.Text, footer)
+	htmlText := fmt.Sprintf("<h4>%s</h4><p>%s</p><p>%s</p>",
+		html.EscapeString(n.Node.Name),
+		strings.ReplaceAll(html.EscapeString(n.Text), "\n", "<br>"),
+		htmlFooter)
+
+	if n.AcknowledgeURL != "" {
+		text += fmt.Sprintf("\n\n[Acknowledge](%s)", n.AcknowledgeURL)
+		htmlText += fmt.Sprintf(`<p><a href="%s">Acknowledge</a></p>`, html.EscapeString(n.AcknowledgeURL))
+	}
+
+	body, err := json.Marshal(matrixMessage{
+		MsgType:       "m.text",
+		Body:          text,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: htmlText,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal matrix message: %w", err))
+	}
+
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/lorafication-%d",
+		m.homeserverURL, url.PathEscape(cp.Address), n.DeliveryID)
+
+	return sendJSON(ctx, m.client, http.MethodPut, u, body, http.Header{
+		"Authorization": {"Bearer " + token},
+	})
+}
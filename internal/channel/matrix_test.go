@@ -0,0 +1,72 @@
+package channel_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// TestMatrixDeliver tests that the Matrix channel sends a message to the room of the
+// contact point, authorized with the access token of the contact point over that of the
+// channel, under a transaction ID derived from the delivery.
+func TestMatrixDeliver(t *testing.T) {
+	t.Parallel()
+
+	u, req := receive(t, http.StatusOK, `{"event_id":"$abc"}`)
+
+	n := notification
+	n.DeliveryID = 12
+
+	mx := channel.NewMatrix(u+"/", "channel-token", time.Second)
+	cp := channel.ContactPoint{Address: "!room:example.com", Secret: "contact-token"}
+	if err := mx.Deliver(context.Background(), cp, n); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if e, a := http.MethodPut, req.method; e != a {
+		t.Errorf("expected method %q, got %q", e, a)
+	}
+
+	if e, a := "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/lorafication-12", req.path; e != a {
+		t.Errorf("expected path %q, got %q", e, a)
+	}
+
+	if e, a := "Bearer contact-token", req.header.Get("Authorization"); e != a {
+		t.Errorf("expected authorization %q, got %q", e, a)
+	}
+
+	var msg map[string]string
+	req.decode(t, &msg)
+
+	if e, a := "org.matrix.custom.html", msg["format"]; e != a {
+		t.Errorf("expected format %q, got %q", e, a)
+	}
+
+	for _, e := range []string{"**Reservoir**", "Severity **critical**", "[Acknowledge](https://example.com/acknowledge?token=abc)"} {
+		if !strings.Contains(msg["body"], e) {
+			t.Errorf("expected body to contain %q, got %q", e, msg["body"])
+		}
+	}
+
+	for _, e := range []string{"<h4>Reservoir</h4>", "Water level &lt;high&gt; &amp; rising", `data-mx-color="#D0021B"`} {
+		if !strings.Contains(msg["formatted_body"], e) {
+			t.Errorf("expected formatted body to contain %q, got %q", e, msg["formatted_body"])
+		}
+	}
+}
+
+// TestMatrixDeliverNoAccessToken tests that the Matrix channel fails permanently when
+// neither it nor the contact point has an access token.
+func TestMatrixDeliverNoAccessToken(t *testing.T) {
+	t.Parallel()
+
+	mx := channel.NewMatrix("http://127.0.0.1:0", "", time.Second)
+	err := mx.Deliver(context.Background(), channel.ContactPoint{Address: "!room:example.com"}, notification)
+	if !channel.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
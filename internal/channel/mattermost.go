@@ -0,0 +1,81 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mattermostEscaper escapes the characters that Mattermost treats as markdown within the
+// title, text and fields of message attachments.
+var mattermostEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "{", `\{`, "}", `\}`, "[", `\[`, "]", `\]`,
+	"(", `\(`, ")", `\)`, "#", `\#`, "+", `\+`, "-", `\-`, ".", `\.`, "!", `\!`, "|", `\|`,
+	"<", `\<`, ">", `\>`, "~", `\~`, "&", `\&`)
+
+// mattermostMessage is the body posted to a Mattermost incoming webhook.
+type mattermostMessage struct {
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+// mattermostAttachment is a message attachment, marked with the colour of the severity of
+// its notification.
+type mattermostAttachment struct {
+	Fallback string            `json:"fallback"`
+	Color    string            `json:"color"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Fields   []mattermostField `json:"fields"`
+}
+
+// mattermostField is a field shown in a table within a mattermostAttachment.
+type mattermostField struct {
+	Short bool   `json:"short"`
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Mattermost is the Channel that posts notifications as message attachments to the
+// Mattermost incoming webhook URL of a contact point.
+type Mattermost struct {
+	client *http.Client
+}
+
+// NewMattermost returns a reference to a Mattermost channel that posts attachments to the
+// incoming webhook of each contact point, waiting up to the timeout for the server.
+func NewMattermost(timeout time.Duration) *Mattermost {
+	return &Mattermost{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The attachment is titled with the name of the
+// node and marked with the colour of its severity, with a link that acknowledges it.
+func (m *Mattermost) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	text := mattermostEscaper.Replace(n.Text)
+	if n.AcknowledgeURL != "" {
+		text += fmt.Sprintf("\n\n[Acknowledge](%s)", n.AcknowledgeURL)
+	}
+
+	var fields []mattermostField
+	if n.Severity != "" {
+		fields = append(fields, mattermostField{Short: true, Title: "Severity", Value: mattermostEscaper.Replace(n.Severity)})
+	}
+	fields = append(fields, mattermostField{Short: true, Title: "Received", Value: chatTime(n.Timestamp)})
+
+	body, err := json.Marshal(mattermostMessage{
+		Attachments: []mattermostAttachment{{
+			Fallback: n.Subject,
+			Color:    urgencyColour(n.Urgency),
+			Title:    mattermostEscaper.Replace(n.Node.Name),
+			Text:     text,
+			Fields:   fields,
+		}},
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal mattermost message: %w", err))
+	}
+
+	return postJSON(ctx, m.client, cp.Address, body, nil)
+}
@@ -0,0 +1,114 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Priorities of ntfy messages, from min to max.
+const (
+	ntfyPriorityDefault = 3
+	ntfyPriorityHigh    = 4
+	ntfyPriorityMax     = 5
+)
+
+// ntfyMessage is the JSON body published to the root of an ntfy server.
+type ntfyMessage struct {
+	Topic    string       `json:"topic"`
+	Title    string       `json:"title"`
+	Message  string       `json:"message"`
+	Priority int          `json:"priority"`
+	Tags     []string     `json:"tags,omitempty"`
+	Actions  []ntfyAction `json:"actions,omitempty"`
+}
+
+// ntfyAction is a button shown on an ntfy notification.
+type ntfyAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"`
+	Clear  bool   `json:"clear"`
+}
+
+// Ntfy is the Channel that publishes notifications to the ntfy topic whose URL, such as
+// https://ntfy.sh/ourcity-alerts, is the address of a contact point, so that topics on
+// self-hosted servers are notified as well. The secret of a contact point is the access
+// token of topics that require one.
+type Ntfy struct {
+	client *http.Client
+}
+
+// NewNtfy returns a reference to an Ntfy channel, which gives up on publishing to a topic
+// whose server hasn't responded within the timeout.
+func NewNtfy(timeout time.Duration) *Ntfy {
+	return &Ntfy{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The priority of the message is that of the
+// severity of the notification, with an action that acknowledges it from the phone in
+// the background.
+func (nt *Ntfy) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	u, err := url.Parse(cp.Address)
+	if err != nil {
+		return Permanent(errors.New("parse ntfy topic url: invalid url"))
+	}
+
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" || strings.Contains(topic, "/") {
+		return Permanent(errors.New("ntfy topic url must end with a single topic"))
+	}
+	u.Path = "/"
+
+	msg := ntfyMessage{
+		Topic:    topic,
+		Title:    n.Subject,
+		Message:  n.Text,
+		Priority: ntfyPriority(n.Urgency),
+	}
+
+	if n.Severity != "" {
+		msg.Tags = []string{strings.ToLower(n.Severity)}
+	}
+
+	if n.AcknowledgeURL != "" {
+		msg.Actions = []ntfyAction{{
+			Action: "http",
+			Label:  "Acknowledge",
+			URL:    n.AcknowledgeURL,
+			Method: http.MethodPost,
+			Clear:  true,
+		}}
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal ntfy message: %w", err))
+	}
+
+	var header http.Header
+	if cp.Secret != "" {
+		header = http.Header{"Authorization": {"Bearer " + cp.Secret}}
+	}
+
+	return postJSON(ctx, nt.client, u.String(), body, header)
+}
+
+// ntfyPriority returns the priority of an ntfy message for a notification of the given
+// urgency.
+func ntfyPriority(u Urgency) int {
+	switch u {
+	case UrgencyCritical:
+		return ntfyPriorityMax
+	case UrgencyWarning:
+		return ntfyPriorityHigh
+	default:
+		return ntfyPriorityDefault
+	}
+}
@@ -0,0 +1,145 @@
+package channel_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// deliverPush delivers the notification, at the given urgency, through the push channel
+// returned by newChannel, given the URL of a receiver, to the contact point returned by
+// newContactPoint. It returns the request that the receiver received along with its
+// JSON body.
+func deliverPush(t *testing.T, urgency channel.Urgency, newChannel func(url string) channel.Channel,
+	newContactPoint func(url string) channel.ContactPoint) (*request, map[string]interface{}) {
+	t.Helper()
+
+	u, req := receive(t, http.StatusOK, `{"status":1}`)
+
+	n := notification
+	n.Urgency = urgency
+
+	if err := newChannel(u).Deliver(context.Background(), newContactPoint(u), n); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	var body map[string]interface{}
+	req.decode(t, &body)
+
+	return req, body
+}
+
+// TestPushDeliver tests that the push channels send notifications to the server of the
+// contact point with its token and the priority of the notification's urgency.
+func TestPushDeliver(t *testing.T) {
+	t.Parallel()
+
+	ntfy := func(string) channel.Channel { return channel.NewNtfy(time.Second) }
+	ntfyCP := func(url string) channel.ContactPoint {
+		return channel.ContactPoint{Address: url + "/ourcity-alerts", Secret: "tk_abc"}
+	}
+
+	gotify := func(string) channel.Channel { return channel.NewGotify(time.Second) }
+	gotifyCP := func(url string) channel.ContactPoint {
+		return channel.ContactPoint{Address: url + "/", Secret: "AbCdEf"}
+	}
+
+	pushover := func(url string) channel.Channel {
+		return channel.NewPushover(channel.PushoverConfig{APIURL: url, AppToken: "app"}, time.Second)
+	}
+	pushoverCP := func(string) channel.ContactPoint {
+		return channel.ContactPoint{Address: "uQiRzpo4DXghDmr9QzzfQu27cmVRsG"}
+	}
+
+	tests := map[string]struct {
+		urgency         channel.Urgency
+		newChannel      func(url string) channel.Channel
+		newContactPoint func(url string) channel.ContactPoint
+		path            string
+		header          string
+		headerValue     string
+		priority        float64
+	}{
+		"ntfy critical": {channel.UrgencyCritical, ntfy, ntfyCP, "/", "Authorization", "Bearer tk_abc", 5},
+		"ntfy warning":  {channel.UrgencyWarning, ntfy, ntfyCP, "/", "Authorization", "Bearer tk_abc", 4},
+		"ntfy other":    {channel.UrgencyNormal, ntfy, ntfyCP, "/", "Authorization", "Bearer tk_abc", 3},
+
+		"gotify critical": {channel.UrgencyCritical, gotify, gotifyCP, "/message", "X-Gotify-Key", "AbCdEf", 10},
+		"gotify warning":  {channel.UrgencyWarning, gotify, gotifyCP, "/message", "X-Gotify-Key", "AbCdEf", 8},
+		"gotify other":    {channel.UrgencyNormal, gotify, gotifyCP, "/message", "X-Gotify-Key", "AbCdEf", 5},
+
+		"pushover critical": {channel.UrgencyCritical, pushover, pushoverCP, "/1/messages.json", "Content-Type", "application/json", 2},
+		"pushover warning":  {channel.UrgencyWarning, pushover, pushoverCP, "/1/messages.json", "Content-Type", "application/json", 1},
+		"pushover other":    {channel.UrgencyNormal, pushover, pushoverCP, "/1/messages.json", "Content-Type", "application/json", 0},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req, body := deliverPush(t, test.urgency, test.newChannel, test.newContactPoint)
+
+			if e, a := test.path, req.path; e != a {
+				t.Errorf("expected path %q, got %q", e, a)
+			}
+
+			if e, a := test.headerValue, req.header.Get(test.header); e != a {
+				t.Errorf("expected %s header %q, got %q", test.header, e, a)
+			}
+
+			if e, a := test.priority, body["priority"]; e != a {
+				t.Errorf("expected priority %v, got %v", e, a)
+			}
+
+			if e, a := "Water level high", body["title"]; e != a {
+				t.Errorf("expected title %q, got %v", e, a)
+			}
+		})
+	}
+}
+
+// TestPushoverDeliverEmergency tests that the Pushover channel repeats critical
+// notifications until they're acknowledged, calling back to acknowledge the notification.
+func TestPushoverDeliverEmergency(t *testing.T) {
+	t.Parallel()
+
+	pushover := func(url string) channel.Channel {
+		return channel.NewPushover(channel.PushoverConfig{APIURL: url, AppToken: "app", Retry: time.Second}, time.Second)
+	}
+	cp := func(string) channel.ContactPoint {
+		return channel.ContactPoint{Address: "uQiRzpo4DXghDmr9QzzfQu27cmVRsG", Secret: "own"}
+	}
+
+	_, body := deliverPush(t, channel.UrgencyCritical, pushover, cp)
+
+	expected := map[string]interface{}{
+		"token":    "own",
+		"user":     "uQiRzpo4DXghDmr9QzzfQu27cmVRsG",
+		"retry":    float64(30),
+		"expire":   float64(3600),
+		"callback": notification.AcknowledgeURL,
+	}
+
+	for key, e := range expected {
+		if a := body[key]; e != a {
+			t.Errorf("expected %s %v, got %v", key, e, a)
+		}
+	}
+}
+
+// TestNtfyDeliverTopic tests that the Ntfy channel fails permanently when the address of
+// the contact point isn't the URL of a topic.
+func TestNtfyDeliverTopic(t *testing.T) {
+	t.Parallel()
+
+	err := channel.NewNtfy(time.Second).Deliver(context.Background(),
+		channel.ContactPoint{Address: "https://ntfy.sh/"}, notification)
+	if !channel.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
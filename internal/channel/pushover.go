@@ -0,0 +1,166 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPushoverAPIURL is the base URL of the Pushover API that a Pushover channel sends
+// messages through when it's created without one.
+const DefaultPushoverAPIURL = "https://api.pushover.net"
+
+// Defaults of the fields of the PushoverConfig type.
+const (
+	// DefaultPushoverRetry is the default value of the Retry field on the PushoverConfig
+	// type.
+	DefaultPushoverRetry = time.Minute
+
+	// DefaultPushoverExpire is the default value of the Expire field on the PushoverConfig
+	// type.
+	DefaultPushoverExpire = time.Hour
+)
+
+// Limits that Pushover places on emergency messages, between which Retry and Expire are
+// clamped.
+const (
+	MinPushoverRetry  = 30 * time.Second
+	MaxPushoverExpire = 3 * time.Hour
+)
+
+// Limits that Pushover places on the text of a message.
+const (
+	pushoverMaxTitle   = 250
+	pushoverMaxMessage = 1024
+)
+
+// Priorities of Pushover messages, an emergency message being repeated until it's
+// acknowledged or expires.
+const (
+	pushoverPriorityNormal    = 0
+	pushoverPriorityHigh      = 1
+	pushoverPriorityEmergency = 2
+)
+
+// pushoverMessage is the JSON body of a message sent through the Pushover API.
+type pushoverMessage struct {
+	Token     string `json:"token"`
+	User      string `json:"user"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+	Priority  int    `json:"priority"`
+	Retry     int    `json:"retry,omitempty"`
+	Expire    int    `json:"expire,omitempty"`
+	Callback  string `json:"callback,omitempty"`
+	URL       string `json:"url,omitempty"`
+	URLTitle  string `json:"url_title,omitempty"`
+}
+
+// PushoverConfig represents the application that a Pushover channel sends messages as
+// and how it repeats emergency messages.
+type PushoverConfig struct {
+	// APIURL is the base URL of the Pushover API, defaulting to DefaultPushoverAPIURL.
+	APIURL string
+
+	// AppToken is the token of the application that messages are sent as, unless a
+	// contact point has its own as its secret.
+	AppToken string
+
+	// Retry is how often an emergency message is repeated until it's acknowledged.
+	Retry time.Duration
+
+	// Expire is how long an emergency message is repeated for.
+	Expire time.Duration
+}
+
+// Pushover is the Channel that sends notifications through Pushover to the user or group
+// whose key is the address of a contact point.
+type Pushover struct {
+	client *http.Client
+	cfg    PushoverConfig
+}
+
+// NewPushover returns a reference to a Pushover channel that sends messages through the
+// application of cfg to the user or group key of each contact point. Fields of cfg left as
+// zero take their defaults.
+func NewPushover(cfg PushoverConfig, timeout time.Duration) *Pushover {
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultPushoverAPIURL
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+
+	if cfg.Retry <= 0 {
+		cfg.Retry = DefaultPushoverRetry
+	} else if cfg.Retry < MinPushoverRetry {
+		cfg.Retry = MinPushoverRetry
+	}
+
+	if cfg.Expire <= 0 {
+		cfg.Expire = DefaultPushoverExpire
+	} else if cfg.Expire > MaxPushoverExpire {
+		cfg.Expire = MaxPushoverExpire
+	}
+
+	return &Pushover{client: newHTTPClient(timeout), cfg: cfg}
+}
+
+// Deliver implements the Channel interface. Critical notifications are sent with
+// emergency priority, repeated every Retry until they're acknowledged within Pushover,
+// which calls back to acknowledge the notification, or Expire passes. Warnings are sent
+// with high priority, bypassing quiet hours, and any other notification with normal
+// priority.
+func (p *Pushover) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	token := cp.Secret
+	if token == "" {
+		token = p.cfg.AppToken
+	}
+
+	if token == "" {
+		return Permanent(errors.New("no pushover application token for contact point"))
+	}
+
+	msg := pushoverMessage{
+		Token:     token,
+		User:      cp.Address,
+		Title:     truncate(n.Subject, pushoverMaxTitle),
+		Message:   truncate(n.Text, pushoverMaxMessage),
+		Timestamp: n.Timestamp.Unix(),
+		Priority:  pushoverPriority(n.Urgency),
+	}
+
+	if n.AcknowledgeURL != "" {
+		msg.URL = n.AcknowledgeURL
+		msg.URLTitle = "Acknowledge"
+	}
+
+	if msg.Priority == pushoverPriorityEmergency {
+		msg.Retry = int(p.cfg.Retry / time.Second)
+		msg.Expire = int(p.cfg.Expire / time.Second)
+		msg.Callback = n.AcknowledgeURL
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal pushover message: %w", err))
+	}
+
+	return postJSON(ctx, p.client, p.cfg.APIURL+"/1/messages.json", body, nil)
+}
+
+// pushoverPriority returns the priority of a Pushover message for a notification of the
+// given urgency.
+func pushoverPriority(u Urgency) int {
+	switch u {
+	case UrgencyCritical:
+		return pushoverPriorityEmergency
+	case UrgencyWarning:
+		return pushoverPriorityHigh
+	default:
+		return pushoverPriorityNormal
+	}
+}
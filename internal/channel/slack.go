@@ -0,0 +1,100 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits that Slack places on the text of Block Kit blocks.
+const (
+	slackMaxHeader  = 150
+	slackMaxSection = 3000
+)
+
+// slackEscaper escapes the characters that Slack treats as control characters within
+// mrkdwn text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackMessage is the body posted to a Slack incoming webhook.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackAttachment is a secondary attachment of a Slack message, used to mark the message
+// with the colour of its severity.
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a Block Kit layout block.
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// slackText is a Block Kit text object.
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackButton is a Block Kit button element that opens a URL.
+type slackButton struct {
+	Type  string    `json:"type"`
+	Text  slackText `json:"text"`
+	URL   string    `json:"url"`
+	Style string    `json:"style,omitempty"`
+}
+
+// Slack is the Channel that posts notifications as Block Kit messages to the Slack
+// incoming webhook URL of a contact point.
+type Slack struct {
+	client *http.Client
+}
+
+// NewSlack returns a reference to a Slack channel that posts Block Kit messages to the
+// incoming webhook of each contact point.
+func NewSlack(timeout time.Duration) *Slack {
+	return &Slack{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The message is headed by the name of the node
+// and marked with the colour of its severity, with a button that acknowledges it.
+func (s *Slack) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	footer := fmt.Sprintf("Received %s", chatTime(n.Timestamp))
+	if n.Severity != "" {
+		footer = fmt.Sprintf("Severity *%s* | %s", slackEscaper.Replace(n.Severity), footer)
+	}
+
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: truncate(n.Node.Name, slackMaxHeader)}},
+		{Type: "section", Text: &slackText{Type: "mrkdwn", Text: truncate(slackEscaper.Replace(n.Text), slackMaxSection)}},
+		{Type: "context", Elements: []interface{}{slackText{Type: "mrkdwn", Text: footer}}},
+	}
+
+	if n.AcknowledgeURL != "" {
+		blocks = append(blocks, slackBlock{Type: "actions", Elements: []interface{}{slackButton{
+			Type:  "button",
+			Text:  slackText{Type: "plain_text", Text: "Acknowledge"},
+			URL:   n.AcknowledgeURL,
+			Style: "primary",
+		}}})
+	}
+
+	body, err := json.Marshal(slackMessage{
+		Text:        slackEscaper.Replace(n.Subject),
+		Attachments: []slackAttachment{{Color: urgencyColour(n.Urgency), Blocks: blocks}},
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal slack message: %w", err))
+	}
+
+	return postJSON(ctx, s.client, cp.Address, body, nil)
+}
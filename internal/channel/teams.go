@@ -0,0 +1,121 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// teamsMessage is the body posted to a Microsoft Teams incoming webhook.
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+// teamsAttachment is an attachment of a Teams message holding an Adaptive Card.
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+// teamsCard is an Adaptive Card.
+type teamsCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []interface{} `json:"body"`
+	Actions []teamsAction `json:"actions,omitempty"`
+}
+
+// teamsTextBlock is an Adaptive Card TextBlock element.
+type teamsTextBlock struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	Size   string `json:"size,omitempty"`
+	Weight string `json:"weight,omitempty"`
+	Color  string `json:"color,omitempty"`
+	Wrap   bool   `json:"wrap"`
+}
+
+// teamsFactSet is an Adaptive Card FactSet element.
+type teamsFactSet struct {
+	Type  string      `json:"type"`
+	Facts []teamsFact `json:"facts"`
+}
+
+// teamsFact is a fact within a teamsFactSet.
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// teamsAction is an Adaptive Card Action.OpenUrl action.
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// teamsColour returns the Adaptive Card colour, which are named rather than given in hex,
+// that the heading of a notification of the given urgency is given.
+func teamsColour(u Urgency) string {
+	switch u {
+	case UrgencyCritical:
+		return "Attention"
+	case UrgencyWarning:
+		return "Warning"
+	default:
+		return "Accent"
+	}
+}
+
+// Teams is the Channel that posts notifications as Adaptive Cards to the Microsoft Teams
+// incoming webhook URL of a contact point.
+type Teams struct {
+	client *http.Client
+}
+
+// NewTeams returns a reference to a Teams channel, which gives up on a card that the
+// incoming webhook hasn't accepted within the timeout.
+func NewTeams(timeout time.Duration) *Teams {
+	return &Teams{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The card is headed by the name of the node in
+// the colour of its severity, with an action that acknowledges it.
+func (t *Teams) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	facts := []teamsFact{{Title: "Received", Value: chatTime(n.Timestamp)}}
+	if n.Severity != "" {
+		facts = append([]teamsFact{{Title: "Severity", Value: n.Severity}}, facts...)
+	}
+
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []interface{}{
+			teamsTextBlock{Type: "TextBlock", Text: n.Node.Name, Size: "Large", Weight: "Bolder", Color: teamsColour(n.Urgency), Wrap: true},
+			teamsTextBlock{Type: "TextBlock", Text: n.Text, Wrap: true},
+			teamsFactSet{Type: "FactSet", Facts: facts},
+		},
+	}
+
+	if n.AcknowledgeURL != "" {
+		card.Actions = []teamsAction{{Type: "Action.OpenUrl", Title: "Acknowledge", URL: n.AcknowledgeURL}}
+	}
+
+	body, err := json.Marshal(teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal teams message: %w", err))
+	}
+
+	return postJSON(ctx, t.client, cp.Address, body, nil)
+}
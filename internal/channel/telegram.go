@@ -0,0 +1,169 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTelegramAPIURL is the base URL of the Telegram Bot API that a Telegram channel
+// sends messages through when it's created without one.
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
+// telegramAcknowledgePrefix prefixes the ID of the delivery within the callback data of
+// the buttons that acknowledge notifications.
+const telegramAcknowledgePrefix = "ack:"
+
+// telegramMaxText is the limit that Telegram places on the text of a message, leaving
+// room for the title, footer and the escaping of MarkdownV2.
+const telegramMaxText = 3000
+
+// telegramEscaper escapes the characters that Telegram treats as markup within
+// MarkdownV2 text.
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "~", `\~`,
+	"`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`, "|", `\|`, "{", `\{`,
+	"}", `\}`, ".", `\.`, "!", `\!`)
+
+// telegramMessage is the body of a sendMessage request to the Telegram Bot API.
+type telegramMessage struct {
+	ChatID                string                `json:"chat_id"`
+	Text                  string                `json:"text"`
+	ParseMode             string                `json:"parse_mode"`
+	DisableWebPagePreview bool                  `json:"disable_web_page_preview"`
+	ReplyMarkup           *telegramInlineMarkup `json:"reply_markup,omitempty"`
+}
+
+// telegramInlineMarkup is an inline keyboard shown beneath a Telegram message.
+type telegramInlineMarkup struct {
+	InlineKeyboard [][]telegramButton `json:"inline_keyboard"`
+}
+
+// telegramButton is a button of an inline keyboard that either opens a URL or sends its
+// callback data to the bot.
+type telegramButton struct {
+	Text         string `json:"text"`
+	URL          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// telegramCallbackAnswer is the body of an answerCallbackQuery request to the Telegram
+// Bot API.
+type telegramCallbackAnswer struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text"`
+}
+
+// TelegramConfig represents the bot that a Telegram channel sends messages as.
+type TelegramConfig struct {
+	// APIURL is the base URL of the Bot API, defaulting to DefaultTelegramAPIURL.
+	APIURL string
+
+	BotToken string
+
+	// Callbacks has the acknowledge buttons of messages send a callback query to the bot,
+	// which must then be answered through the bot's webhook, rather than open the link
+	// that acknowledges the notification.
+	Callbacks bool
+}
+
+// Telegram is the Channel that sends notifications as messages from a Telegram bot to
+// the chat whose ID is the address of a contact point.
+type Telegram struct {
+	client *http.Client
+	cfg    TelegramConfig
+}
+
+// NewTelegram returns a reference to a Telegram channel that sends messages as the
+// configured bot, giving up on the Bot API after the given timeout or
+// DefaultWebhookTimeout when it's zero.
+func NewTelegram(cfg TelegramConfig, timeout time.Duration) *Telegram {
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultTelegramAPIURL
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+
+	return &Telegram{client: newHTTPClient(timeout), cfg: cfg}
+}
+
+// Deliver implements the Channel interface. The message is formatted with MarkdownV2,
+// headed by the name of the node, with an inline button that acknowledges it, either
+// through a callback query or by opening its acknowledge link. Chats that the bot can't
+// send to, such as those it isn't a member of, fail permanently.
+func (t *Telegram) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	footer := fmt.Sprintf("Received %s", chatTime(n.Timestamp))
+	if n.Severity != "" {
+		footer = fmt.Sprintf("Severity %s | %s", n.Severity, footer)
+	}
+
+	msg := telegramMessage{
+		ChatID: cp.Address,
+		Text: fmt.Sprintf("*%s*\n%s\n\n_%s_",
+			telegramEscaper.Replace(n.Node.Name),
+			telegramEscaper.Replace(truncate(n.Text, telegramMaxText)),
+			telegramEscaper.Replace(footer)),
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+	}
+
+	switch {
+	case t.cfg.Callbacks && n.DeliveryID != 0:
+		msg.ReplyMarkup = &telegramInlineMarkup{
+			InlineKeyboard: [][]telegramButton{{{Text: "Acknowledge", CallbackData: TelegramCallbackData(n.DeliveryID)}}},
+		}
+	case n.AcknowledgeURL != "":
+		msg.ReplyMarkup = &telegramInlineMarkup{
+			InlineKeyboard: [][]telegramButton{{{Text: "Acknowledge", URL: n.AcknowledgeURL}}},
+		}
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal telegram message: %w", err))
+	}
+
+	return postJSON(ctx, t.client, t.method("sendMessage"), body, nil)
+}
+
+// AnswerCallback answers the callback query with the given ID, showing the given text to
+// the user who pressed the button.
+func (t *Telegram) AnswerCallback(ctx context.Context, id, text string) error {
+	body, err := json.Marshal(telegramCallbackAnswer{CallbackQueryID: id, Text: text})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal telegram callback answer: %w", err))
+	}
+
+	return postJSON(ctx, t.client, t.method("answerCallbackQuery"), body, nil)
+}
+
+// method returns the URL of the Bot API method with the given name.
+func (t *Telegram) method(name string) string {
+	return fmt.Sprintf("%s/bot%s/%s", t.cfg.APIURL, t.cfg.BotToken, name)
+}
+
+// TelegramCallbackData returns the callback data of the button that acknowledges the
+// notification delivered by the delivery with the given ID.
+func TelegramCallbackData(deliveryID int) string {
+	return telegramAcknowledgePrefix + strconv.Itoa(deliveryID)
+}
+
+// ParseTelegramCallback returns the ID of the delivery within the callback data of a
+// button that acknowledges a notification, and whether or not the data is of one. Callback
+// data can be forged by clients, so the chat that the callback came from must be checked
+// against the delivery.
+func ParseTelegramCallback(data string) (int, bool) {
+	if !strings.HasPrefix(data, telegramAcknowledgePrefix) {
+		return 0, false
+	}
+
+	deliveryID, err := strconv.Atoi(strings.TrimPrefix(data, telegramAcknowledgePrefix))
+	if err != nil || deliveryID <= 0 {
+		return 0, false
+	}
+
+	return deliveryID, true
+}
@@ -0,0 +1,161 @@
+package channel_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// telegramMessage is the message sent through the Bot API within tests.
+type telegramMessage struct {
+	ChatID      string `json:"chat_id"`
+	Text        string `json:"text"`
+	ParseMode   string `json:"parse_mode"`
+	ReplyMarkup struct {
+		InlineKeyboard [][]map[string]string `json:"inline_keyboard"`
+	} `json:"reply_markup"`
+}
+
+// deliverTelegram delivers the given notification through a Telegram channel with the
+// given config to a receiver standing in for the Bot API, returning the request that it
+// received along with the message sent.
+func deliverTelegram(t *testing.T, cfg channel.TelegramConfig, n channel.Notification) (*request, telegramMessage) {
+	t.Helper()
+
+	u, req := receive(t, http.StatusOK, `{"ok":true}`)
+	cfg.APIURL, cfg.BotToken = u, "123:abc"
+
+	if err := channel.NewTelegram(cfg, time.Second).Deliver(context.Background(), channel.ContactPoint{Address: "-1001234567890"}, n); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	var msg telegramMessage
+	req.decode(t, &msg)
+
+	return req, msg
+}
+
+// TestTelegramDeliver tests that the Telegram channel sends a MarkdownV2 message with an
+// acknowledge button to the chat of the contact point through the configured Bot API.
+func TestTelegramDeliver(t *testing.T) {
+	t.Parallel()
+
+	req, msg := deliverTelegram(t, channel.TelegramConfig{}, notification)
+
+	if e, a := "/bot123:abc/sendMessage", req.path; e != a {
+		t.Errorf("expected path %q, got %q", e, a)
+	}
+
+	if e, a := "-1001234567890", msg.ChatID; e != a {
+		t.Errorf("expected chat id %q, got %q", e, a)
+	}
+
+	if e, a := "MarkdownV2", msg.ParseMode; e != a {
+		t.Errorf("expected parse mode %q, got %q", e, a)
+	}
+
+	expected := "*Reservoir*\nWater level <high\\> & rising\n\n_Severity critical \\| Received Thu, 04 Mar 2021 05:06:07 UTC_"
+	if e, a := expected, msg.Text; e != a {
+		t.Errorf("expected text %q, got %q", e, a)
+	}
+
+	button := map[string]string{"text": "Acknowledge", "url": notification.AcknowledgeURL}
+	if e, a := [][]map[string]string{{button}}, msg.ReplyMarkup.InlineKeyboard; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected inline keyboard %v, got %v", e, a)
+	}
+}
+
+// TestTelegramDeliverCallbacks tests that the Telegram channel's acknowledge button sends
+// a callback identifying the delivery when callbacks are enabled, rather than opening the
+// acknowledge link.
+func TestTelegramDeliverCallbacks(t *testing.T) {
+	t.Parallel()
+
+	n := notification
+	n.DeliveryID = 42
+
+	_, msg := deliverTelegram(t, channel.TelegramConfig{Callbacks: true}, n)
+
+	button := map[string]string{"text": "Acknowledge", "callback_data": "ack:42"}
+	if e, a := [][]map[string]string{{button}}, msg.ReplyMarkup.InlineKeyboard; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected inline keyboard %v, got %v", e, a)
+	}
+}
+
+// TestTelegramDeliverChatNotFound tests that the Telegram channel fails permanently when
+// the Bot API rejects the chat, without the bot token within the error.
+func TestTelegramDeliverChatNotFound(t *testing.T) {
+	t.Parallel()
+
+	u, _ := receive(t, http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+
+	tg := channel.NewTelegram(channel.TelegramConfig{APIURL: u, BotToken: "123:abc"}, time.Second)
+	err := tg.Deliver(context.Background(), channel.ContactPoint{Address: "42"}, notification)
+	if !channel.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+// TestTelegramAnswerCallback tests that the Telegram channel answers a callback query
+// through the configured Bot API.
+func TestTelegramAnswerCallback(t *testing.T) {
+	t.Parallel()
+
+	u, req := receive(t, http.StatusOK, `{"ok":true}`)
+
+	tg := channel.NewTelegram(channel.TelegramConfig{APIURL: u, BotToken: "123:abc"}, time.Second)
+	if err := tg.AnswerCallback(context.Background(), "9876", "Acknowledged"); err != nil {
+		t.Fatalf("answer callback: %v", err)
+	}
+
+	if e, a := "/bot123:abc/answerCallbackQuery", req.path; e != a {
+		t.Errorf("expected path %q, got %q", e, a)
+	}
+
+	var answer map[string]string
+	req.decode(t, &answer)
+
+	expected := map[string]string{"callback_query_id": "9876", "text": "Acknowledged"}
+	if e, a := expected, answer; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected answer %v, got %v", e, a)
+	}
+}
+
+// TestParseTelegramCallback tests that only the callback data of acknowledge buttons is
+// parsed into the ID of a delivery.
+func TestParseTelegramCallback(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		data       string
+		deliveryID int
+		ok         bool
+	}{
+		"acknowledge":  {channel.TelegramCallbackData(42), 42, true},
+		"other button": {"snooze:42", 0, false},
+		"not a number": {"ack:abc", 0, false},
+		"not positive": {"ack:0", 0, false},
+		"empty":        {"", 0, false},
+	}
+
+	for name, test := range tests {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			deliveryID, ok := channel.ParseTelegramCallback(test.data)
+			if e, a := test.ok, ok; e != a {
+				t.Errorf("expected ok to be %t, got %t", e, a)
+			}
+
+			if e, a := test.deliveryID, deliveryID; e != a {
+				t.Errorf("expected delivery id %d, got %d", e, a)
+			}
+		})
+	}
+}
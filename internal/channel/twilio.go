@@ -0,0 +1,138 @@
+package channel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTwilioAPIURL is the base URL of the Twilio REST API that a Twilio provider
+// places calls through when it's created without one.
+const DefaultTwilioAPIURL = "https://api.twilio.com"
+
+// twilioGatherTimeout is the number of seconds that a call waits for a keypress after
+// the speech has been read out.
+const twilioGatherTimeout = 10
+
+// twilioLoop is the number of times the speech is read out while waiting for a keypress.
+const twilioLoop = 2
+
+// twiML is the TwiML document that instructs Twilio how to conduct a call.
+type twiML struct {
+	XMLName xml.Name     `xml:"Response"`
+	Gather  *twiMLGather `xml:"Gather,omitempty"`
+	Say     []twiMLSay   `xml:"Say"`
+}
+
+// twiMLGather reads out its speech while collecting the digit pressed by the callee,
+// which it posts to its action.
+type twiMLGather struct {
+	NumDigits int      `xml:"numDigits,attr"`
+	Timeout   int      `xml:"timeout,attr"`
+	Action    string   `xml:"action,attr"`
+	Method    string   `xml:"method,attr"`
+	Say       twiMLSay `xml:"Say"`
+}
+
+// twiMLSay reads out text.
+type twiMLSay struct {
+	Loop int    `xml:"loop,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+// TwilioConfig represents the account that a Twilio provider places calls and sends SMS
+// from.
+type TwilioConfig struct {
+	// APIURL is the base URL of the Twilio REST API, defaulting to DefaultTwilioAPIURL.
+	APIURL string
+
+	AccountSID string
+	AuthToken  string
+
+	// From is the E.164 phone number that calls are placed and SMS are sent from.
+	From string
+}
+
+// Twilio is the VoiceProvider that places calls through the Twilio REST API, reading out
+// their speech with TwiML. It's also the Texter that sends SMS from the same number.
+type Twilio struct {
+	client *http.Client
+	cfg    TwilioConfig
+}
+
+// NewTwilio returns a reference to a Twilio provider that calls and texts from the number
+// of cfg, billed to its account. Twilio is reached at its public REST API unless cfg
+// names another.
+func NewTwilio(cfg TwilioConfig, timeout time.Duration) *Twilio {
+	if cfg.APIURL == "" {
+		cfg.APIURL = DefaultTwilioAPIURL
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+
+	return &Twilio{client: newHTTPClient(timeout), cfg: cfg}
+}
+
+// Call implements the VoiceProvider interface. Numbers that Twilio refuses to call fail
+// permanently.
+func (t *Twilio) Call(ctx context.Context, call Call) error {
+	doc := twiML{}
+	if call.AcknowledgeURL != "" {
+		doc.Gather = &twiMLGather{
+			NumDigits: 1,
+			Timeout:   twilioGatherTimeout,
+			Action:    call.AcknowledgeURL,
+			Method:    http.MethodPost,
+			Say:       twiMLSay{Loop: twilioLoop, Text: call.Speech},
+		}
+		doc.Say = []twiMLSay{{Text: "No key was pressed, the alert has not been acknowledged. Goodbye."}}
+	} else {
+		doc.Say = []twiMLSay{{Loop: twilioLoop, Text: call.Speech}}
+	}
+
+	b, err := xml.Marshal(doc)
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal twiml: %w", err))
+	}
+
+	u := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Calls.json", t.cfg.APIURL, url.PathEscape(t.cfg.AccountSID))
+	form := url.Values{
+		"To":    {call.To},
+		"From":  {t.cfg.From},
+		"Twiml": {string(b)},
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(t.cfg.AccountSID + ":" + t.cfg.AuthToken))
+
+	return postForm(ctx, t.client, u, form, http.Header{"Authorization": {"Basic " + auth}})
+}
+
+// Text implements the Texter interface, sending the body as an SMS from the number that
+// calls are placed from. Numbers that Twilio refuses to text fail permanently.
+func (t *Twilio) Text(ctx context.Context, to, body string) error {
+	u := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.cfg.APIURL, url.PathEscape(t.cfg.AccountSID))
+	form := url.Values{
+		"To":   {to},
+		"From": {t.cfg.From},
+		"Body": {body},
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(t.cfg.AccountSID + ":" + t.cfg.AuthToken))
+
+	return postForm(ctx, t.client, u, form, http.Header{"Authorization": {"Basic " + auth}})
+}
+
+// SayTwiML returns the TwiML document that reads out the given text and hangs up, with
+// which the digit pressed during a call is responded to.
+func SayTwiML(text string) ([]byte, error) {
+	b, err := xml.Marshal(twiML{Say: []twiMLSay{{Text: text}}})
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
@@ -0,0 +1,99 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Providers that place the calls of a Voice channel.
+const (
+	// VoiceProviderTwilio places calls through the Twilio REST API, or one compatible
+	// with it.
+	VoiceProviderTwilio = "twilio"
+
+	// VoiceProviderBridge places calls by posting them to a bridge, such as one in front
+	// of a SIP trunk or a local stand-in during development.
+	VoiceProviderBridge = "bridge"
+)
+
+// VoiceProviders contains the providers that a Voice channel can place calls through.
+var VoiceProviders = []string{VoiceProviderTwilio, VoiceProviderBridge}
+
+// AcknowledgeDigit is the key that the callee presses to acknowledge the notification
+// that they're being called about.
+const AcknowledgeDigit = "1"
+
+// voiceMaxMessage is the longest message that is read out, so that calls stay short.
+const voiceMaxMessage = 500
+
+// Call is a text-to-speech call placed by a VoiceProvider.
+type Call struct {
+	// To is the E.164 phone number being called.
+	To string `json:"to"`
+
+	// Speech is the text that is read out to the callee.
+	Speech string `json:"speech"`
+
+	// AcknowledgeURL is the URL that the digit pressed by the callee is posted to, as the
+	// Digits field of a form, when there is one.
+	AcknowledgeURL string `json:"acknowledgeURL,omitempty"`
+}
+
+// VoiceProvider places text-to-speech calls. Providers read out the speech of a call,
+// repeating it while waiting for a keypress, and post the key pressed to the acknowledge
+// URL of the call. Errors are returned wrapped with Permanent when retrying the call
+// won't resolve them, such as an invalid phone number.
+type VoiceProvider interface {
+	Call(ctx context.Context, call Call) error
+}
+
+// Texter sends SMS. Errors are returned wrapped with Permanent when resending the SMS
+// won't resolve them, such as an invalid phone number.
+type Texter interface {
+	Text(ctx context.Context, to, body string) error
+}
+
+// Voice is the Channel that calls the phone number that is the address of a contact point
+// and reads out the notification, which the callee acknowledges by pressing
+// AcknowledgeDigit.
+type Voice struct {
+	provider VoiceProvider
+}
+
+// NewVoice returns a reference to a Voice channel that places calls through the given
+// provider.
+func NewVoice(provider VoiceProvider) *Voice {
+	return &Voice{provider: provider}
+}
+
+// Deliver implements the Channel interface. The notification is delivered once the call
+// has been placed, rather than answered.
+func (v *Voice) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	return v.provider.Call(ctx, Call{
+		To:             cp.Address,
+		Speech:         voiceSpeech(n),
+		AcknowledgeURL: n.AcknowledgeURL,
+	})
+}
+
+// voiceSpeech returns the text read out in a call about the notification: the name of
+// its node, its severity and its message, followed by how to acknowledge it.
+func voiceSpeech(n Notification) string {
+	message := n.SMS
+	if message == "" {
+		message = n.Message
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "This is an alert from %s.", n.Node.Name)
+	if n.Severity != "" {
+		fmt.Fprintf(&b, " Severity %s.", n.Severity)
+	}
+	fmt.Fprintf(&b, " %s.", strings.TrimRight(truncate(message, voiceMaxMessage), "."))
+	if n.AcknowledgeURL != "" {
+		fmt.Fprintf(&b, " Press %s to acknowledge this alert.", AcknowledgeDigit)
+	}
+
+	return b.String()
+}
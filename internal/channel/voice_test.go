@@ -0,0 +1,117 @@
+package channel_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// voiceSpeech is the speech read out for the notification.
+const voiceSpeech = "This is an alert from Reservoir. Severity critical. Water level high. Press 1 to acknowledge this alert."
+
+// newTwilio returns a reference to a Twilio provider whose account places calls and
+// sends SMS through the REST API at the given URL.
+func newTwilio(url string) *channel.Twilio {
+	return channel.NewTwilio(channel.TwilioConfig{
+		APIURL:     url,
+		AccountSID: "AC123",
+		AuthToken:  "secret",
+		From:       "+15555550100",
+	}, time.Second)
+}
+
+// TestVoiceDeliverTwilio tests that the Twilio provider places a call that reads out the
+// notification and gathers the digit pressed for the acknowledge URL.
+func TestVoiceDeliverTwilio(t *testing.T) {
+	t.Parallel()
+
+	u, req := receive(t, http.StatusCreated, "{}")
+	if err := channel.NewVoice(newTwilio(u)).Deliver(context.Background(), channel.ContactPoint{Address: "+15555550123"}, notification); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	if e, a := "/2010-04-01/Accounts/AC123/Calls.json", req.path; e != a {
+		t.Errorf("expected path %q, got %q", e, a)
+	}
+
+	if user, pass, ok := (&http.Request{Header: req.header}).BasicAuth(); !ok || user != "AC123" || pass != "secret" {
+		t.Errorf("expected basic auth AC123:secret, got %s:%s", user, pass)
+	}
+
+	form := req.form(t)
+
+	if e, a := "+15555550123", form.Get("To"); e != a {
+		t.Errorf("expected to %q, got %q", e, a)
+	}
+
+	if e, a := "+15555550100", form.Get("From"); e != a {
+		t.Errorf("expected from %q, got %q", e, a)
+	}
+
+	expected := `<Response><Gather numDigits="1" timeout="10" action="https://example.com/acknowledge?token=abc" method="POST">` +
+		`<Say loop="2">` + voiceSpeech + `</Say></Gather>`
+	if twiml := form.Get("Twiml"); !strings.HasPrefix(twiml, expected) {
+		t.Errorf("expected twiml to start with %s, got %s", expected, twiml)
+	}
+}
+
+// TestVoiceDeliverBridge tests that the bridge provider posts the call to the bridge.
+func TestVoiceDeliverBridge(t *testing.T) {
+	t.Parallel()
+
+	u, req := receive(t, http.StatusOK, "")
+	if err := channel.NewVoice(channel.NewVoiceBridge(u, time.Second)).Deliver(context.Background(), channel.ContactPoint{Address: "+15555550123"}, notification); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	var call channel.Call
+	req.decode(t, &call)
+
+	expected := channel.Call{To: "+15555550123", Speech: voiceSpeech, AcknowledgeURL: notification.AcknowledgeURL}
+	if e, a := expected, call; e != a {
+		t.Errorf("expected call %+v, got %+v", e, a)
+	}
+}
+
+// TestTwilioText tests that the Twilio provider sends an SMS from the number that it
+// places calls from.
+func TestTwilioText(t *testing.T) {
+	t.Parallel()
+
+	u, req := receive(t, http.StatusCreated, "{}")
+	if err := newTwilio(u).Text(context.Background(), "+15555550123", "Your code is 123456."); err != nil {
+		t.Fatalf("text: %v", err)
+	}
+
+	if e, a := "/2010-04-01/Accounts/AC123/Messages.json", req.path; e != a {
+		t.Errorf("expected path %q, got %q", e, a)
+	}
+
+	form := req.form(t)
+
+	if e, a := "+15555550123", form.Get("To"); e != a {
+		t.Errorf("expected to %q, got %q", e, a)
+	}
+
+	if e, a := "+15555550100", form.Get("From"); e != a {
+		t.Errorf("expected from %q, got %q", e, a)
+	}
+
+	if e, a := "Your code is 123456.", form.Get("Body"); e != a {
+		t.Errorf("expected body %q, got %q", e, a)
+	}
+}
+
+// TestTwilioTextRejected tests that an SMS that Twilio refuses to send fails permanently.
+func TestTwilioTextRejected(t *testing.T) {
+	t.Parallel()
+
+	u, _ := receive(t, http.StatusBadRequest, "{}")
+	if err := newTwilio(u).Text(context.Background(), "+15555550123", "Your code is 123456."); !channel.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
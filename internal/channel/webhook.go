@@ -0,0 +1,82 @@
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers that webhook events are posted with, so that receivers can verify that an event
+// was sent by the daemon and recently.
+const (
+	// HeaderTimestamp holds the time the event was posted at, in seconds since the epoch.
+	HeaderTimestamp = "X-Lorafication-Timestamp"
+
+	// HeaderSignature holds the signature of the event, as returned by Sign.
+	HeaderSignature = "X-Lorafication-Signature"
+)
+
+// Event is the JSON body posted to webhooks for a notification.
+type Event struct {
+	ID        int                    `json:"id"`
+	Node      Node                   `json:"node"`
+	Message   string                 `json:"message"`
+	Severity  string                 `json:"severity"`
+	Timestamp time.Time              `json:"timestamp"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// Webhook is the Channel that posts notifications as JSON events to the URL of a contact
+// point, signing them with the contact point's secret.
+type Webhook struct {
+	client *http.Client
+}
+
+// NewWebhook returns a reference to a Webhook channel. A receiver that hasn't responded
+// within the timeout fails the delivery, which is then retried.
+func NewWebhook(timeout time.Duration) *Webhook {
+	return &Webhook{client: newHTTPClient(timeout)}
+}
+
+// Deliver implements the Channel interface. The event is delivered once the webhook
+// responds with a 2xx status. Timeouts, 5xx, 408 and 429 statuses are worth retrying,
+// whereas any other status fails permanently.
+func (wh *Webhook) Deliver(ctx context.Context, cp ContactPoint, n Notification) error {
+	body, err := json.Marshal(Event{
+		ID:        n.ID,
+		Node:      n.Node,
+		Message:   n.Message,
+		Severity:  n.Severity,
+		Timestamp: n.Timestamp,
+		Payload:   n.Payload,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("marshal event: %w", err))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	return postJSON(ctx, wh.client, cp.Address, body, http.Header{
+		HeaderTimestamp: {timestamp},
+		HeaderSignature: {"sha256=" + Sign(cp.Secret, timestamp, body)},
+	})
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the timestamp and body of a webhook event,
+// joined by a period, keyed by the secret of the webhook. Receivers verify an event by
+// computing the same signature and comparing it with the one in HeaderSignature, after
+// its "sha256=" prefix, rejecting events whose timestamp is too old.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,145 @@
+package channel_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/channel"
+)
+
+// TestWebhookDeliver tests that a Webhook posts the notification as a JSON event signed
+// with the secret of the contact point.
+func TestWebhookDeliver(t *testing.T) {
+	t.Parallel()
+
+	const secret = "s3cr3t"
+
+	u, req := receive(t, http.StatusNoContent, "")
+
+	wh := channel.NewWebhook(time.Second)
+	if err := wh.Deliver(context.Background(), channel.ContactPoint{Address: u, Secret: secret}, notification); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	header := req.header
+
+	if e, a := "application/json", header.Get("Content-Type"); e != a {
+		t.Errorf("expected content type %q, got %q", e, a)
+	}
+
+	timestamp := header.Get(channel.HeaderTimestamp)
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("expected a recent timestamp, got %q", timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(req.body)
+	if e, a := "sha256="+hex.EncodeToString(mac.Sum(nil)), header.Get(channel.HeaderSignature); e != a {
+		t.Errorf("expected signature %q, got %q", e, a)
+	}
+
+	var event channel.Event
+	req.decode(t, &event)
+
+	if e, a := notification.ID, event.ID; e != a {
+		t.Errorf("expected id %d, got %d", e, a)
+	}
+
+	if e, a := notification.Node, event.Node; e != a {
+		t.Errorf("expected node %+v, got %+v", e, a)
+	}
+
+	if e, a := notification.Message, event.Message; e != a {
+		t.Errorf("expected message %q, got %q", e, a)
+	}
+
+	if e, a := notification.Severity, event.Severity; e != a {
+		t.Errorf("expected severity %q, got %q", e, a)
+	}
+
+	if e, a := notification.Timestamp, event.Timestamp; !e.Equal(a) {
+		t.Errorf("expected timestamp %v, got %v", e, a)
+	}
+
+	if e, a := 9.5, event.Payload["level"]; e != a {
+		t.Errorf("expected payload level %v, got %v", e, a)
+	}
+}
+
+// TestWebhookDeliverStatus tests which statuses a webhook may respond with fail the
+// delivery, and whether they fail it permanently.
+func TestWebhookDeliverStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status    int
+		failed    bool
+		permanent bool
+	}{
+		{http.StatusOK, false, false},
+		{http.StatusAccepted, false, false},
+		{http.StatusFound, true, true},
+		{http.StatusBadRequest, true, true},
+		{http.StatusNotFound, true, true},
+		{http.StatusRequestTimeout, true, false},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusInternalServerError, true, false},
+		{http.StatusServiceUnavailable, true, false},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(strconv.Itoa(test.status), func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer srv.Close()
+
+			err := channel.NewWebhook(time.Second).Deliver(context.Background(), channel.ContactPoint{Address: srv.URL}, notification)
+
+			if e, a := test.failed, err != nil; e != a {
+				t.Errorf("expected failure to be %t, got %t (%v)", e, a, err)
+			}
+
+			if e, a := test.permanent, channel.IsPermanent(err); e != a {
+				t.Errorf("expected permanence to be %t, got %t (%v)", e, a, err)
+			}
+		})
+	}
+}
+
+// TestWebhookDeliverTimeout tests that a webhook that doesn't respond within the timeout
+// fails the delivery, although not permanently.
+func TestWebhookDeliverTimeout(t *testing.T) {
+	t.Parallel()
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	err := channel.NewWebhook(50*time.Millisecond).Deliver(context.Background(), channel.ContactPoint{Address: srv.URL}, notification)
+	if err == nil {
+		t.Fatal("expected delivery to time out")
+	}
+
+	if channel.IsPermanent(err) {
+		t.Errorf("expected timeout to not be permanent, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// loginAuth implements the non-standard, but widely deployed, LOGIN authentication
+// mechanism, which net/smtp doesn't.
+type loginAuth struct {
+	host string
+	user string
+	pass string
+}
+
+// Start implements the smtp.Auth interface. Like smtp.PlainAuth, it refuses to send
+// credentials over an unencrypted connection to anything but localhost.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// Next implements the smtp.Auth interface.
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.user), nil
+	case "password:":
+		return []byte(a.pass), nil
+	}
+
+	return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+}
+
+// isLocalhost reports whether or not name refers to the local host.
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
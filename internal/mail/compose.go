@@ -0,0 +1,142 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrHeaderInjection is the error returned when composing a message whose header values
+// contain line breaks, which would otherwise allow arbitrary headers to be injected.
+var ErrHeaderInjection = errors.New("header value contains a line break")
+
+// header is a single header of a composed message. Headers are kept in a slice, rather
+// than a map, so that they're written in a stable order.
+type header struct {
+	key, value string
+}
+
+// Compose returns the given message, sent from the given sender, formed as an RFC 5322
+// email. Messages with both a text and an HTML body are composed as multipart/alternative,
+// while messages with only one of them are composed as a single part. The subject is RFC
+// 2047 encoded when it isn't plain ASCII.
+//
+// The HTML body is written as given, so any untrusted content within it must already be
+// escaped, e.g. by rendering it with html/template.
+func Compose(from Sender, msg Message) ([]byte, error) {
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, fmt.Errorf("generate message ID: %w", err)
+	}
+
+	headers := []header{
+		{"From", from.header()},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+	}
+
+	if from.ReplyTo != "" {
+		headers = append(headers, header{"Reply-To", from.ReplyTo})
+	}
+
+	if msg.UnsubscribeURL != "" {
+		headers = append(headers,
+			header{"List-Unsubscribe", fmt.Sprintf("<%s>", msg.UnsubscribeURL)},
+			header{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"})
+	}
+
+	var body bytes.Buffer
+	switch {
+	case msg.Text != "" && msg.HTML != "":
+		mpw := multipart.NewWriter(&body)
+		headers = append(headers, header{"Content-Type", mime.FormatMediaType("multipart/alternative",
+			map[string]string{"boundary": mpw.Boundary()})})
+
+		// Parts are ordered from the least to the most preferred, per RFC 2046.
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain", msg.Text},
+			{"text/html", msg.HTML},
+		} {
+			pw, err := mpw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType + "; charset=utf-8"},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("create %s part: %w", part.contentType, err)
+			}
+
+			if err := writeQuotedPrintable(pw, part.content); err != nil {
+				return nil, fmt.Errorf("write %s part: %w", part.contentType, err)
+			}
+		}
+
+		if err := mpw.Close(); err != nil {
+			return nil, fmt.Errorf("close multipart writer: %w", err)
+		}
+	default:
+		contentType, content := "text/plain", msg.Text
+		if msg.HTML != "" {
+			contentType, content = "text/html", msg.HTML
+		}
+
+		headers = append(headers,
+			header{"Content-Type", contentType + "; charset=utf-8"},
+			header{"Content-Transfer-Encoding", "quoted-printable"})
+
+		if err := writeQuotedPrintable(&body, content); err != nil {
+			return nil, fmt.Errorf("write body: %w", err)
+		}
+	}
+
+	var email bytes.Buffer
+	for _, h := range headers {
+		if strings.ContainsAny(h.value, "\r\n") {
+			return nil, fmt.Errorf("%s: %w", h.key, ErrHeaderInjection)
+		}
+
+		fmt.Fprintf(&email, "%s: %s\r\n", h.key, h.value)
+	}
+	email.WriteString("\r\n")
+	email.Write(body.Bytes())
+
+	return email.Bytes(), nil
+}
+
+// writeQuotedPrintable writes content to w using the quoted-printable encoding.
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qpw := quotedprintable.NewWriter(w)
+
+	if _, err := qpw.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return qpw.Close()
+}
+
+// newMessageID returns a new, random, Message-ID within the domain of the given address,
+// falling back to localhost when it doesn't have one.
+func newMessageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i != -1 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
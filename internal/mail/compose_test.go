@@ -0,0 +1,161 @@
+// Package mail_test tests the mail package.
+package mail_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+)
+
+// TestComposeMultipart tests that messages with text and HTML bodies are composed as
+// multipart/alternative with well formed headers.
+func TestComposeMultipart(t *testing.T) {
+	t.Parallel()
+
+	sender := lmail.Sender{Address: "alerts@example.com", Name: "LoRafication Alerts", ReplyTo: "support@example.com"}
+	email, err := lmail.Compose(sender, lmail.Message{
+		To:             "user@example.com",
+		Subject:        "LoRafication: Notification from Zürich Node",
+		Text:           "Water level high",
+		HTML:           "<p>Water level high</p>",
+		UnsubscribeURL: "https://example.com/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(email))
+	if err != nil {
+		t.Fatalf("read composed message: %v", err)
+	}
+
+	if e, a := `"LoRafication Alerts" <alerts@example.com>`, msg.Header.Get("From"); e != a {
+		t.Errorf("expected From to be %q, got %q", e, a)
+	}
+
+	if e, a := "support@example.com", msg.Header.Get("Reply-To"); e != a {
+		t.Errorf("expected Reply-To to be %q, got %q", e, a)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+
+	if e, a := "LoRafication: Notification from Zürich Node", subject; e != a {
+		t.Errorf("expected subject to be %q, got %q", e, a)
+	}
+
+	if _, err := msg.Header.Date(); err != nil {
+		t.Errorf("expected a valid Date header, got %v", err)
+	}
+
+	if e, a := "@example.com>", msg.Header.Get("Message-ID"); !strings.HasSuffix(a, e) {
+		t.Errorf("expected Message-ID to end with %q, got %q", e, a)
+	}
+
+	if e, a := "<https://example.com/unsubscribe?token=abc>", msg.Header.Get("List-Unsubscribe"); e != a {
+		t.Errorf("expected List-Unsubscribe to be %q, got %q", e, a)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+
+	if e, a := "multipart/alternative", mediaType; e != a {
+		t.Fatalf("expected media type to be %q, got %q", e, a)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", "Water level high"},
+		{"text/html; charset=utf-8", "<p>Water level high</p>"},
+	} {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			t.Fatalf("read %s part: %v", expected.contentType, err)
+		}
+
+		if e, a := expected.contentType, part.Header.Get("Content-Type"); e != a {
+			t.Errorf("expected part content type to be %q, got %q", e, a)
+		}
+
+		content, err := ioutil.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("read %s part content: %v", expected.contentType, err)
+		}
+
+		if e, a := expected.content, string(content); e != a {
+			t.Errorf("expected %s part content to be %q, got %q", expected.contentType, e, a)
+		}
+	}
+}
+
+// TestComposeSinglePart tests that messages with only a text body are composed as a
+// single text/plain part.
+func TestComposeSinglePart(t *testing.T) {
+	t.Parallel()
+
+	email, err := lmail.Compose(lmail.Sender{Address: "alerts@example.com"}, lmail.Message{
+		To:      "user@example.com",
+		Subject: "Plain",
+		Text:    "Water level high",
+	})
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(email))
+	if err != nil {
+		t.Fatalf("read composed message: %v", err)
+	}
+
+	if e, a := "text/plain; charset=utf-8", msg.Header.Get("Content-Type"); e != a {
+		t.Errorf("expected content type to be %q, got %q", e, a)
+	}
+
+	if e, a := "Plain", msg.Header.Get("Subject"); e != a {
+		t.Errorf("expected ASCII subject to be left unencoded as %q, got %q", e, a)
+	}
+}
+
+// TestComposeHeaderInjection tests that line breaks within header values are encoded or
+// rejected, rather than injecting headers.
+func TestComposeHeaderInjection(t *testing.T) {
+	t.Parallel()
+
+	email, err := lmail.Compose(lmail.Sender{Address: "alerts@example.com"}, lmail.Message{
+		To:      "user@example.com",
+		Subject: "Hello\r\nBcc: victim@example.com",
+		Text:    "Water level high",
+	})
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(email))
+	if err != nil {
+		t.Fatalf("read composed message: %v", err)
+	}
+
+	if a := msg.Header.Get("Bcc"); a != "" {
+		t.Errorf("expected no Bcc header to be injected through the subject, got %q", a)
+	}
+
+	_, err = lmail.Compose(lmail.Sender{Address: "alerts@example.com"}, lmail.Message{
+		To:   "user@example.com\r\nBcc: victim@example.com",
+		Text: "Water level high",
+	})
+	if !errors.Is(err, lmail.ErrHeaderInjection) {
+		t.Errorf("expected recipient with a line break to be rejected, got %v", err)
+	}
+}
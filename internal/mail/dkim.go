@@ -0,0 +1,239 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// DKIM signing algorithms, chosen by the type of the signing key.
+const (
+	DKIMAlgorithmRSA     = "rsa-sha256"
+	DKIMAlgorithmEd25519 = "ed25519-sha256"
+)
+
+// dkimMinRSABits is the minimum size of RSA keys that verifiers must accept, per RFC 8301.
+const dkimMinRSABits = 1024
+
+// dkimHeaders are the headers that are signed when present, From being required.
+var dkimHeaders = []string{
+	"From", "Reply-To", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMSigner signs emails with DKIM, per RFC 6376 and RFC 8463, using the relaxed header
+// and body canonicalization algorithms.
+type DKIMSigner struct {
+	domain    string
+	selector  string
+	key       crypto.Signer
+	algorithm string
+}
+
+// NewDKIMSigner returns a DKIMSigner that signs on behalf of the given domain with the
+// given RSA or Ed25519 private key, whose public key is published at the given selector.
+func NewDKIMSigner(domain, selector string, key crypto.Signer) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("domain and selector must be defined")
+	}
+
+	var algorithm string
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < dkimMinRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", dkimMinRSABits)
+		}
+		algorithm = DKIMAlgorithmRSA
+	case ed25519.PrivateKey:
+		algorithm = DKIMAlgorithmEd25519
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return &DKIMSigner{
+		domain:    domain,
+		selector:  selector,
+		key:       key,
+		algorithm: algorithm,
+	}, nil
+}
+
+// LoadDKIMKey reads a PEM encoded PKCS #1 RSA or PKCS #8 RSA or Ed25519 private key from
+// the file at the given path.
+func LoadDKIMKey(path string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("key file contains no pem encoded key")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse pkcs8 key: %w", err)
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported pem block type %q", block.Type)
+}
+
+// Sign returns the given email, as composed by Compose, with a DKIM-Signature header
+// prepended to it.
+func (s *DKIMSigner) Sign(email []byte) ([]byte, error) {
+	i := bytes.Index(email, []byte("\r\n\r\n"))
+	if i == -1 {
+		return nil, errors.New("email has no header and body separator")
+	}
+	header, body := email[:i+2], email[i+4:]
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	fields := parseHeader(header)
+
+	var (
+		names  []string
+		signed bytes.Buffer
+	)
+	for _, name := range dkimHeaders {
+		// Only the last instance of a header is signed, as verifiers select instances
+		// from the bottom up.
+		for j := len(fields) - 1; j >= 0; j-- {
+			if strings.EqualFold(fields[j].key, name) {
+				names = append(names, strings.ToLower(name))
+				signed.WriteString(relaxedHeader(fields[j].key, fields[j].value))
+				break
+			}
+		}
+	}
+
+	if len(names) == 0 || names[0] != "from" {
+		return nil, errors.New("email has no From header")
+	}
+
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.algorithm, s.domain, s.selector, time.Now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	// The DKIM-Signature header itself is signed with an empty signature and without a
+	// trailing CRLF.
+	signed.WriteString(strings.TrimSuffix(relaxedHeader("DKIM-Signature", value), "\r\n"))
+	digest := sha256.Sum256(signed.Bytes())
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if s.algorithm == DKIMAlgorithmEd25519 {
+		// Ed25519 signs the SHA-256 digest itself, per RFC 8463.
+		opts = crypto.Hash(0)
+	}
+
+	sig, err := s.key.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+
+	var signedEmail bytes.Buffer
+	fmt.Fprintf(&signedEmail, "DKIM-Signature: %s%s\r\n", value, base64.StdEncoding.EncodeToString(sig))
+	signedEmail.Write(email)
+
+	return signedEmail.Bytes(), nil
+}
+
+// parseHeader splits an email's header into its fields, keeping the values of folded
+// fields as they are.
+func parseHeader(raw []byte) []header {
+	var fields []header
+	for _, line := range strings.SplitAfter(string(raw), "\r\n") {
+		if line == "" {
+			continue
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].value += line
+			continue
+		}
+
+		if i := strings.IndexByte(line, ':'); i != -1 {
+			fields = append(fields, header{key: line[:i], value: line[i+1:]})
+		}
+	}
+
+	return fields
+}
+
+// relaxedHeader returns a header field canonicalized with the relaxed header
+// canonicalization algorithm of RFC 6376, section 3.4.2.
+func relaxedHeader(key, value string) string {
+	value = strings.NewReplacer("\r\n", "", "\n", "").Replace(value)
+
+	return strings.ToLower(strings.TrimSpace(key)) + ":" + strings.TrimSpace(collapseWSP(value)) + "\r\n"
+}
+
+// relaxedBody returns a body canonicalized with the relaxed body canonicalization
+// algorithm of RFC 6376, section 3.4.4.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(collapseWSP(lines[i]), " ")
+	}
+
+	// Empty lines at the end of the body are ignored.
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// collapseWSP replaces each sequence of spaces and tabs within s with a single space.
+func collapseWSP(s string) string {
+	var (
+		b   strings.Builder
+		wsp bool
+	)
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			wsp = true
+			continue
+		}
+
+		if wsp {
+			b.WriteByte(' ')
+			wsp = false
+		}
+		b.WriteRune(r)
+	}
+
+	if wsp {
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,235 @@
+package mail_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+)
+
+// wsp matches sequences of whitespace that relaxed canonicalization collapses.
+var wsp = regexp.MustCompile(`[ \t]+`)
+
+// verifyDKIM verifies the DKIM-Signature header of the given email with the given public
+// key, independently of the mail package's signing code.
+func verifyDKIM(email []byte, pub crypto.PublicKey) error {
+	i := bytes.Index(email, []byte("\r\n\r\n"))
+	if i == -1 {
+		return errors.New("no header and body separator")
+	}
+	rawHeader, body := string(email[:i]), string(email[i+4:])
+
+	// Unfold the header into its fields.
+	type field struct{ name, value string }
+	var fields []field
+	for _, line := range strings.Split(rawHeader, "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			fields[len(fields)-1].value += line
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		fields = append(fields, field{name: parts[0], value: parts[1]})
+	}
+
+	canonHeader := func(f field) string {
+		return strings.ToLower(strings.TrimSpace(f.name)) + ":" + strings.TrimSpace(wsp.ReplaceAllString(f.value, " "))
+	}
+
+	if !strings.EqualFold(fields[0].name, "DKIM-Signature") {
+		return errors.New("no DKIM-Signature header")
+	}
+	sigField := fields[0]
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(sigField.value, ";") {
+		parts := strings.SplitN(strings.TrimSpace(tag), "=", 2)
+		if len(parts) == 2 {
+			tags[parts[0]] = wsp.ReplaceAllString(parts[1], "")
+		}
+	}
+
+	if tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unexpected canonicalization %q", tags["c"])
+	}
+
+	// Verify the body hash.
+	lines := strings.Split(body, "\r\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(wsp.ReplaceAllString(lines[i], " "), " ")
+	}
+	canonBody := strings.TrimRight(strings.Join(lines, "\r\n"), "\r\n")
+	if canonBody != "" {
+		canonBody += "\r\n"
+	}
+
+	bh := sha256.Sum256([]byte(canonBody))
+	if base64.StdEncoding.EncodeToString(bh[:]) != tags["bh"] {
+		return errors.New("body hash mismatch")
+	}
+
+	// Hash the signed headers, selecting instances from the bottom up, followed by the
+	// signature header without its signature.
+	var data strings.Builder
+	used := make(map[int]bool)
+	for _, name := range strings.Split(tags["h"], ":") {
+		for j := len(fields) - 1; j > 0; j-- {
+			if !used[j] && strings.EqualFold(fields[j].name, name) {
+				used[j] = true
+				data.WriteString(canonHeader(fields[j]) + "\r\n")
+				break
+			}
+		}
+	}
+
+	b := strings.LastIndex(sigField.value, "b=")
+	data.WriteString(canonHeader(field{name: sigField.name, value: sigField.value[:b+2]}))
+	digest := sha256.Sum256([]byte(data.String()))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest[:], sig) {
+			return errors.New("ed25519 signature mismatch")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported key type %T", pub)
+}
+
+// TestDKIMSigner tests that emails signed by a DKIMSigner verify with each supported
+// algorithm, tolerate whitespace changes in transit and fail to verify once tampered with.
+func TestDKIMSigner(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm string
+	}{
+		{name: "rsa", key: rsaKey, algorithm: lmail.DKIMAlgorithmRSA},
+		{name: "ed25519", key: edKey, algorithm: lmail.DKIMAlgorithmEd25519},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			signer, err := lmail.NewDKIMSigner("example.com", "alerts", test.key)
+			if err != nil {
+				t.Fatalf("new dkim signer: %v", err)
+			}
+
+			email, err := lmail.Compose(lmail.Sender{Address: "alerts@example.com", Name: "LoRafication Alerts"}, lmail.Message{
+				To:      "user@example.com",
+				Subject: "LoRafication: Notification from Zürich Node",
+				Text:    "Water level   high\t \n\n\n",
+				HTML:    "<p>Water level high</p>",
+			})
+			if err != nil {
+				t.Fatalf("compose: %v", err)
+			}
+
+			signed, err := signer.Sign(email)
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+
+			if e, a := "a="+test.algorithm+";", string(signed); !strings.Contains(a, e) {
+				t.Errorf("expected signature to contain %q", e)
+			}
+
+			if err := verifyDKIM(signed, test.key.Public()); err != nil {
+				t.Errorf("expected signed email to verify, got %v", err)
+			}
+
+			// Relays may change whitespace, which relaxed canonicalization tolerates.
+			rewhitespaced := bytes.Replace(signed, []byte("To: user@example.com"), []byte("To:   user@example.com "), 1)
+			if err := verifyDKIM(rewhitespaced, test.key.Public()); err != nil {
+				t.Errorf("expected email with changed whitespace to verify, got %v", err)
+			}
+
+			tamperedHeader := bytes.Replace(signed, []byte("To: user@example.com"), []byte("To: other@example.com"), 1)
+			if err := verifyDKIM(tamperedHeader, test.key.Public()); err == nil {
+				t.Error("expected email with a tampered header to fail to verify")
+			}
+
+			tamperedBody := bytes.Replace(signed, []byte("Water level high</p>"), []byte("Water level low</p>"), 1)
+			if err := verifyDKIM(tamperedBody, test.key.Public()); err == nil {
+				t.Error("expected email with a tampered body to fail to verify")
+			}
+		})
+	}
+}
+
+// TestLoadDKIMKey tests that PEM encoded RSA and Ed25519 keys load as DKIM signing keys.
+func TestLoadDKIMKey(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatalf("marshal ed25519 key: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+		{Type: "PRIVATE KEY", Bytes: edDER},
+	} {
+		path := filepath.Join(dir, "key.pem")
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatalf("write key file: %v", err)
+		}
+
+		key, err := lmail.LoadDKIMKey(path)
+		if err != nil {
+			t.Errorf("load %s: %v", block.Type, err)
+			continue
+		}
+
+		if _, err := lmail.NewDKIMSigner("example.com", "alerts", key); err != nil {
+			t.Errorf("new dkim signer with %s: %v", block.Type, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaildirTransport is the Transport that writes each email to its own file within the
+// new directory of a maildir, for mail clients to read during development.
+type MaildirTransport struct {
+	seq      uint64 // Accessed atomically, so kept first for 64-bit alignment.
+	dir      string
+	hostname string
+	pid      int
+}
+
+// NewMaildirTransport returns a reference to a MaildirTransport that writes email to the
+// maildir at the given directory, creating it if it doesn't exist.
+func NewMaildirTransport(dir string) (*MaildirTransport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("create maildir: %w", err)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return &MaildirTransport{
+		dir:      dir,
+		hostname: hostname,
+		pid:      os.Getpid(),
+	}, nil
+}
+
+// Send implements the Transport interface. The email is written to the tmp directory and
+// then moved into the new directory, so that mail clients never see partial emails.
+func (t *MaildirTransport) Send(ctx context.Context, from string, to []string, email []byte) error {
+	now := time.Now()
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, t.pid,
+		atomic.AddUint64(&t.seq, 1), t.hostname)
+
+	tmp := filepath.Join(t.dir, "tmp", name)
+	if err := ioutil.WriteFile(tmp, fileEmail(from, to, email), 0600); err != nil {
+		return fmt.Errorf("write email: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(t.dir, "new", name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("deliver email: %w", err)
+	}
+
+	return nil
+}
+
+// Close implements the Transport interface.
+func (t *MaildirTransport) Close() error {
+	return nil
+}
+
+// MboxTransport is the Transport that appends each email to an mbox file, in the mboxrd
+// format, for mail clients to read during development.
+type MboxTransport struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewMboxTransport returns a reference to an MboxTransport that appends email to the mbox
+// file at the given path, creating it if it doesn't exist.
+func NewMboxTransport(path string) (*MboxTransport, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open mbox file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("close mbox file: %w", err)
+	}
+
+	return &MboxTransport{path: path}, nil
+}
+
+// mboxFrom matches the lines of an email that would be mistaken for the "From " line
+// separating emails within an mbox, along with those already escaped.
+var mboxFrom = regexp.MustCompile(`(?m)^(>*From )`)
+
+// Send implements the Transport interface.
+func (t *MboxTransport) Send(ctx context.Context, from string, to []string, email []byte) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From %s %s\n", from, time.Now().UTC().Format(time.ANSIC))
+	b.Write(mboxFrom.ReplaceAll(fileEmail(from, to, email), []byte(">$1")))
+	b.WriteString("\n")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open mbox file: %w", err)
+	}
+
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("write email: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Close implements the Transport interface.
+func (t *MboxTransport) Close() error {
+	return nil
+}
+
+// fileEmail returns the given email as it's stored in a file: with the envelope recorded
+// in Return-Path and Delivered-To headers, as a delivering server would, and with the
+// line endings of the local system rather than CRLF.
+func fileEmail(from string, to []string, email []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Return-Path: <%s>\r\n", from)
+	for _, rcpt := range to {
+		fmt.Fprintf(&b, "Delivered-To: %s\r\n", rcpt)
+	}
+	b.Write(email)
+
+	return bytes.ReplaceAll(b.Bytes(), []byte("\r\n"), []byte("\n"))
+}
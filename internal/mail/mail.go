@@ -1,69 +1,196 @@
 // Package mail facilitates the interaction between the lorafication daemon and
-// the configured SMTP server.
+// the configured mail transport, such as SMTP servers, and bounce mailbox.
 package mail
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"mime/quotedprintable"
-	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Errors returned when the SMTP server doesn't support what the Mailer is configured to
+// require of it.
+var (
+	ErrStartTLSUnsupported = errors.New("smtp server doesn't support STARTTLS")
+	ErrAuthUnsupported     = errors.New("smtp server doesn't support AUTH")
+)
+
+// TLS modes that the SMTP connection may be secured with.
+const (
+	// TLSModeNone sends email over an unencrypted connection.
+	TLSModeNone = "none"
+
+	// TLSModeStartTLS upgrades the connection using STARTTLS when the server supports it,
+	// sending email over an unencrypted connection otherwise.
+	TLSModeStartTLS = "starttls"
+
+	// TLSModeStartTLSRequired upgrades the connection using STARTTLS, failing to send email
+	// when the server doesn't support it.
+	TLSModeStartTLSRequired = "starttls-required"
+
+	// TLSModeImplicit secures the connection with TLS from the start, as is typical of
+	// port 465.
+	TLSModeImplicit = "implicit"
 )
 
-// Mailer is a type that holds the SMTP auth, ready to send email using it's receiver
-// functions after proper initialization using NewMailer.
+// TLSModes contains the TLS modes supported by a Mailer.
+var TLSModes = []string{TLSModeNone, TLSModeStartTLS, TLSModeStartTLSRequired, TLSModeImplicit}
+
+// Authentication mechanisms that a Mailer may authenticate with.
+const (
+	AuthNone    = "none"
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+)
+
+// AuthMechanisms contains the authentication mechanisms supported by a Mailer.
+var AuthMechanisms = []string{AuthNone, AuthPlain, AuthLogin, AuthCRAMMD5}
+
+// Config represents the SMTP relays that a Mailer sends email through and who it sends
+// email from.
+type Config struct {
+	// Relays are the SMTP relays to send email through, in order of preference: email is
+	// sent through the relay with the lowest priority that's healthy, failing over to the
+	// next on connection, TLS and authentication errors. Relays with equal priorities are
+	// preferred in the order given.
+	Relays []RelayConfig
+
+	// Transport optionally delivers email instead of the relays, which are then ignored,
+	// e.g. to write email to files during development.
+	Transport Transport
+
+	// Sender is who emails are sent from unless a message says otherwise. Its address
+	// defaults to the User of the most preferred relay.
+	Sender Sender
+
+	// DKIM optionally signs every email sent.
+	DKIM *DKIMSigner
+
+	// Cooldown is how long a relay that failed is passed over for, defaulting to
+	// DefaultCooldown, after which email is sent through it again.
+	Cooldown time.Duration
+}
+
+// DefaultCooldown is the default value of the Cooldown field on the Config type.
+const DefaultCooldown = time.Minute
+
+// Mailer is a type that composes email and sends it through its Transport after proper
+// initialization using NewMailer.
 type Mailer struct {
-	addr string
-	auth smtp.Auth
-	from string
+	transport Transport
+	sender    Sender
+	dkim      *DKIMSigner
 }
 
-// NewMailer configures a Mailer to be used given the credentials for carrying out
-// authentication.
-func NewMailer(host string, port int, user, pass string) *Mailer {
+// NewMailer configures a Mailer to be used given the SMTP relays' configuration, or the
+// Transport that replaces them.
+func NewMailer(cfg Config, logger *zap.Logger) (*Mailer, error) {
+	if cfg.Transport == nil {
+		t, err := newSMTPTransport(cfg.Relays, cfg.Cooldown, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Sender.Address == "" {
+			cfg.Sender.Address = t.relays[0].cfg.User
+		}
+		cfg.Transport = t
+	}
+
+	if cfg.Sender.Address == "" {
+		return nil, errors.New("sender address must be defined")
+	}
+
 	return &Mailer{
-		addr: fmt.Sprintf("%s:%d", host, port),
-		from: user,
-		auth: smtp.PlainAuth("", user, pass, host),
+		transport: cfg.Transport,
+		sender:    cfg.Sender,
+		dkim:      cfg.DKIM,
+	}, nil
+}
+
+// Message is an email to be sent by a Mailer. At least one of Text and HTML must be set,
+// and when both are the recipient's mail client chooses which one to display.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+
+	// Sender optionally overrides, field by field, who the Mailer sends the message from.
+	Sender Sender
+
+	// UnsubscribeURL is an optional URL that unsubscribes the recipient from the emails
+	// that they're being sent, advertised through RFC 8058 one-click unsubscribe headers.
+	UnsubscribeURL string
+}
+
+// Send takes a message and uses it to send an email using the underlying receiver type,
+// Mailer.
+func (m *Mailer) Send(ctx context.Context, msg Message) error {
+	err := m.send(ctx, []string{msg.To}, msg)
+
+	var rcptErrs RecipientErrors
+	if errors.As(err, &rcptErrs) {
+		return fmt.Errorf("rcpt to: %w", rcptErrs[msg.To])
 	}
+
+	return err
 }
 
-// DefaultHeaders returns the default headers necessary to send a properly formed
-// email in string form.
-func (m *Mailer) DefaultHeaders(to, subject string) string {
-	headers := make(map[string]string)
-
-	headers["From"] = m.from
-	headers["To"] = to
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = fmt.Sprintf("%s; charset=\"utf-8\"", "text/html")
-	headers["Content-Disposition"] = "inline"
-	headers["Content-Transfer-Encoding"] = "quoted-printable"
-
-	header := ""
-	for key, value := range headers {
-		header += fmt.Sprintf("%s: %s\r\n", key, value)
+// RecipientErrors is the error returned when the SMTP server rejects recipients of a
+// message, keyed by the rejected recipient.
+type RecipientErrors map[string]error
+
+// Error implements the error interface.
+func (e RecipientErrors) Error() string {
+	rcpts := make([]string, 0, len(e))
+	for rcpt := range e {
+		rcpts = append(rcpts, rcpt)
 	}
+	sort.Strings(rcpts)
 
-	return header
+	msgs := make([]string, len(rcpts))
+	for i, rcpt := range rcpts {
+		msgs[i] = fmt.Sprintf("%s: %v", rcpt, e[rcpt])
+	}
+
+	return "recipients rejected: " + strings.Join(msgs, "; ")
 }
 
-// Send takes a recipient address, subject, and message and uses them to send an email
-// using the underlying receiver type, Mailer.
-func (m *Mailer) Send(to, subject, msg string) error {
-	var body bytes.Buffer
-	qpw := quotedprintable.NewWriter(&body)
+// send sends the message to each of the given recipients within a single SMTP
+// transaction, or its equivalent for the Mailer's Transport.
+func (m *Mailer) send(ctx context.Context, to []string, msg Message) (err error) {
+	ctx, span := tracing.Start(ctx, "mail.Send", attribute.Int("smtp.recipients", len(to)))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
-	if _, err := qpw.Write([]byte(msg)); err != nil {
-		return fmt.Errorf("write message: %w", err)
+	sender := msg.Sender.Merge(m.sender)
+
+	email, err := Compose(sender, msg)
+	if err != nil {
+		return fmt.Errorf("compose message: %w", err)
 	}
 
-	if err := qpw.Close(); err != nil {
-		return fmt.Errorf("close writer: %w", err)
+	if m.dkim != nil {
+		if email, err = m.dkim.Sign(email); err != nil {
+			return fmt.Errorf("dkim sign message: %w", err)
+		}
 	}
 
-	// Add headers to body.
-	finalBody := m.DefaultHeaders(to, subject) + "\r\n" + body.String()
+	return m.transport.Send(ctx, sender.envelope(), to, email)
+}
 
-	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(finalBody))
+// Close closes the Mailer's Transport, such as ending its idle sessions with the SMTP
+// relays.
+func (m *Mailer) Close() error {
+	return m.transport.Close()
 }
@@ -0,0 +1,655 @@
+package mail_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+	"go.uber.org/zap"
+)
+
+// Credentials accepted by fakeServer.
+const (
+	fakeUser = "alerts@example.com"
+	fakePass = "secret"
+)
+
+// received is a message received by a fakeServer.
+type received struct {
+	from string
+	to   []string
+	data string
+	tls  bool
+	auth string
+}
+
+// fakeServer is an in-process SMTP server that accepts messages from clients
+// authenticating as fakeUser, rejecting recipients whose address starts with reject.
+type fakeServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+	implicit  bool // implicit secures connections with TLS from the start.
+	startTLS  bool // startTLS advertises, and supports, STARTTLS.
+	silent    bool // silent never greets clients.
+
+	mu       sync.Mutex
+	failAuth bool
+	conns    []net.Conn
+	received []received
+}
+
+// newFakeServer starts a fakeServer, configured by opts, that's closed once the test
+// completes, along with the path to the CA certificate its TLS certificate is signed with.
+func newFakeServer(t *testing.T, opts func(*fakeServer)) (*fakeServer, string) {
+	t.Helper()
+
+	cert, caFile := newCertificate(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := fakeServer{
+		ln:        ln,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	if opts != nil {
+		opts(&s)
+	}
+
+	t.Cleanup(func() {
+		ln.Close()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+
+			go s.serve(conn)
+		}
+	}()
+
+	return &s, caFile
+}
+
+// config returns the mailer configuration that connects to the server.
+func (s *fakeServer) config() lmail.RelayConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+
+	return lmail.RelayConfig{
+		Host:           addr.IP.String(),
+		Port:           addr.Port,
+		User:           fakeUser,
+		Pass:           fakePass,
+		CommandTimeout: 5 * time.Second,
+	}
+}
+
+// messages returns the messages that the server has received.
+func (s *fakeServer) messages() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]received(nil), s.received...)
+}
+
+// setFailAuth sets whether or not the server fails every authentication attempt, as if
+// the credentials had been revoked.
+func (s *fakeServer) setFailAuth(failAuth bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failAuth = failAuth
+}
+
+// connections returns the number of connections that the server has accepted.
+func (s *fakeServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
+
+// drop closes every connection that the server has accepted, as servers do to sessions
+// that have been idle for too long.
+func (s *fakeServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// serve handles the SMTP session of a single connection.
+func (s *fakeServer) serve(conn net.Conn) {
+	if s.silent {
+		return
+	}
+
+	secure := false
+	if s.implicit {
+		conn = tls.Server(conn, s.tlsConfig)
+		secure = true
+	}
+
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	var (
+		msg  received
+		auth string
+	)
+	if err := tp.PrintfLine("220 fake ESMTP"); err != nil {
+		return
+	}
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			verb, arg = line[:i], line[i+1:]
+		}
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-fake")
+			if s.startTLS && !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN LOGIN CRAM-MD5")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			conn = tls.Server(conn, s.tlsConfig)
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			mechanism, ok := s.authenticate(tp, arg)
+
+			s.mu.Lock()
+			ok = ok && !s.failAuth
+			s.mu.Unlock()
+
+			if !ok {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			auth = mechanism
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg = received{from: arg, auth: auth}
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			if strings.HasPrefix(arg, "TO:<reject") {
+				tp.PrintfLine("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, arg)
+			tp.PrintfLine("250 ok")
+		case "RSET":
+			msg = received{}
+			tp.PrintfLine("250 ok")
+		case "NOOP":
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data, msg.tls = string(data), secure
+
+			s.mu.Lock()
+			s.received = append(s.received, msg)
+			s.mu.Unlock()
+			msg = received{}
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unrecognized command")
+		}
+	}
+}
+
+// authenticate carries out the AUTH exchange of the mechanism within arg, reporting the
+// mechanism and whether or not the client authenticated as fakeUser.
+func (s *fakeServer) authenticate(tp *textproto.Conn, arg string) (string, bool) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	challenge := func(prompt string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(prompt)))
+		line, _ := tp.ReadLine()
+		resp, _ := base64.StdEncoding.DecodeString(line)
+		return string(resp)
+	}
+
+	switch mechanism := strings.ToUpper(fields[0]); mechanism {
+	case "PLAIN":
+		var resp string
+		if len(fields) > 1 {
+			b, _ := base64.StdEncoding.DecodeString(fields[1])
+			resp = string(b)
+		} else {
+			resp = challenge("")
+		}
+		return mechanism, resp == "\x00"+fakeUser+"\x00"+fakePass
+	case "LOGIN":
+		user := challenge("Username:")
+		pass := challenge("Password:")
+		return mechanism, user == fakeUser && pass == fakePass
+	case "CRAM-MD5":
+		nonce := "<1234@fake>"
+		h := hmac.New(md5.New, []byte(fakePass))
+		h.Write([]byte(nonce))
+		return mechanism, challenge(nonce) == fakeUser+" "+hex.EncodeToString(h.Sum(nil))
+	}
+
+	return "", false
+}
+
+// newCertificate returns a self-signed TLS certificate for 127.0.0.1, along with the path
+// to a PEM encoded copy of it.
+func newCertificate(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("write ca file: %v", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// TestMailerSend tests that a Mailer sends email over each supported TLS mode and auth
+// mechanism.
+func TestMailerSend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		server   func(*fakeServer)
+		config   func(cfg *lmail.RelayConfig, caFile string)
+		sender   lmail.Sender
+		wantTLS  bool
+		wantAuth string
+		wantFrom string
+	}{
+		{
+			name:   "starttls required with plain auth",
+			server: func(s *fakeServer) { s.startTLS = true },
+			config: func(cfg *lmail.RelayConfig, caFile string) {
+				cfg.TLSMode, cfg.Auth, cfg.CAFile = lmail.TLSModeStartTLSRequired, lmail.AuthPlain, caFile
+			},
+			wantTLS:  true,
+			wantAuth: "PLAIN",
+			wantFrom: "FROM:<alerts@example.com>",
+		},
+		{
+			name:   "implicit tls with login auth",
+			server: func(s *fakeServer) { s.implicit = true },
+			config: func(cfg *lmail.RelayConfig, _ string) {
+				cfg.TLSMode, cfg.Auth, cfg.InsecureSkipVerify = lmail.TLSModeImplicit, lmail.AuthLogin, true
+			},
+			wantTLS:  true,
+			wantAuth: "LOGIN",
+			wantFrom: "FROM:<alerts@example.com>",
+		},
+		{
+			name: "no tls with cram-md5 auth",
+			config: func(cfg *lmail.RelayConfig, _ string) {
+				cfg.TLSMode, cfg.Auth, cfg.HELOName = lmail.TLSModeNone, lmail.AuthCRAMMD5, "loraficationd.example.com"
+			},
+			wantAuth: "CRAM-MD5",
+			wantFrom: "FROM:<alerts@example.com>",
+		},
+		{
+			name: "opportunistic starttls without auth",
+			config: func(cfg *lmail.RelayConfig, _ string) {
+				cfg.TLSMode, cfg.Auth, cfg.User, cfg.Pass = lmail.TLSModeStartTLS, lmail.AuthNone, "", ""
+			},
+			sender:   lmail.Sender{Address: "alerts@ourcity.gov", Envelope: "bounces@ourcity.gov"},
+			wantFrom: "FROM:<bounces@ourcity.gov>",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, caFile := newFakeServer(t, test.server)
+			cfg := s.config()
+			test.config(&cfg, caFile)
+
+			mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}, Sender: test.sender}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("new mailer: %v", err)
+			}
+
+			if err := mailer.Send(context.Background(), lmail.Message{
+				To:      "user@example.com",
+				Subject: "Hello",
+				Text:    "Water level high",
+			}); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+
+			messages := s.messages()
+			if e, a := 1, len(messages); e != a {
+				t.Fatalf("expected %d message to be received, got %d", e, a)
+			}
+
+			if e, a := "TO:<user@example.com>", strings.Join(messages[0].to, ","); e != a {
+				t.Errorf("expected recipient to be %q, got %q", e, a)
+			}
+
+			if e, a := test.wantFrom, messages[0].from; e != a {
+				t.Errorf("expected envelope sender to be %q, got %q", e, a)
+			}
+
+			if e, a := test.wantTLS, messages[0].tls; e != a {
+				t.Errorf("expected message received over tls to be %t, got %t", e, a)
+			}
+
+			if e, a := test.wantAuth, messages[0].auth; e != a {
+				t.Errorf("expected auth mechanism to be %q, got %q", e, a)
+			}
+
+			if e, a := "Subject: Hello\n", messages[0].data; !strings.Contains(a, e) {
+				t.Errorf("expected message to contain %q, got %q", e, a)
+			}
+		})
+	}
+}
+
+// TestMailerStartTLSRequired tests that a Mailer requiring STARTTLS refuses to send email
+// to a server that doesn't support it.
+func TestMailerStartTLSRequired(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newFakeServer(t, nil)
+	cfg := s.config()
+	cfg.TLSMode = lmail.TLSModeStartTLSRequired
+
+	mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	err = mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"})
+	if !errors.Is(err, lmail.ErrStartTLSUnsupported) {
+		t.Errorf("expected error to be %v, got %v", lmail.ErrStartTLSUnsupported, err)
+	}
+
+	if e, a := 0, len(s.messages()); e != a {
+		t.Errorf("expected %d messages to be received, got %d", e, a)
+	}
+}
+
+// TestMailerCommandTimeout tests that a Mailer gives up on a server that stops responding.
+func TestMailerCommandTimeout(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newFakeServer(t, func(s *fakeServer) { s.silent = true })
+	cfg := s.config()
+	cfg.CommandTimeout = 100 * time.Millisecond
+
+	mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+
+	st := time.Now()
+	err = mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"})
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+
+	if d := time.Since(st); d > 5*time.Second {
+		t.Errorf("expected send to time out promptly, took %s", d)
+	}
+}
+
+// TestMailerReusesSessions tests that a Mailer sends successive emails over the same
+// session, replacing it once the server drops it.
+func TestMailerReusesSessions(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newFakeServer(t, nil)
+	cfg := s.config()
+	cfg.TLSMode = lmail.TLSModeNone
+
+	mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	defer mailer.Close()
+
+	send := func() {
+		t.Helper()
+
+		if err := mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		send()
+	}
+
+	if e, a := 1, s.connections(); e != a {
+		t.Errorf("expected %d connection for successive emails, got %d", e, a)
+	}
+
+	s.drop()
+	send()
+
+	if e, a := 2, s.connections(); e != a {
+		t.Errorf("expected %d connections once the first was dropped, got %d", e, a)
+	}
+
+	if e, a := 4, len(s.messages()); e != a {
+		t.Errorf("expected %d messages to be received, got %d", e, a)
+	}
+}
+
+// TestMailerMaxSessions tests that a Mailer sending emails concurrently doesn't open more
+// sessions than it's configured to.
+func TestMailerMaxSessions(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newFakeServer(t, nil)
+	cfg := s.config()
+	cfg.TLSMode, cfg.MaxSessions = lmail.TLSModeNone, 2
+
+	mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	defer mailer.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("send: %v", err)
+		}
+	}
+
+	if a := s.connections(); a > cfg.MaxSessions {
+		t.Errorf("expected at most %d connections, got %d", cfg.MaxSessions, a)
+	}
+
+	if e, a := cap(errs), len(s.messages()); e != a {
+		t.Errorf("expected %d messages to be received, got %d", e, a)
+	}
+}
+
+// TestMailerSendRejected tests that a Mailer reports the recipient that the server
+// rejected, and that the session is still used afterwards.
+func TestMailerSendRejected(t *testing.T) {
+	t.Parallel()
+
+	s, _ := newFakeServer(t, nil)
+	cfg := s.config()
+	cfg.TLSMode = lmail.TLSModeNone
+
+	mailer, err := lmail.NewMailer(lmail.Config{Relays: []lmail.RelayConfig{cfg}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	defer mailer.Close()
+
+	err = mailer.Send(context.Background(), lmail.Message{To: "rejected@example.com", Subject: "Hello", Text: "Water level high"})
+
+	var tpErr *textproto.Error
+	if !errors.As(err, &tpErr) || tpErr.Code != 550 {
+		t.Fatalf("expected the recipient to be rejected with 550, got %v", err)
+	}
+
+	if e, a := 0, len(s.messages()); e != a {
+		t.Errorf("expected %d messages to be received, got %d", e, a)
+	}
+
+	if err := mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"}); err != nil {
+		t.Errorf("send after rejection: %v", err)
+	}
+
+	if e, a := 1, s.connections(); e != a {
+		t.Errorf("expected %d connection, got %d", e, a)
+	}
+}
+
+// TestMailerFailover tests that a Mailer fails over to the next relay when the preferred
+// one fails, and fails back to it once it has cooled down.
+func TestMailerFailover(t *testing.T) {
+	t.Parallel()
+
+	primary, _ := newFakeServer(t, func(s *fakeServer) { s.failAuth = true })
+	backup, _ := newFakeServer(t, nil)
+
+	primaryCfg, backupCfg := primary.config(), backup.config()
+	primaryCfg.TLSMode, backupCfg.TLSMode = lmail.TLSModeNone, lmail.TLSModeNone
+	primaryCfg.Priority, backupCfg.Priority = 0, 10
+
+	cooldown := 200 * time.Millisecond
+	mailer, err := lmail.NewMailer(lmail.Config{
+		Relays:   []lmail.RelayConfig{backupCfg, primaryCfg},
+		Cooldown: cooldown,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	defer mailer.Close()
+
+	send := func() {
+		t.Helper()
+
+		if err := mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Text: "Water level high"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	// The primary fails to authenticate, so both emails are carried by the backup without
+	// the primary being attempted again while it cools down.
+	send()
+	send()
+
+	if e, a := 1, primary.connections(); e != a {
+		t.Errorf("expected %d connection to the primary, got %d", e, a)
+	}
+
+	if e, a := 2, len(backup.messages()); e != a {
+		t.Errorf("expected %d messages to be received by the backup, got %d", e, a)
+	}
+
+	// Once cooled down, and recovered, the primary carries email again.
+	primary.setFailAuth(false)
+	time.Sleep(cooldown)
+	send()
+
+	if e, a := 1, len(primary.messages()); e != a {
+		t.Errorf("expected %d message to be received by the primary, got %d", e, a)
+	}
+
+	if e, a := 2, len(backup.messages()); e != a {
+		t.Errorf("expected %d messages to be received by the backup, got %d", e, a)
+	}
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultMemoryCapacity is the number of emails a MemoryTransport keeps when it's created
+// with a capacity of zero.
+const DefaultMemoryCapacity = 1000
+
+// Captured is an email captured by a MemoryTransport, along with its envelope.
+type Captured struct {
+	From  string
+	To    []string
+	Email []byte
+}
+
+// MemoryTransport is the Transport that captures email in memory rather than delivering
+// it, for tests to inspect what would have been sent. Only the most recent emails are
+// kept, so that a long running daemon doesn't grow without bound.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	capacity int
+	captured []Captured
+}
+
+// NewMemoryTransport returns a reference to a MemoryTransport that keeps up to capacity
+// emails, defaulting to DefaultMemoryCapacity.
+func NewMemoryTransport(capacity int) *MemoryTransport {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+
+	return &MemoryTransport{capacity: capacity}
+}
+
+// Send implements the Transport interface.
+func (t *MemoryTransport) Send(ctx context.Context, from string, to []string, email []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.captured) == t.capacity {
+		t.captured = t.captured[1:]
+	}
+
+	t.captured = append(t.captured, Captured{
+		From:  from,
+		To:    append([]string(nil), to...),
+		Email: append([]byte(nil), email...),
+	})
+
+	return nil
+}
+
+// Captured returns the emails captured so far, oldest first.
+func (t *MemoryTransport) Captured() []Captured {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Captured(nil), t.captured...)
+}
+
+// Reset discards the emails captured so far.
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.captured = nil
+}
+
+// Close implements the Transport interface.
+func (t *MemoryTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSTLSUnsupported is returned when the POP3 server doesn't support STLS, but the
+// Mailbox is configured to require it.
+var ErrSTLSUnsupported = errors.New("pop3 server doesn't support STLS")
+
+// MailboxConfig represents all of the information needed to connect, and authenticate, to
+// a POP3 mailbox.
+type MailboxConfig struct {
+	Host string
+	Port int
+	User string
+	Pass string
+
+	// TLSMode is one of TLSModes, defaulting to TLSModeImplicit as is typical of port 995.
+	// The STARTTLS modes upgrade the connection using the STLS command.
+	TLSMode string
+
+	// CAFile is an optional path to PEM encoded certificates that the server's certificate
+	// is verified against instead of the system's.
+	CAFile string
+
+	// InsecureSkipVerify skips verifying the server's certificate entirely.
+	InsecureSkipVerify bool
+
+	// Timeout bounds connecting to the server and each command sent to it once connected.
+	// Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Mailbox retrieves messages from a POP3 mailbox, per RFC 1939.
+type Mailbox struct {
+	cfg       MailboxConfig
+	addr      string
+	tlsConfig *tls.Config
+}
+
+// NewMailbox returns a reference to a Mailbox given its configuration.
+func NewMailbox(cfg MailboxConfig) (*Mailbox, error) {
+	if cfg.TLSMode == "" {
+		cfg.TLSMode = TLSModeImplicit
+	}
+
+	switch cfg.TLSMode {
+	case TLSModeNone, TLSModeStartTLS, TLSModeStartTLSRequired, TLSModeImplicit:
+	default:
+		return nil, fmt.Errorf("unsupported tls mode %q", cfg.TLSMode)
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.Host, cfg.CAFile, cfg.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mailbox{
+		cfg:       cfg,
+		addr:      net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		tlsConfig: tlsConfig,
+	}, nil
+}
+
+// Fetch retrieves each message in the mailbox, passing it to handle, and deletes the
+// messages that handle returns no error for. Fetching stops at the first error handle
+// returns, keeping that message and those after it in the mailbox for the next Fetch.
+func (mb *Mailbox) Fetch(ctx context.Context, handle func(msg []byte) error) error {
+	c, err := mb.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.conn.Close()
+
+	reply, err := c.cmd(ctx, "STAT")
+	if err != nil {
+		return fmt.Errorf("stat: %w", err)
+	}
+
+	var count int
+	if _, err := fmt.Sscanf(reply, "%d", &count); err != nil {
+		return fmt.Errorf("parse stat reply %q: %w", reply, err)
+	}
+
+	var handleErr error
+	for i := 1; i <= count; i++ {
+		if _, err := c.cmd(ctx, "RETR %d", i); err != nil {
+			return fmt.Errorf("retr: %w", err)
+		}
+
+		msg, err := c.ReadDotBytes()
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+
+		if err := handle(msg); err != nil {
+			handleErr = fmt.Errorf("handle message %d: %w", i, err)
+			break
+		}
+
+		if _, err := c.cmd(ctx, "DELE %d", i); err != nil {
+			return fmt.Errorf("dele: %w", err)
+		}
+	}
+
+	// Deleted messages are only removed once the session is ended with QUIT.
+	if _, err := c.cmd(ctx, "QUIT"); err != nil {
+		return fmt.Errorf("quit: %w", err)
+	}
+
+	return handleErr
+}
+
+// pop3Error is a -ERR reply of a POP3 server.
+type pop3Error string
+
+// Error implements the error interface.
+func (e pop3Error) Error() string {
+	return "pop3: " + string(e)
+}
+
+// pop3Conn is a POP3 session whose connection's deadline is extended before each command.
+type pop3Conn struct {
+	*textproto.Conn
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// cmd sends a command to the server and returns the text of its +OK reply.
+func (c *pop3Conn) cmd(ctx context.Context, format string, args ...interface{}) (string, error) {
+	_ = c.conn.SetDeadline(deadline(ctx, c.timeout))
+
+	if err := c.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+
+	return c.reply()
+}
+
+// reply reads a reply from the server, returning its text when it's +OK and a pop3Error
+// when it's -ERR.
+func (c *pop3Conn) reply() (string, error) {
+	line, err := c.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(line, "+OK"):
+		return strings.TrimSpace(line[3:]), nil
+	case strings.HasPrefix(line, "-ERR"):
+		return "", pop3Error(strings.TrimSpace(line[4:]))
+	}
+
+	return "", fmt.Errorf("malformed reply %q", line)
+}
+
+// dial connects to the mailbox, securing the connection and authenticating as the Mailbox
+// is configured to.
+func (mb *Mailbox) dial(ctx context.Context) (*pop3Conn, error) {
+	dialer := net.Dialer{Timeout: mb.cfg.Timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", mb.addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	c, err := mb.handshake(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// handshake reads the server's greeting, upgrades the connection to TLS and authenticates
+// as the Mailbox is configured to.
+func (mb *Mailbox) handshake(ctx context.Context, conn net.Conn) (*pop3Conn, error) {
+	_ = conn.SetDeadline(deadline(ctx, mb.cfg.Timeout))
+
+	if mb.cfg.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, mb.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c := pop3Conn{Conn: textproto.NewConn(conn), conn: conn, timeout: mb.cfg.Timeout}
+	if _, err := c.reply(); err != nil {
+		return nil, fmt.Errorf("greeting: %w", err)
+	}
+
+	if mb.cfg.TLSMode == TLSModeStartTLS || mb.cfg.TLSMode == TLSModeStartTLSRequired {
+		_, err := c.cmd(ctx, "STLS")
+
+		var popErr pop3Error
+		switch {
+		case err == nil:
+			tlsConn := tls.Client(conn, mb.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, fmt.Errorf("tls handshake: %w", err)
+			}
+			c = pop3Conn{Conn: textproto.NewConn(tlsConn), conn: tlsConn, timeout: mb.cfg.Timeout}
+		case errors.As(err, &popErr) && mb.cfg.TLSMode == TLSModeStartTLSRequired:
+			return nil, ErrSTLSUnsupported
+		case !errors.As(err, &popErr):
+			return nil, fmt.Errorf("stls: %w", err)
+		}
+	}
+
+	if _, err := c.cmd(ctx, "USER %s", mb.cfg.User); err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+
+	if _, err := c.cmd(ctx, "PASS %s", mb.cfg.Pass); err != nil {
+		return nil, fmt.Errorf("pass: %w", err)
+	}
+
+	return &c, nil
+}
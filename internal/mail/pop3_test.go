@@ -0,0 +1,237 @@
+package mail_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+)
+
+// fakeMailbox is an in-process POP3 server, securing connections with TLS from the start,
+// that serves its messages to clients authenticating as fakeUser.
+type fakeMailbox struct {
+	ln        net.Listener
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	messages []string
+}
+
+// newFakeMailbox starts a fakeMailbox holding the given messages, returning it along with
+// the path to the CA file that its certificate verifies against.
+func newFakeMailbox(t *testing.T, messages ...string) (*fakeMailbox, string) {
+	t.Helper()
+
+	cert, caFile := newCertificate(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	mb := fakeMailbox{
+		ln:        ln,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		messages:  messages,
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go mb.serve(tls.Server(conn, mb.tlsConfig))
+		}
+	}()
+
+	return &mb, caFile
+}
+
+// config returns the mailbox configuration that connects to the server.
+func (mb *fakeMailbox) config(caFile string) lmail.MailboxConfig {
+	addr := mb.ln.Addr().(*net.TCPAddr)
+
+	return lmail.MailboxConfig{
+		Host:    addr.IP.String(),
+		Port:    addr.Port,
+		User:    fakeUser,
+		Pass:    fakePass,
+		CAFile:  caFile,
+		Timeout: 5 * time.Second,
+	}
+}
+
+// remaining returns the messages that haven't been deleted from the mailbox.
+func (mb *fakeMailbox) remaining() []string {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	return append([]string(nil), mb.messages...)
+}
+
+// serve handles the POP3 session of a single connection, only deleting messages once the
+// session is ended with QUIT.
+func (mb *fakeMailbox) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	defer tp.Close()
+
+	mb.mu.Lock()
+	messages := append([]string(nil), mb.messages...)
+	mb.mu.Unlock()
+
+	var user string
+	authenticated := false
+	deleted := make(map[int]bool)
+
+	tp.PrintfLine("+OK fake POP3")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			verb, arg = line[:i], line[i+1:]
+		}
+
+		if !authenticated && verb != "USER" && verb != "PASS" && verb != "QUIT" {
+			tp.PrintfLine("-ERR not authenticated")
+			continue
+		}
+
+		var n int
+		fmt.Sscanf(arg, "%d", &n)
+
+		switch verb {
+		case "USER":
+			user = arg
+			tp.PrintfLine("+OK")
+		case "PASS":
+			if user != fakeUser || arg != fakePass {
+				tp.PrintfLine("-ERR invalid credentials")
+				continue
+			}
+			authenticated = true
+			tp.PrintfLine("+OK")
+		case "STAT":
+			tp.PrintfLine("+OK %d 0", len(messages))
+		case "RETR":
+			if n < 1 || n > len(messages) {
+				tp.PrintfLine("-ERR no such message")
+				continue
+			}
+			tp.PrintfLine("+OK")
+			w := tp.DotWriter()
+			w.Write([]byte(messages[n-1]))
+			w.Close()
+		case "DELE":
+			deleted[n] = true
+			tp.PrintfLine("+OK")
+		case "QUIT":
+			var kept []string
+			for i, msg := range messages {
+				if !deleted[i+1] {
+					kept = append(kept, msg)
+				}
+			}
+
+			mb.mu.Lock()
+			mb.messages = kept
+			mb.mu.Unlock()
+
+			tp.PrintfLine("+OK bye")
+			return
+		default:
+			tp.PrintfLine("-ERR unrecognized command")
+		}
+	}
+}
+
+// TestMailboxFetch tests that a Mailbox passes each message to the handler and deletes the
+// handled ones, keeping those from the first the handler fails on.
+func TestMailboxFetch(t *testing.T) {
+	t.Parallel()
+
+	mb, caFile := newFakeMailbox(t, "Subject: one\n\n1\n", "Subject: two\n\n2\n", "Subject: three\n\n3\n")
+
+	mailbox, err := lmail.NewMailbox(mb.config(caFile))
+	if err != nil {
+		t.Fatalf("new mailbox: %v", err)
+	}
+
+	errHandle := errors.New("database unavailable")
+
+	var handled []string
+	err = mailbox.Fetch(context.Background(), func(msg []byte) error {
+		if strings.Contains(string(msg), "two") {
+			return errHandle
+		}
+		handled = append(handled, string(msg))
+		return nil
+	})
+	if !errors.Is(err, errHandle) {
+		t.Fatalf("expected fetch to fail with the handler's error, got %v", err)
+	}
+
+	if e, a := []string{"Subject: one\n\n1\n"}, handled; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected handled messages %q, got %q", e, a)
+	}
+
+	if e, a := []string{"Subject: two\n\n2\n", "Subject: three\n\n3\n"}, mb.remaining(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected remaining messages %q, got %q", e, a)
+	}
+
+	// The next fetch picks up where the last one failed.
+	handled = nil
+	if err := mailbox.Fetch(context.Background(), func(msg []byte) error {
+		handled = append(handled, string(msg))
+		return nil
+	}); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	if e, a := 2, len(handled); e != a {
+		t.Errorf("expected %d handled messages, got %d", e, a)
+	}
+
+	if e, a := 0, len(mb.remaining()); e != a {
+		t.Errorf("expected %d remaining messages, got %d", e, a)
+	}
+}
+
+// TestMailboxFetchAuthFailure tests that a Mailbox fails to fetch with the wrong
+// credentials.
+func TestMailboxFetchAuthFailure(t *testing.T) {
+	t.Parallel()
+
+	mb, caFile := newFakeMailbox(t, "Subject: one\n\n1\n")
+
+	cfg := mb.config(caFile)
+	cfg.Pass = "wrong"
+
+	mailbox, err := lmail.NewMailbox(cfg)
+	if err != nil {
+		t.Fatalf("new mailbox: %v", err)
+	}
+
+	if err := mailbox.Fetch(context.Background(), func([]byte) error { return nil }); err == nil {
+		t.Error("expected fetch to fail")
+	}
+
+	if e, a := 1, len(mb.remaining()); e != a {
+		t.Errorf("expected %d remaining messages, got %d", e, a)
+	}
+}
@@ -0,0 +1,212 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RelayConfig represents all of the information needed to connect, and authenticate, to
+// an SMTP relay.
+type RelayConfig struct {
+	Host string
+	Port int
+	User string
+	Pass string
+
+	// Priority orders the relay among others, lower priorities being preferred.
+	Priority int
+
+	// TLSMode is one of TLSModes, defaulting to TLSModeStartTLSRequired.
+	TLSMode string
+
+	// CAFile is an optional path to PEM encoded certificates that the server's certificate
+	// is verified against instead of the system's, e.g. for internal relays.
+	CAFile string
+
+	// InsecureSkipVerify skips verifying the server's certificate entirely.
+	InsecureSkipVerify bool
+
+	// Auth is one of AuthMechanisms, defaulting to AuthPlain.
+	Auth string
+
+	// HELOName is the name the Mailer identifies itself with, defaulting to localhost.
+	HELOName string
+
+	// DialTimeout bounds connecting to the server, and CommandTimeout bounds each command
+	// sent to it once connected. Zero means no timeout.
+	DialTimeout    time.Duration
+	CommandTimeout time.Duration
+
+	// MaxSessions caps the number of concurrent sessions with the server, defaulting to
+	// DefaultMaxSessions. Sessions are kept open between emails, until they've been idle
+	// for IdleTimeout, so that each email doesn't need its own connection and handshake.
+	MaxSessions int
+	IdleTimeout time.Duration
+}
+
+// Defaults for the RelayConfig fields that fall back to them when left unset.
+const (
+	DefaultMaxSessions = 4
+	DefaultIdleTimeout = 30 * time.Second
+)
+
+// addr returns the host:port address of the relay.
+func (cfg RelayConfig) addr() string {
+	return net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+}
+
+// relay is an SMTP relay along with its pool of sessions and its health.
+type relay struct {
+	cfg       RelayConfig
+	addr      string
+	auth      smtp.Auth
+	tlsConfig *tls.Config
+
+	// slots holds a value for each session in use, capping them at MaxSessions, and idle
+	// holds the sessions not in use.
+	slots chan struct{}
+	idle  chan *client
+
+	mu        sync.Mutex
+	closed    bool
+	downUntil time.Time
+}
+
+// newRelay configures a relay given its configuration.
+func newRelay(cfg RelayConfig) (*relay, error) {
+	if cfg.TLSMode == "" {
+		cfg.TLSMode = TLSModeStartTLSRequired
+	}
+
+	if cfg.Auth == "" {
+		cfg.Auth = AuthPlain
+	}
+
+	if cfg.MaxSessions <= 0 {
+		cfg.MaxSessions = DefaultMaxSessions
+	}
+
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.Host, cfg.CAFile, cfg.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	switch cfg.Auth {
+	case AuthNone:
+	case AuthPlain:
+		auth = smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
+	case AuthLogin:
+		auth = &loginAuth{host: cfg.Host, user: cfg.User, pass: cfg.Pass}
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(cfg.User, cfg.Pass)
+	default:
+		return nil, fmt.Errorf("unsupported auth mechanism %q", cfg.Auth)
+	}
+
+	switch cfg.TLSMode {
+	case TLSModeNone, TLSModeStartTLS, TLSModeStartTLSRequired, TLSModeImplicit:
+	default:
+		return nil, fmt.Errorf("unsupported tls mode %q", cfg.TLSMode)
+	}
+
+	return &relay{
+		cfg:       cfg,
+		addr:      cfg.addr(),
+		auth:      auth,
+		tlsConfig: tlsConfig,
+		slots:     make(chan struct{}, cfg.MaxSessions),
+		idle:      make(chan *client, cfg.MaxSessions),
+	}, nil
+}
+
+// newTLSConfig returns the configuration of TLS connections to the given host, verifying
+// its certificate against those in caFile when it's set.
+func newTLSConfig(host, caFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ca file contains no pem encoded certificates")
+		}
+	}
+
+	return &tlsConfig, nil
+}
+
+// send sends email from the given envelope sender to each of the given recipients within
+// a single transaction over one of the relay's sessions.
+func (r *relay) send(ctx context.Context, from string, to []string, email []byte) error {
+	c, err := r.acquire(ctx)
+	if err != nil {
+		return &sessionError{err: err}
+	}
+
+	err = c.transact(ctx, from, to, email)
+	r.release(ctx, c, err)
+
+	return err
+}
+
+// healthy reports whether or not the relay is healthy at the given time, being unhealthy
+// while cooling down after failing.
+func (r *relay) healthy(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return !now.Before(r.downUntil)
+}
+
+// fail marks the relay as unhealthy until the given time.
+func (r *relay) fail(until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.downUntil = until
+}
+
+// sessionError is the error returned when a session with a relay can't be established,
+// whether it's due to the connection, TLS or the relay rejecting authentication.
+type sessionError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *sessionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+// shouldFailover reports whether or not email that failed to be sent through a relay with
+// the given error should be sent through another relay instead. It should be unless the
+// relay rejected the email itself, which other relays would too.
+func shouldFailover(err error) bool {
+	var sessErr *sessionError
+	return errors.As(err, &sessErr) || !isRejection(err)
+}
@@ -0,0 +1,238 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// ErrNotReport is returned by ParseReport when the message is neither a delivery status
+// notification nor a feedback report, e.g. an auto-reply.
+var ErrNotReport = errors.New("message is not a delivery status notification or feedback report")
+
+// Kinds of bounces that reports describe.
+const (
+	// BounceHard is a permanent failure to deliver to the recipient, e.g. an unknown user.
+	BounceHard = "hard"
+
+	// BounceSoft is a transient failure that the reporting server gave up retrying, e.g. a
+	// full mailbox.
+	BounceSoft = "soft"
+
+	// BounceComplaint is the recipient reporting an email as spam or abuse.
+	BounceComplaint = "complaint"
+)
+
+// Bounce is a recipient that an email bounced from, or that complained about it.
+type Bounce struct {
+	Recipient string
+	Kind      string
+
+	// Status is the enhanced status code of the failure, per RFC 3463, and is empty for
+	// complaints.
+	Status string
+
+	// Reason is a human readable explanation of the bounce, e.g. the diagnostic reply of
+	// the recipient's server.
+	Reason string
+}
+
+// ParseReport parses the bounces reported by the given message, which is either a
+// delivery status notification per RFC 3464 or a feedback report per RFC 5965. Recipients
+// that were only delayed, or that were delivered to, aren't bounces and are left out.
+func ParseReport(r io.Reader) ([]Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" {
+		return nil, ErrNotReport
+	}
+
+	reportType := strings.ToLower(params["report-type"])
+	if reportType != "delivery-status" && reportType != "feedback-report" {
+		return nil, ErrNotReport
+	}
+
+	var (
+		feedback textproto.MIMEHeader
+		original *mail.Message
+	)
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		body := partBody(part)
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if reportType == "delivery-status" {
+				return parseDeliveryStatus(body)
+			}
+		case "message/feedback-report":
+			if feedback, err = readFields(textproto.NewReader(bufio.NewReader(body))); err != nil {
+				return nil, fmt.Errorf("read feedback report: %w", err)
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			// The original message is only needed for its recipient, so an unreadable one
+			// is ignored in favour of the feedback report's.
+			original, _ = mail.ReadMessage(body)
+		}
+	}
+
+	if feedback == nil {
+		return nil, ErrNotReport
+	}
+
+	return parseFeedback(feedback, original)
+}
+
+// partBody returns the decoded body of the given part. Quoted-printable parts are decoded
+// by the multipart package itself.
+func partBody(part *multipart.Part) io.Reader {
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+
+	return part
+}
+
+// parseDeliveryStatus parses the bounces within the body of a message/delivery-status part,
+// which holds a group of per-message fields followed by a group of fields per recipient.
+func parseDeliveryStatus(body io.Reader) ([]Bounce, error) {
+	tp := textproto.NewReader(bufio.NewReader(body))
+
+	if _, err := readFields(tp); err != nil {
+		return nil, fmt.Errorf("read per-message fields: %w", err)
+	}
+
+	var bounces []Bounce
+	for {
+		fields, err := readFields(tp)
+		if err != nil {
+			return nil, fmt.Errorf("read per-recipient fields: %w", err)
+		}
+		if fields == nil {
+			break
+		}
+
+		if !strings.EqualFold(fields.Get("Action"), "failed") {
+			continue
+		}
+
+		recipient := typedValue(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = typedValue(fields.Get("Original-Recipient"))
+		}
+
+		if recipient == "" {
+			continue
+		}
+
+		status := strings.TrimSpace(fields.Get("Status"))
+
+		kind := BounceSoft
+		if strings.HasPrefix(status, "5") {
+			kind = BounceHard
+		}
+
+		reason := typedValue(fields.Get("Diagnostic-Code"))
+		if reason == "" {
+			reason = status
+		}
+
+		bounces = append(bounces, Bounce{
+			Recipient: recipient,
+			Kind:      kind,
+			Status:    status,
+			Reason:    reason,
+		})
+	}
+
+	return bounces, nil
+}
+
+// parseFeedback parses the complaint described by the fields of a message/feedback-report
+// part, falling back to the recipient of the original message when the report doesn't
+// name them.
+func parseFeedback(feedback textproto.MIMEHeader, original *mail.Message) ([]Bounce, error) {
+	feedbackType := strings.ToLower(strings.TrimSpace(feedback.Get("Feedback-Type")))
+	if feedbackType == "not-spam" {
+		return nil, nil
+	}
+
+	recipient := strings.Trim(strings.TrimSpace(feedback.Get("Original-Rcpt-To")), "<>")
+	if recipient == "" && original != nil {
+		if to, err := original.Header.AddressList("To"); err == nil && len(to) == 1 {
+			recipient = to[0].Address
+		}
+	}
+
+	if recipient == "" {
+		return nil, errors.New("feedback report doesn't identify its recipient")
+	}
+
+	return []Bounce{{
+		Recipient: recipient,
+		Kind:      BounceComplaint,
+		Reason:    "complaint: " + feedbackType,
+	}}, nil
+}
+
+// readFields reads the next group of header fields, which are separated by blank lines,
+// returning nil once there are none left.
+func readFields(tp *textproto.Reader) (textproto.MIMEHeader, error) {
+	// Groups may be separated by more than a single blank line.
+	for {
+		b, err := tp.R.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if b[0] != '\r' && b[0] != '\n' {
+			break
+		}
+
+		if _, err := tp.ReadLine(); err != nil {
+			return nil, err
+		}
+	}
+
+	fields, err := tp.ReadMIMEHeader()
+	if err == io.EOF {
+		// The last group needn't be followed by a blank line.
+		err = nil
+	}
+
+	return fields, err
+}
+
+// typedValue returns the value of a field of the form "type; value", such as the
+// "rfc822; user@example.com" of a Final-Recipient field.
+func typedValue(field string) string {
+	if i := strings.IndexByte(field, ';'); i != -1 {
+		field = field[i+1:]
+	}
+
+	return strings.Trim(strings.TrimSpace(field), "<>")
+}
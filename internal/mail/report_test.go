@@ -0,0 +1,150 @@
+package mail_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+)
+
+// dsn is a delivery status notification reporting a hard bounce, a soft bounce and a
+// delayed recipient.
+const dsn = `From: Mail Delivery System <MAILER-DAEMON@mx.example.com>
+To: bounces@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="b1"
+
+--b1
+Content-Type: text/plain
+
+Your message could not be delivered to some of its recipients.
+
+--b1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.com
+Arrival-Date: Mon, 5 Oct 2026 10:00:00 +0000
+
+Final-Recipient: rfc822; <unknown@example.org>
+Original-Recipient: rfc822;unknown@example.org
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <unknown@example.org>: Recipient address
+ rejected: User unknown
+
+Final-Recipient: rfc822; full@example.org
+Action: failed
+Status: 4.2.2
+
+
+Final-Recipient: rfc822; slow@example.org
+Action: delayed
+Status: 4.4.1
+
+--b1
+Content-Type: text/rfc822-headers
+
+From: alerts@example.com
+To: undisclosed-recipients:;
+Subject: LoRafication: Notification from Node
+
+--b1--
+`
+
+// arf is a feedback report of a complaint about an email, which only names the recipient
+// within the original message.
+const arf = `From: feedback@isp.example.net
+To: abuse@example.com
+Subject: Abuse report
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="b2"
+
+--b2
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--b2
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+
+--b2
+Content-Type: message/rfc822
+
+From: LoRafication <alerts@example.com>
+To: complainer@isp.example.net
+Subject: LoRafication: Notification from Node
+
+Water level high
+--b2--
+`
+
+// TestParseReport tests that bounces and complaints are parsed from delivery status
+// notifications and feedback reports, and that other messages aren't mistaken for them.
+func TestParseReport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		message  string
+		expected []lmail.Bounce
+		err      error
+	}{
+		{
+			name:    "dsn",
+			message: dsn,
+			expected: []lmail.Bounce{
+				{
+					Recipient: "unknown@example.org",
+					Kind:      lmail.BounceHard,
+					Status:    "5.1.1",
+					Reason:    "550 5.1.1 <unknown@example.org>: Recipient address rejected: User unknown",
+				},
+				{
+					Recipient: "full@example.org",
+					Kind:      lmail.BounceSoft,
+					Status:    "4.2.2",
+					Reason:    "4.2.2",
+				},
+			},
+		},
+		{
+			name:    "arf",
+			message: arf,
+			expected: []lmail.Bounce{
+				{
+					Recipient: "complainer@isp.example.net",
+					Kind:      lmail.BounceComplaint,
+					Reason:    "complaint: abuse",
+				},
+			},
+		},
+		{
+			name:    "auto-reply",
+			message: "From: user@example.org\nTo: bounces@example.com\nSubject: Out of office\n\nI'm away.\n",
+			err:     lmail.ErrNotReport,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			bounces, err := lmail.ParseReport(strings.NewReader(test.message))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if e, a := test.expected, bounces; !reflect.DeepEqual(e, a) {
+				t.Errorf("expected bounces %+v, got %+v", e, a)
+			}
+		})
+	}
+}
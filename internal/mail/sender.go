@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"net/mail"
+)
+
+// Sender identifies who an email is sent from, and where replies and bounces to it go.
+type Sender struct {
+	// Address is the address the email is sent from, and Name the display name that mail
+	// clients show alongside it.
+	Address string
+	Name    string
+
+	// ReplyTo is an optional address that replies are sent to instead of Address.
+	ReplyTo string
+
+	// Envelope is the envelope sender, where the receiving servers send bounces to,
+	// defaulting to Address.
+	Envelope string
+}
+
+// Merge returns the sender with each of its empty fields set to that of fallback.
+func (s Sender) Merge(fallback Sender) Sender {
+	if s.Address == "" {
+		s.Address = fallback.Address
+	}
+
+	if s.Name == "" {
+		s.Name = fallback.Name
+	}
+
+	if s.ReplyTo == "" {
+		s.ReplyTo = fallback.ReplyTo
+	}
+
+	if s.Envelope == "" {
+		s.Envelope = fallback.Envelope
+	}
+
+	return s
+}
+
+// header returns the sender formatted as the value of a From header, with the display name
+// RFC 2047 encoded when it isn't plain ASCII.
+func (s Sender) header() string {
+	return (&mail.Address{Name: s.Name, Address: s.Address}).String()
+}
+
+// envelope returns the envelope sender of the sender.
+func (s Sender) envelope() string {
+	if s.Envelope != "" {
+		return s.Envelope
+	}
+
+	return s.Address
+}
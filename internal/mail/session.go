@@ -0,0 +1,268 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// codeServiceUnavailable is the SMTP reply code of a server that's closing the session.
+const codeServiceUnavailable = 421
+
+// client is an SMTP session whose connection's deadline is extended before each command.
+type client struct {
+	*smtp.Client
+	conn     net.Conn
+	timeout  time.Duration
+	lastUsed time.Time
+}
+
+// extend extends the deadline of the client's connection by its command timeout, capped
+// by the deadline of ctx.
+func (c *client) extend(ctx context.Context) {
+	_ = c.conn.SetDeadline(deadline(ctx, c.timeout))
+}
+
+// deadline returns the time the given timeout elapses from now, capped by the deadline of
+// ctx. The zero time, meaning no deadline, is returned when there's neither.
+func deadline(ctx context.Context, timeout time.Duration) time.Time {
+	var t time.Time
+	if timeout > 0 {
+		t = time.Now().Add(timeout)
+	}
+
+	if d, ok := ctx.Deadline(); ok && (t.IsZero() || d.Before(t)) {
+		t = d
+	}
+
+	return t
+}
+
+// transact sends email from the given envelope sender to each of the given recipients
+// within a single transaction. Recipients rejected by the server are returned as
+// RecipientErrors, once the email has been sent to the others.
+func (c *client) transact(ctx context.Context, from string, to []string, email []byte) error {
+	c.extend(ctx)
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+
+	rejected := make(RecipientErrors)
+	for _, rcpt := range to {
+		c.extend(ctx)
+		if err := c.Rcpt(rcpt); err != nil {
+			if !isRejection(err) {
+				return fmt.Errorf("rcpt to: %w", err)
+			}
+			rejected[rcpt] = err
+		}
+	}
+
+	if len(rejected) == len(to) {
+		return rejected
+	}
+
+	c.extend(ctx)
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
+	if _, err := w.Write(email); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("end data: %w", err)
+	}
+
+	if len(rejected) > 0 {
+		return rejected
+	}
+
+	return nil
+}
+
+// isRejection reports whether or not err is the server rejecting a command, rather than
+// the session failing, such that the session may still be used afterwards.
+func isRejection(err error) bool {
+	var rcptErrs RecipientErrors
+	if errors.As(err, &rcptErrs) {
+		return true
+	}
+
+	var tpErr *textproto.Error
+	return errors.As(err, &tpErr) && tpErr.Code != codeServiceUnavailable
+}
+
+// acquire returns a session with the relay, reusing an idle one when there is one and
+// dialing a new one otherwise, once fewer than MaxSessions are in use. Idle sessions that
+// the server has since dropped are discarded.
+func (r *relay) acquire(ctx context.Context) (*client, error) {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		select {
+		case c := <-r.idle:
+			if time.Since(c.lastUsed) > r.cfg.IdleTimeout {
+				c.quit(ctx)
+				continue
+			}
+
+			c.extend(ctx)
+			if err := c.Noop(); err != nil {
+				c.Close()
+				continue
+			}
+
+			return c, nil
+		default:
+			c, err := r.dial(ctx)
+			if err != nil {
+				<-r.slots
+				return nil, err
+			}
+
+			return c, nil
+		}
+	}
+}
+
+// release returns a session acquired with acquire to the pool, unless the transaction it
+// was used for failed in a way that leaves it unusable or the relay has been closed.
+func (r *relay) release(ctx context.Context, c *client, err error) {
+	defer func() {
+		<-r.slots
+	}()
+
+	if err != nil {
+		if !isRejection(err) {
+			c.Close()
+			return
+		}
+
+		// Rejections may leave a transaction open, which would fail the next one.
+		c.extend(ctx)
+		if err := c.Reset(); err != nil {
+			c.Close()
+			return
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		c.quit(ctx)
+		return
+	}
+
+	c.lastUsed = time.Now()
+	r.idle <- c
+}
+
+// close ends the relay's idle sessions. Sessions in use are ended once they're released.
+func (r *relay) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	for {
+		select {
+		case c := <-r.idle:
+			c.quit(context.Background())
+		default:
+			return
+		}
+	}
+}
+
+// quit politely ends the session, closing its connection regardless of whether or not
+// the server responds.
+func (c *client) quit(ctx context.Context) {
+	c.extend(ctx)
+	if err := c.Quit(); err != nil {
+		c.Close()
+	}
+}
+
+// dial connects to the relay, securing the connection and authenticating as the relay is
+// configured to.
+func (r *relay) dial(ctx context.Context) (*client, error) {
+	dialer := net.Dialer{Timeout: r.cfg.DialTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+
+	c := client{conn: conn, timeout: r.cfg.CommandTimeout}
+	c.extend(ctx)
+
+	if r.cfg.TLSMode == TLSModeImplicit {
+		tlsConn := tls.Client(conn, r.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	// NewClient reads the server's greeting, and knows the connection is secure when it's
+	// given a *tls.Conn.
+	if c.Client, err = smtp.NewClient(conn, r.cfg.Host); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("greeting: %w", err)
+	}
+
+	if err := r.handshake(ctx, &c); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// handshake identifies the client to the relay, upgrades the connection to TLS and
+// authenticates as the relay is configured to.
+func (r *relay) handshake(ctx context.Context, c *client) error {
+	if r.cfg.HELOName != "" {
+		c.extend(ctx)
+		if err := c.Hello(r.cfg.HELOName); err != nil {
+			return fmt.Errorf("hello: %w", err)
+		}
+	}
+
+	if r.cfg.TLSMode == TLSModeStartTLS || r.cfg.TLSMode == TLSModeStartTLSRequired {
+		c.extend(ctx)
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(r.tlsConfig); err != nil {
+				return fmt.Errorf("starttls: %w", err)
+			}
+		} else if r.cfg.TLSMode == TLSModeStartTLSRequired {
+			return ErrStartTLSUnsupported
+		}
+	}
+
+	if r.auth != nil {
+		c.extend(ctx)
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return ErrAuthUnsupported
+		}
+
+		if err := c.Auth(r.auth); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Transports that a Mailer may deliver email through.
+const (
+	// TransportSMTP sends email through the configured SMTP relays.
+	TransportSMTP = "smtp"
+
+	// TransportMaildir writes each email to its own file within a maildir.
+	TransportMaildir = "maildir"
+
+	// TransportMbox appends each email to an mbox file.
+	TransportMbox = "mbox"
+
+	// TransportMemory captures email in memory.
+	TransportMemory = "memory"
+)
+
+// Transports contains every transport that a Mailer may deliver email through.
+var Transports = []string{TransportSMTP, TransportMaildir, TransportMbox, TransportMemory}
+
+// Transport delivers email composed by a Mailer.
+type Transport interface {
+	// Send delivers the given email from the given envelope sender to each of the given
+	// recipients. Recipients that are rejected while others aren't are returned as
+	// RecipientErrors.
+	Send(ctx context.Context, from string, to []string, email []byte) error
+
+	// Close releases the resources held by the Transport.
+	Close() error
+}
+
+// smtpTransport is the Transport that sends email through SMTP relays, failing over
+// between them.
+type smtpTransport struct {
+	relays   []*relay
+	cooldown time.Duration
+	logger   *zap.Logger
+}
+
+// newSMTPTransport configures an smtpTransport given the configuration of its relays.
+func newSMTPTransport(cfgs []RelayConfig, cooldown time.Duration, logger *zap.Logger) (*smtpTransport, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("at least one relay must be configured")
+	}
+
+	if cooldown <= 0 {
+		cooldown = DefaultCooldown
+	}
+
+	relays := make([]*relay, len(cfgs))
+	for i := range cfgs {
+		r, err := newRelay(cfgs[i])
+		if err != nil {
+			return nil, fmt.Errorf("relay %s: %w", cfgs[i].addr(), err)
+		}
+		relays[i] = r
+	}
+
+	sort.SliceStable(relays, func(i, j int) bool {
+		return relays[i].cfg.Priority < relays[j].cfg.Priority
+	})
+
+	return &smtpTransport{
+		relays:   relays,
+		cooldown: cooldown,
+		logger:   logger,
+	}, nil
+}
+
+// Send implements the Transport interface, sending email within a single SMTP transaction
+// through the most preferred relay that doesn't fail to.
+func (t *smtpTransport) Send(ctx context.Context, from string, to []string, email []byte) error {
+	span := trace.SpanFromContext(ctx)
+
+	var err error
+	for _, r := range t.candidates(time.Now()) {
+		span.SetAttributes(attribute.String("smtp.addr", r.addr))
+
+		if err = r.send(ctx, from, to, email); err == nil || !shouldFailover(err) {
+			t.logger.Info("email sent",
+				zap.String("relay", r.addr), zap.Int("recipients", len(to)), zap.Error(err))
+			return err
+		}
+
+		// The caller giving up isn't the fault of the relay, so it isn't cooled down and
+		// there's no point failing over.
+		if ctx.Err() != nil {
+			break
+		}
+
+		r.fail(time.Now().Add(t.cooldown))
+		t.logger.Warn("smtp relay failed, failing over to the next relay",
+			zap.String("relay", r.addr), zap.Duration("cooldown", t.cooldown), zap.Error(err))
+	}
+
+	return err
+}
+
+// candidates returns the relays to attempt sending email through at the given time, in
+// order: healthy relays by preference followed by those cooling down after failing, so
+// that email is still attempted when every relay has recently failed.
+func (t *smtpTransport) candidates(now time.Time) []*relay {
+	candidates := make([]*relay, 0, len(t.relays))
+	for _, r := range t.relays {
+		if r.healthy(now) {
+			candidates = append(candidates, r)
+		}
+	}
+
+	for _, r := range t.relays {
+		if !r.healthy(now) {
+			candidates = append(candidates, r)
+		}
+	}
+
+	return candidates
+}
+
+// Close implements the Transport interface, ending the idle sessions with the relays.
+// Sessions in use are ended once they're released.
+func (t *smtpTransport) Close() error {
+	for _, r := range t.relays {
+		r.close()
+	}
+
+	return nil
+}
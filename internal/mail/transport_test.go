@@ -0,0 +1,154 @@
+package mail_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	lmail "github.com/22arw/lorafication/internal/mail"
+	"go.uber.org/zap"
+)
+
+// newTransportMailer returns a Mailer that delivers email through the given transport.
+func newTransportMailer(t *testing.T, transport lmail.Transport) *lmail.Mailer {
+	t.Helper()
+
+	mailer, err := lmail.NewMailer(lmail.Config{
+		Transport: transport,
+		Sender:    lmail.Sender{Address: "alerts@example.com", Envelope: "bounces@example.com"},
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("new mailer: %v", err)
+	}
+	t.Cleanup(func() { mailer.Close() })
+
+	return mailer
+}
+
+// TestMemoryTransport tests that a MemoryTransport captures the email sent through it
+// along with its envelope, keeping only the most recent emails.
+func TestMemoryTransport(t *testing.T) {
+	t.Parallel()
+
+	transport := lmail.NewMemoryTransport(2)
+	mailer := newTransportMailer(t, transport)
+
+	for _, to := range []string{"one@example.com", "two@example.com", "three@example.com"} {
+		if err := mailer.Send(context.Background(), lmail.Message{To: to, Subject: "Hello", Text: "Water level high"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	captured := transport.Captured()
+	if e, a := 2, len(captured); e != a {
+		t.Fatalf("expected %d captured emails, got %d", e, a)
+	}
+
+	if e, a := "bounces@example.com", captured[0].From; e != a {
+		t.Errorf("expected envelope sender %q, got %q", e, a)
+	}
+
+	if e, a := []string{"two@example.com"}, captured[0].To; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected recipients %q, got %q", e, a)
+	}
+
+	if e, a := "To: three@example.com\r\n", string(captured[1].Email); !strings.Contains(a, e) {
+		t.Errorf("expected email to contain %q", e)
+	}
+
+	transport.Reset()
+	if e, a := 0, len(transport.Captured()); e != a {
+		t.Errorf("expected %d captured emails after reset, got %d", e, a)
+	}
+}
+
+// TestMaildirTransport tests that a MaildirTransport writes each email to its own file
+// within the new directory of the maildir.
+func TestMaildirTransport(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "maildir")
+
+	transport, err := lmail.NewMaildirTransport(dir)
+	if err != nil {
+		t.Fatalf("new maildir transport: %v", err)
+	}
+	mailer := newTransportMailer(t, transport)
+
+	for i := 0; i < 2; i++ {
+		if err := mailer.Send(context.Background(), lmail.Message{To: "user@example.com", Subject: "Hello", Text: "Water level high"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatalf("read new directory: %v", err)
+	}
+
+	if e, a := 2, len(files); e != a {
+		t.Fatalf("expected %d emails, got %d", e, a)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "new", files[0].Name()))
+	if err != nil {
+		t.Fatalf("read email: %v", err)
+	}
+
+	for _, e := range []string{"Return-Path: <bounces@example.com>\n", "Delivered-To: user@example.com\n", "Subject: Hello\n"} {
+		if !strings.Contains(string(b), e) {
+			t.Errorf("expected email to contain %q", e)
+		}
+	}
+
+	if strings.Contains(string(b), "\r\n") {
+		t.Error("expected email to have local line endings")
+	}
+
+	if tmp, _ := ioutil.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("expected tmp directory to be empty, got %d files", len(tmp))
+	}
+}
+
+// TestMboxTransport tests that an MboxTransport appends each email to the mbox file,
+// escaping lines that would be mistaken for the start of another email.
+func TestMboxTransport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "mbox")
+
+	transport, err := lmail.NewMboxTransport(path)
+	if err != nil {
+		t.Fatalf("new mbox transport: %v", err)
+	}
+	mailer := newTransportMailer(t, transport)
+
+	for i := 0; i < 2; i++ {
+		if err := mailer.Send(context.Background(), lmail.Message{
+			To:      "user@example.com",
+			Subject: "Hello",
+			Text:    "From here on\n>From there on\n",
+		}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read mbox: %v", err)
+	}
+	mbox := string(b)
+
+	if e, a := 2, strings.Count("\n"+mbox, "\nFrom bounces@example.com "); e != a {
+		t.Errorf("expected %d emails, got %d", e, a)
+	}
+
+	for _, e := range []string{"\n>From here on\n", "\n>>From there on\n"} {
+		if !strings.Contains(mbox, e) {
+			t.Errorf("expected mbox to contain %q", e)
+		}
+	}
+}
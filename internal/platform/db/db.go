@@ -3,6 +3,8 @@ package db
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -11,14 +13,102 @@ import (
 	"go.uber.org/zap"
 )
 
+// SSLModes contains the postgres sslmode values supported by the underlying driver.
+var SSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
 // Config represents all of the information that needs to be passed to a postgres connection
-// string in order to resolve and connect to a database.
+// string in order to resolve and connect to a database, as well as how the resulting
+// connection pool should be sized.
 type Config struct {
+	// URL is an optional full connection string (either a postgres:// URL or a key/value
+	// DSN). When it is set it is used verbatim and the connection fields below are ignored.
+	URL string
+
 	User string
 	Pass string
 	Name string
 	Host string
 	Port int
+
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ApplicationName  string
+	StatementTimeout time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// DataSourceName returns the connection string that gets handed to the postgres driver.
+func (c Config) DataSourceName() string {
+	if c.URL != "" {
+		return c.URL
+	}
+
+	params := map[string]string{
+		"user":     c.User,
+		"password": c.Pass,
+		"dbname":   c.Name,
+		"host":     c.Host,
+		"port":     fmt.Sprintf("%d", c.Port),
+		"sslmode":  c.SSLMode,
+	}
+
+	if params["sslmode"] == "" {
+		params["sslmode"] = "disable"
+	}
+
+	if c.SSLRootCert != "" {
+		params["sslrootcert"] = c.SSLRootCert
+	}
+
+	if c.SSLCert != "" {
+		params["sslcert"] = c.SSLCert
+	}
+
+	if c.SSLKey != "" {
+		params["sslkey"] = c.SSLKey
+	}
+
+	if c.ApplicationName != "" {
+		params["application_name"] = c.ApplicationName
+	}
+
+	// Unknown keys are sent to the server as run-time parameters, statement_timeout
+	// being interpreted in milliseconds.
+	if c.StatementTimeout > 0 {
+		params["statement_timeout"] = fmt.Sprintf("%d", c.StatementTimeout.Milliseconds())
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, quote(params[key])))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// quote quotes a value of a key/value connection string so that it may safely contain
+// spaces, quotes and backslashes.
+func quote(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+
+	return "'" + value + "'"
 }
 
 // NewConnection returns a new database connection with the schema applied, if not already
@@ -27,11 +117,12 @@ func NewConnection(logger *zap.Logger, cfg Config) (*sqlx.DB, error) {
 	var db *sqlx.DB
 	var err error
 
-	conn := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%d sslmode=disable",
-		cfg.User, cfg.Pass, cfg.Name, cfg.Host, cfg.Port)
+	conn := cfg.DataSourceName()
 
 	logger.Info("connecting to postgres database...")
 	if db, err = sqlx.Connect("postgres", conn); err != nil {
+		logger.Warn("unable to connect to postgres database, retrying", zap.Error(err))
+
 		ticker := time.NewTicker(time.Second * 1)
 		defer ticker.Stop()
 
@@ -43,6 +134,11 @@ func NewConnection(logger *zap.Logger, cfg Config) (*sqlx.DB, error) {
 	}
 	logger.Info("connected to postgres database")
 
+	// Size the connection pool.
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	logger.Info("verifying postgres connection...")
 	if err := db.Ping(); err != nil {
 		ticker := time.NewTicker(time.Second * 1)
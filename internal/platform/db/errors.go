@@ -0,0 +1,74 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of postgres errors that are classified by Classify.
+var (
+	ErrUniqueViolation     = errors.New("unique violation")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrCheckViolation      = errors.New("check violation")
+	ErrNotNullViolation    = errors.New("not null violation")
+	ErrInvalidInput        = errors.New("invalid input")
+)
+
+// kinds maps postgres error codes to the kind of error they represent.
+var kinds = map[pq.ErrorCode]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23514": ErrCheckViolation,
+	"23502": ErrNotNullViolation,
+	"22P02": ErrInvalidInput, // invalid_text_representation, i.e. a malformed UUID.
+	"22001": ErrInvalidInput, // string_data_right_truncation, i.e. a value too long for its column.
+}
+
+// Error is a postgres error that was caused by the data given to a statement rather than
+// by the database itself. Using errors.Is, it matches the kind of error it was classified
+// as.
+type Error struct {
+	Kind       error
+	Column     string
+	Constraint string
+
+	err *pq.Error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Is reports whether or not target is the kind of the error.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying *pq.Error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Classify returns the *Error corresponding to the postgres error within the given error's
+// chain. If there isn't one, or it wasn't caused by the data given to a statement, nil is
+// returned.
+func Classify(err error) *Error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	kind, ok := kinds[pqErr.Code]
+	if !ok {
+		return nil
+	}
+
+	return &Error{
+		Kind:       kind,
+		Column:     pqErr.Column,
+		Constraint: pqErr.Constraint,
+		err:        pqErr,
+	}
+}
@@ -14,16 +14,77 @@ CREATE TABLE IF NOT EXISTS node(
 	modified timestamp NOT NULL DEFAULT NOW()
 );
 
+-- Nodes may send their notifications from their own address, display name and reply-to
+-- address instead of the daemon's, left empty to use the daemon's.
+ALTER TABLE node ADD COLUMN IF NOT EXISTS sender_address varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE node ADD COLUMN IF NOT EXISTS sender_name varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE node ADD COLUMN IF NOT EXISTS reply_to varchar(255) NOT NULL DEFAULT '';
+
 CREATE TABLE IF NOT EXISTS entity(
 	id serial PRIMARY KEY,
 	name varchar(255) NOT NULL,
-	email varchar(255),
-	sms integer,
+	created timestamp NOT NULL DEFAULT NOW(),
+	modified timestamp NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS contact_point(
+	id serial PRIMARY KEY,
+	entity_id integer NOT NULL,
+	type varchar(32) NOT NULL,
+	address varchar(255) NOT NULL,
+	verified_at timestamp,
+	priority integer NOT NULL DEFAULT 0,
 	created timestamp NOT NULL DEFAULT NOW(),
 	modified timestamp NOT NULL DEFAULT NOW(),
-	CONSTRAINT notify_channels_check CHECK (email IS NOT NULL OR sms IS NOT NULL)
+	FOREIGN KEY(entity_id) REFERENCES entity(id) ON DELETE CASCADE,
+	CONSTRAINT contact_point_address_unique UNIQUE (entity_id, type, address)
 );
 
+-- Entities used to hold a single email and sms number of their own. Those are migrated
+-- into contact points, already verified as they were being notified, and then dropped.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'entity' AND column_name = 'email') THEN
+		INSERT INTO contact_point (entity_id, type, address, verified_at)
+			SELECT id, 'email', email, NOW() FROM entity WHERE email IS NOT NULL;
+
+		INSERT INTO contact_point (entity_id, type, address, verified_at)
+			SELECT id, 'sms', '+' || sms::text, NOW() FROM entity WHERE sms IS NOT NULL;
+
+		ALTER TABLE entity DROP CONSTRAINT IF EXISTS notify_channels_check;
+		ALTER TABLE entity DROP COLUMN email;
+		ALTER TABLE entity DROP COLUMN sms;
+	END IF;
+END $$;
+
+-- Contact points used to be marked as verified with a boolean rather than when they were
+-- verified.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'contact_point' AND column_name = 'verified') THEN
+		ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS verified_at timestamp;
+		UPDATE contact_point SET verified_at = modified WHERE verified;
+		ALTER TABLE contact_point DROP COLUMN verified;
+	END IF;
+END $$;
+
+-- Email contact points record the bounces reported for their address, and are suspended
+-- once they've hard bounced too many times or their recipient complains.
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS bounce_count integer NOT NULL DEFAULT 0;
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS bounced_at timestamp;
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS bounce_reason text NOT NULL DEFAULT '';
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS suspended_at timestamp;
+
+-- Webhook contact points sign the events posted to them with a secret shared with their
+-- receiver.
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS secret varchar(255) NOT NULL DEFAULT '';
+
+-- Phone contact points are verified with a short code sent to them, of which only the
+-- hash is stored, that may only be guessed a few times before it expires.
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS verification_code varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS verification_code_expires timestamp;
+ALTER TABLE contact_point ADD COLUMN IF NOT EXISTS verification_attempts integer NOT NULL DEFAULT 0;
+
 CREATE TABLE IF NOT EXISTS contract(
 	id serial PRIMARY KEY,
 	node_public_key UUID NOT NULL,
@@ -32,4 +93,99 @@ CREATE TABLE IF NOT EXISTS contract(
 	modified timestamp NOT NULL DEFAULT NOW(),
 	FOREIGN KEY(node_public_key) REFERENCES node(public_key),
 	FOREIGN KEY(entity_id) REFERENCES entity(id)
-);`
+);
+
+-- Contracts are deactivated, rather than deleted, when an entity unsubscribes from a node
+-- so that the reason for it is kept.
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS active boolean NOT NULL DEFAULT true;
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS deactivated timestamp;
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS deactivation_reason text;
+
+CREATE TABLE IF NOT EXISTS template(
+	id serial PRIMARY KEY,
+	node_public_key UUID,
+	subject text NOT NULL DEFAULT '',
+	html_body text NOT NULL DEFAULT '',
+	text_body text NOT NULL DEFAULT '',
+	sms_text text NOT NULL DEFAULT '',
+	created timestamp NOT NULL DEFAULT NOW(),
+	modified timestamp NOT NULL DEFAULT NOW(),
+	FOREIGN KEY(node_public_key) REFERENCES node(public_key)
+);
+
+-- There is at most one template per node, and one organisation default template without
+-- a node.
+CREATE UNIQUE INDEX IF NOT EXISTS template_node_unique
+	ON template ((COALESCE(node_public_key, '00000000-0000-0000-0000-000000000000')));
+
+CREATE TABLE IF NOT EXISTS notification(
+	id serial PRIMARY KEY,
+	node_public_key UUID NOT NULL,
+	message text NOT NULL,
+	severity varchar(32) NOT NULL DEFAULT '',
+	payload jsonb,
+	created timestamp NOT NULL DEFAULT NOW(),
+	FOREIGN KEY(node_public_key) REFERENCES node(public_key)
+);
+
+-- Each notification is delivered to every contact point of the entities subscribed to its
+-- node, deliveries being retried until they succeed, fail permanently or run out of
+-- attempts.
+CREATE TABLE IF NOT EXISTS delivery(
+	id serial PRIMARY KEY,
+	notification_id integer NOT NULL,
+	contract_id integer NOT NULL,
+	contact_point_id integer NOT NULL,
+	channel varchar(32) NOT NULL,
+	status varchar(32) NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	last_error text NOT NULL DEFAULT '',
+	next_attempt_at timestamp NOT NULL DEFAULT NOW(),
+	delivered_at timestamp,
+	created timestamp NOT NULL DEFAULT NOW(),
+	modified timestamp NOT NULL DEFAULT NOW(),
+	FOREIGN KEY(notification_id) REFERENCES notification(id) ON DELETE CASCADE,
+	FOREIGN KEY(contract_id) REFERENCES contract(id),
+	FOREIGN KEY(contact_point_id) REFERENCES contact_point(id) ON DELETE CASCADE,
+	CONSTRAINT delivery_status_check CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS delivery_pending
+	ON delivery (next_attempt_at) WHERE status = 'pending';
+
+-- Notifications are acknowledged by the first contact point to follow the acknowledge link
+-- of their delivery.
+ALTER TABLE notification ADD COLUMN IF NOT EXISTS acknowledged_at timestamp;
+ALTER TABLE notification ADD COLUMN IF NOT EXISTS acknowledged_by integer
+	REFERENCES contact_point(id) ON DELETE SET NULL;
+
+-- Notifications are ranked by severity, custom severities being ranked between or beyond
+-- the built-in ones by their level.
+CREATE TABLE IF NOT EXISTS severity(
+	name varchar(32) PRIMARY KEY,
+	level integer NOT NULL,
+	created timestamp NOT NULL DEFAULT NOW(),
+	CONSTRAINT severity_level_unique UNIQUE (level)
+);
+
+INSERT INTO severity (name, level) VALUES ('info', 100), ('warning', 200), ('critical', 300)
+	ON CONFLICT DO NOTHING;
+
+-- Notifications used to be sent without a severity, which are now info notifications.
+UPDATE notification SET severity = 'info' WHERE severity = '';
+ALTER TABLE notification ALTER COLUMN severity SET DEFAULT 'info';
+
+-- Contracts may only be notified of notifications of a minimum severity, and through only
+-- some of their entity's channels for each severity, keyed by severity.
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS min_severity varchar(32)
+	REFERENCES severity(name);
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS severity_channels jsonb;
+
+-- Contracts may be notified through only some of their entity's channels, every channel
+-- being notified when none are listed, and only during their active hours, in the time zone
+-- of the contract. Disabled contracts are kept but not notified until they're enabled.
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS channels varchar(32)[];
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS active_from time;
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS active_until time;
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS timezone varchar(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE contract ADD COLUMN IF NOT EXISTS enabled boolean NOT NULL DEFAULT true;`
@@ -0,0 +1,192 @@
+// Package metrics exposes the prometheus collectors of the lorafication daemon along
+// with the handler that serves them.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace is the prefix of every metric exposed by the lorafication daemon.
+const namespace = "lorafication"
+
+// Outcome label values used on delivery and SMTP metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// countTimeout is how long a collector that counts rows of the database is given when
+// metrics are scraped.
+const countTimeout = 5 * time.Second
+
+// Metrics holds every collector of the lorafication daemon within its own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	notifications    *prometheus.CounterVec
+	deliveries       *prometheus.CounterVec
+	smtpSendDuration *prometheus.HistogramVec
+	bounces          *prometheus.CounterVec
+}
+
+// New returns a reference to a Metrics type with all of its collectors registered,
+// including the standard go runtime and process collectors.
+func New() *Metrics {
+	m := Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests handled, partitioned by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests handled, partitioned by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		notifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "notifications_total",
+			Help:      "Total number of authenticated notifications received, partitioned by node and severity.",
+		}, []string{"node", "severity"}),
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deliveries_total",
+			Help:      "Total number of notification deliveries attempted, partitioned by channel, severity and outcome.",
+		}, []string{"channel", "severity", "outcome"}),
+		smtpSendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "smtp_send_duration_seconds",
+			Help:      "Latency of sending an email over SMTP, partitioned by outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		bounces: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bounces_total",
+			Help:      "Total number of email bounces and complaints recorded, partitioned by kind.",
+		}, []string{"kind"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.notifications,
+		m.deliveries,
+		m.smtpSendDuration,
+		m.bounces,
+	)
+
+	return &m
+}
+
+// RegisterDB registers a collector exposing the connection pool statistics of the given
+// database.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	if err := m.registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		return fmt.Errorf("register db stats collector: %w", err)
+	}
+
+	return nil
+}
+
+// RegisterPendingDeliveries registers a gauge of the number of deliveries pending within
+// the outbox, counted with the given function whenever metrics are scraped.
+func (m *Metrics) RegisterPendingDeliveries(count func(context.Context) (int, error)) error {
+	c := countCollector{
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "deliveries_pending"),
+			"Number of notification deliveries pending within the outbox.", nil, nil),
+		count: count,
+	}
+
+	if err := m.registry.Register(c); err != nil {
+		return fmt.Errorf("register pending deliveries collector: %w", err)
+	}
+
+	return nil
+}
+
+// countCollector is a prometheus.Collector exposing a gauge whose value is counted when
+// it's collected, such as a number of rows of the database.
+type countCollector struct {
+	desc  *prometheus.Desc
+	count func(context.Context) (int, error)
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements the prometheus.Collector interface. Failing to count is reported as
+// an invalid metric, failing the scrape, rather than as a misleading value.
+func (c countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+	defer cancel()
+
+	n, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
+
+// Handler returns the http.Handler that serves the metrics in the prometheus exposition
+// format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a handled HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.requestDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// IncNotifications records a notification of the given severity received from the node
+// with the given public key.
+func (m *Metrics) IncNotifications(nodePublicKey, severity string) {
+	m.notifications.WithLabelValues(nodePublicKey, severity).Inc()
+}
+
+// ObserveDelivery records the outcome of the delivery of a notification of the given
+// severity over the given channel.
+func (m *Metrics) ObserveDelivery(channel, severity string, err error) {
+	m.deliveries.WithLabelValues(channel, severity, outcome(err)).Inc()
+}
+
+// ObserveSMTPSend records the latency and outcome of sending an email over SMTP.
+func (m *Metrics) ObserveSMTPSend(d time.Duration, err error) {
+	m.smtpSendDuration.WithLabelValues(outcome(err)).Observe(d.Seconds())
+}
+
+// IncBounces records a bounce of the given kind.
+func (m *Metrics) IncBounces(kind string) {
+	m.bounces.WithLabelValues(kind).Inc()
+}
+
+// outcome returns the outcome label value corresponding to the given error.
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+}
@@ -0,0 +1,94 @@
+// Package token creates and verifies signed, expiring tokens that identify a single
+// resource for a single purpose, such as verifying a contact point. Tokens are URL safe
+// so that they can be embedded within links.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when verifying a token.
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("expired token")
+)
+
+// Signer signs and verifies tokens using HMAC-SHA256.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a reference to a Signer type that signs tokens using the given key.
+func NewSigner(key []byte) *Signer {
+	return &Signer{
+		key: key,
+	}
+}
+
+// Sign returns a token for the resource with the given ID, only valid for the given
+// purpose and until the given expiry. The purpose must not contain a colon.
+func (s *Signer) Sign(purpose string, id int, expires time.Time) string {
+	payload := fmt.Sprintf("%s:%d:%d", purpose, id, expires.Unix())
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// Verify verifies that the given token was signed by the receiver for the given purpose
+// and hasn't expired, returning the ID of the resource it was signed for.
+func (s *Signer) Verify(purpose, token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return 0, ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	if !hmac.Equal(mac, s.mac(string(payload))) {
+		return 0, ErrInvalid
+	}
+
+	fields := strings.Split(string(payload), ":")
+	if len(fields) != 3 || fields[0] != purpose {
+		return 0, ErrInvalid
+	}
+
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+
+	if now.After(time.Unix(expires, 0)) {
+		return 0, ErrExpired
+	}
+
+	return id, nil
+}
+
+// mac returns the HMAC-SHA256 of the given payload using the receiver's key.
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,40 @@
+// Package token_test tests the token package.
+package token_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/22arw/lorafication/internal/platform/token"
+)
+
+// TestSigner tests that tokens signed by a Signer verify for their purpose and ID only.
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	signer := token.NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	tok := signer.Sign("verify", 42, now.Add(time.Hour))
+
+	id, err := signer.Verify("verify", tok, now)
+	if err != nil {
+		t.Fatalf("verify token: %v", err)
+	}
+
+	if e, a := 42, id; e != a {
+		t.Errorf("expected verified ID to be %d, got %d", e, a)
+	}
+
+	if _, err := signer.Verify("unsubscribe", tok, now); !errors.Is(err, token.ErrInvalid) {
+		t.Errorf("expected token verified for another purpose to be invalid, got %v", err)
+	}
+
+	if _, err := signer.Verify("verify", tok, now.Add(2*time.Hour)); !errors.Is(err, token.ErrExpired) {
+		t.Errorf("expected token verified after its expiry to be expired, got %v", err)
+	}
+
+	if _, err := token.NewSigner([]byte("another key")).Verify("verify", tok, now); !errors.Is(err, token.ErrInvalid) {
+		t.Errorf("expected token verified with another key to be invalid, got %v", err)
+	}
+}
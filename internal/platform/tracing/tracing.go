@@ -0,0 +1,125 @@
+// Package tracing configures OpenTelemetry tracing for the lorafication daemon and
+// provides helpers for creating spans throughout it.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer that every span of the lorafication
+// daemon is created with.
+const instrumentationName = "github.com/22arw/lorafication"
+
+// Config represents all of the information needed to export traces over OTLP.
+type Config struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector, tracing is disabled when
+	// it is empty.
+	Endpoint string
+
+	// Insecure disables TLS when connecting to the collector.
+	Insecure bool
+
+	// SampleRatio is the ratio of traces, in the range [0, 1], that are sampled when
+	// they don't have a sampled parent.
+	SampleRatio float64
+
+	// ServiceName is the name of the service that the spans are attributed to.
+	ServiceName string
+}
+
+// Init installs the W3C trace context propagator and, if an endpoint is configured, a
+// global tracer provider exporting spans over OTLP/HTTP. The returned function flushes
+// and stops the exporting of spans and must be called before the daemon exits.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+
+	tp := NewProvider(cfg, sdktrace.NewBatchSpanProcessor(exporter))
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider, sampling as described by cfg, that hands its
+// spans to the given span processor. Outside of Init this allows for spans to be
+// exported in-process, such as to a tracetest.SpanRecorder.
+func NewProvider(cfg Config, sp sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(sp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.ServiceName))),
+	)
+}
+
+// Start creates a span with the given name and attributes from the global tracer
+// provider, returning it along with a context containing it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartHTTP creates a server span for the given incoming request, continuing any trace
+// propagated to it through W3C trace context headers. The span is named after the
+// method and path of the request, which should be replaced with the matched route once
+// known. The http.target attribute is set to the given target rather than the raw
+// request URI, so that callers may redact secrets, such as tokens, from its query.
+func StartHTTP(r *http.Request, target string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	attrs := semconv.HTTPServerAttributesFromHTTPRequest("loraficationd", "", r)
+	for i := range attrs {
+		if attrs[i].Key == semconv.HTTPTargetKey {
+			attrs[i] = semconv.HTTPTargetKey.String(target)
+		}
+	}
+
+	return otel.Tracer(instrumentationName).Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...))
+}
+
+// EndHTTP ends the given server span, naming it after the matched route and recording
+// the status code of the response.
+func EndHTTP(span trace.Span, method, route string, status int) {
+	span.SetName(method + " " + route)
+	span.SetAttributes(semconv.HTTPRouteKey.String(route))
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+	span.End()
+}
+
+// End ends the given span, marking it as errored if err is non-nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
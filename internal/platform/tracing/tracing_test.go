@@ -0,0 +1,47 @@
+// Package tracing_test tests the tracing package.
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestEnd tests that End marks spans that failed as errored, recording the error as an
+// event, and leaves those that succeeded unset.
+func TestEnd(t *testing.T) {
+	t.Parallel()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := tracing.NewProvider(tracing.Config{SampleRatio: 1, ServiceName: "test"}, sr)
+	defer func() {
+		_ = tp.Shutdown(context.Background())
+	}()
+
+	_, failed := tp.Tracer("test").Start(context.Background(), "failed")
+	tracing.End(failed, errors.New("retrieve record from table: no rows"))
+
+	_, succeeded := tp.Tracer("test").Start(context.Background(), "succeeded")
+	tracing.End(succeeded, nil)
+
+	spans := sr.Ended()
+	if e, a := 2, len(spans); e != a {
+		t.Fatalf("expected %d ended spans, got %d", e, a)
+	}
+
+	if e, a := codes.Error, spans[0].Status().Code; e != a {
+		t.Errorf("expected failed span status to be %v, got %v", e, a)
+	}
+
+	if e, a := 1, len(spans[0].Events()); e != a {
+		t.Errorf("expected failed span to have %d event, got %d", e, a)
+	}
+
+	if e, a := codes.Unset, spans[1].Status().Code; e != a {
+		t.Errorf("expected succeeded span status to be %v, got %v", e, a)
+	}
+}
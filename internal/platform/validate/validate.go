@@ -0,0 +1,131 @@
+// Package validate contains helpers for validating the values of request fields. Each
+// helper returns an error describing why the value is invalid, or nil if it is valid.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pborman/uuid"
+)
+
+// MaxVarchar is the maximum length of the varchar(255) columns of the database schema.
+const MaxVarchar = 255
+
+// e164 matches phone numbers in the E.164 format, a leading plus followed by up to 15
+// digits, the first of which (the country code) can't be zero.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// telegramChatID matches the ID of a Telegram chat, being either a signed integer or the
+// @username of a public channel.
+var telegramChatID = regexp.MustCompile(`^(-?[0-9]{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+// pushoverKey matches the key of a Pushover user or group, 30 letters and digits.
+var pushoverKey = regexp.MustCompile(`^[A-Za-z0-9]{30}$`)
+
+// matrixRoomID matches the ID of a Matrix room, an exclamation mark followed by an opaque
+// local part and the server name that the room was created on.
+var matrixRoomID = regexp.MustCompile(`^![^:\s]+:[^\s]+$`)
+
+// Required validates that the value isn't empty or made up solely of whitespace.
+func Required(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return errors.New("must be provided")
+	}
+
+	return nil
+}
+
+// MaxLength validates that the value is at most max characters long.
+func MaxLength(value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("must be at most %d characters", max)
+	}
+
+	return nil
+}
+
+// Email validates that the value is a bare RFC 5322 email address, without a display
+// name or angle brackets.
+func Email(value string) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Name != "" || addr.Address != value {
+		return errors.New("must be a valid email address")
+	}
+
+	return nil
+}
+
+// E164 validates that the value is a phone number in the E.164 format, such as
+// +15555550123.
+func E164(value string) error {
+	if !e164.MatchString(value) {
+		return errors.New("must be an E.164 phone number, such as +15555550123")
+	}
+
+	return nil
+}
+
+// HTTPURL validates that the value is an absolute http or https URL, such as
+// https://example.com/hooks/lorafication.
+func HTTPURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http or https url")
+	}
+
+	return nil
+}
+
+// TelegramChatID validates that the value is the ID of a Telegram chat, such as
+// -1001234567890, or the username of a public channel, such as @ourcityalerts.
+func TelegramChatID(value string) error {
+	if !telegramChatID.MatchString(value) {
+		return errors.New("must be a telegram chat id or @channel username")
+	}
+
+	return nil
+}
+
+// MatrixRoomID validates that the value is the ID of a Matrix room, such as
+// !abcdefgh:matrix.org. Room aliases, such as #alerts:matrix.org, aren't IDs.
+func MatrixRoomID(value string) error {
+	if !matrixRoomID.MatchString(value) {
+		return errors.New("must be a matrix room id, such as !abcdefgh:matrix.org")
+	}
+
+	return nil
+}
+
+// PushoverKey validates that the value is the key of a Pushover user or group, such as
+// uQiRzpo4DXghDmr9QzzfQu27cmVRsG.
+func PushoverKey(value string) error {
+	if !pushoverKey.MatchString(value) {
+		return errors.New("must be a pushover user or group key")
+	}
+
+	return nil
+}
+
+// UUID validates that the value is a UUID.
+func UUID(value string) error {
+	if uuid.Parse(value) == nil {
+		return errors.New("must be a UUID")
+	}
+
+	return nil
+}
+
+// Positive validates that the value is greater than zero.
+func Positive(value int) error {
+	if value <= 0 {
+		return errors.New("must be greater than 0")
+	}
+
+	return nil
+}
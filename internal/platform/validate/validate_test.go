@@ -0,0 +1,142 @@
+// Package validate_test tests the validate package.
+package validate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/22arw/lorafication/internal/platform/validate"
+)
+
+// TestEmail tests the Email function of the validate package.
+func TestEmail(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"alerts@ourcity.gov":                 true,
+		"first.last+tag@sub.example.com":     true,
+		"":                                   false,
+		"not-an-email":                       false,
+		"Alerts <alerts@ourcity.gov>":        false,
+		"alerts@ourcity.gov, other@city.gov": false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.Email(value) == nil; e != a {
+			t.Errorf("expected validity of email \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestE164 tests the E164 function of the validate package.
+func TestE164(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"+15555550123":      true,
+		"+447911123456":     true,
+		"15555550123":       false,
+		"+05555550123":      false,
+		"+1555555012345678": false,
+		"+1 555 555 0123":   false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.E164(value) == nil; e != a {
+			t.Errorf("expected validity of phone number \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestHTTPURL tests the HTTPURL function of the validate package.
+func TestHTTPURL(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"https://example.com/hooks/lorafication": true,
+		"http://10.0.0.5:8080/alerts":            true,
+		"":                                       false,
+		"example.com/hooks":                      false,
+		"ftp://example.com/hooks":                false,
+		"https:///hooks":                         false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.HTTPURL(value) == nil; e != a {
+			t.Errorf("expected validity of url \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestTelegramChatID tests the TelegramChatID function of the validate package.
+func TestTelegramChatID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"123456789":      true,
+		"-1001234567890": true,
+		"@ourcityalerts": true,
+		"":               false,
+		"@abc":           false,
+		"ourcityalerts":  false,
+		"12 34":          false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.TelegramChatID(value) == nil; e != a {
+			t.Errorf("expected validity of telegram chat id \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestMatrixRoomID tests the MatrixRoomID function of the validate package.
+func TestMatrixRoomID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"!abcdefgh:matrix.org":     true,
+		"!abc123:example.com:8448": true,
+		"":                         false,
+		"#alerts:matrix.org":       false,
+		"!abcdefgh":                false,
+		"!abc defgh:matrix.org":    false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.MatrixRoomID(value) == nil; e != a {
+			t.Errorf("expected validity of matrix room id \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestPushoverKey tests the PushoverKey function of the validate package.
+func TestPushoverKey(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"uQiRzpo4DXghDmr9QzzfQu27cmVRsG":  true,
+		"":                                false,
+		"uQiRzpo4DXghDmr9QzzfQu27cmVRs":   false,
+		"uQiRzpo4DXghDmr9QzzfQu27cmVRsG1": false,
+		"uQiRzpo4DXghDmr9QzzfQu27cmVRs_":  false,
+	}
+
+	for value, valid := range tests {
+		if e, a := valid, validate.PushoverKey(value) == nil; e != a {
+			t.Errorf("expected validity of pushover key \"%s\" to be %t, got %t", value, e, a)
+		}
+	}
+}
+
+// TestMaxLength tests the MaxLength function of the validate package.
+func TestMaxLength(t *testing.T) {
+	t.Parallel()
+
+	if err := validate.MaxLength(strings.Repeat("é", validate.MaxVarchar), validate.MaxVarchar); err != nil {
+		t.Errorf("expected value of max length to be valid, got %v", err)
+	}
+
+	if err := validate.MaxLength(strings.Repeat("a", validate.MaxVarchar+1), validate.MaxVarchar); err == nil {
+		t.Error("expected value over max length to be invalid")
+	}
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxBodyBytes is the maximum size of a request body that Decode will read.
+const MaxBodyBytes = 1 << 20
+
+// Validator is implemented by request types that can validate their own fields.
+type Validator interface {
+	Validate() error
+}
+
+// Decode decodes the JSON request body into v, rejecting bodies over MaxBodyBytes,
+// unknown fields and trailing data. If v implements Validator, it is validated once
+// decoded. The returned errors are typed so that they can be handed straight to
+// RespondError.
+func Decode(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		if err.Error() == "http: request body too large" {
+			return &Error{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("request body must be at most %d bytes", MaxBodyBytes),
+				Err:     err,
+			}
+		}
+
+		return NewBadRequestError(err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return NewBadRequestError(errors.New("request body must only contain a single JSON value"))
+	}
+
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
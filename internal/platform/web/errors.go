@@ -0,0 +1,134 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+)
+
+// StatusCoder is implemented by errors that know the HTTP status code that they should be
+// responded to with.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// FieldError describes what is wrong with a single field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error whose message and field details are safe to respond to a client with.
+// It implements StatusCoder, dictating the status code of the response.
+type Error struct {
+	Code    int
+	Message string
+	Fields  []FieldError
+
+	// Err is the underlying cause of the error. It is logged but never responded with.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+// StatusCode implements the StatusCoder interface.
+func (e *Error) StatusCode() int {
+	return e.Code
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewBadRequestError returns an error responded to with a 400 for requests that are
+// malformed, such as request bodies that can't be decoded.
+func NewBadRequestError(err error) error {
+	return &Error{
+		Code:    http.StatusBadRequest,
+		Message: err.Error(),
+		Err:     err,
+	}
+}
+
+// NewUnauthorizedError returns an error responded to with a 401 for requests whose
+// credentials couldn't be verified.
+func NewUnauthorizedError(message string, err error) error {
+	return &Error{
+		Code:    http.StatusUnauthorized,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// NewNotFoundError returns an error responded to with a 404 for requests referencing
+// resources that don't exist.
+func NewNotFoundError(message string, err error, fields ...FieldError) error {
+	return &Error{
+		Code:    http.StatusNotFound,
+		Message: message,
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// NewConflictError returns an error responded to with a 409 for requests that conflict
+// with the current state of a resource, such as creating a duplicate.
+func NewConflictError(message string, err error, fields ...FieldError) error {
+	return &Error{
+		Code:    http.StatusConflict,
+		Message: message,
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// NewValidationError returns an error responded to with a 422 for requests that are well
+// formed but whose fields hold invalid values.
+func NewValidationError(message string, err error, fields ...FieldError) error {
+	return &Error{
+		Code:    http.StatusUnprocessableEntity,
+		Message: message,
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// asError returns the *Error within the given error's chain, if any.
+func asError(err error) (*Error, bool) {
+	var webErr *Error
+	if errors.As(err, &webErr) {
+		return webErr, true
+	}
+
+	return nil, false
+}
+
+// FieldErrors collects the field errors of a request as it is validated.
+type FieldErrors []FieldError
+
+// Check records the first non-nil error of errs against the given field, if any.
+func (f *FieldErrors) Check(field string, errs ...error) {
+	for _, err := range errs {
+		if err != nil {
+			*f = append(*f, FieldError{Field: field, Message: err.Error()})
+			return
+		}
+	}
+}
+
+// Err returns a validation error holding the collected field errors, or nil if none were
+// collected.
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+
+	return NewValidationError("request failed validation", nil, f...)
+}
@@ -2,18 +2,93 @@ package web
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/22arw/lorafication/internal/platform/tracing"
 	"github.com/pborman/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 // requestIDHeader contains the key of the header field that stores a request ID.
 const requestIDHeader = "X-Request-ID"
 
+// requestIDAttribute is the span attribute that a request ID is recorded under, tying
+// traces to the logs of the request.
+const requestIDAttribute = attribute.Key("http.request_id")
+
+// redactedParams are the query parameters whose values are redacted from logged URIs, as
+// they hold signed tokens that anyone with access to the logs could replay.
+var redactedParams = []string{"token"}
+
+// RedactedURI returns the path and query of the request's URL, with the values of
+// redactedParams within the query replaced so that the URI is safe to log.
+func RedactedURI(r *http.Request) string {
+	q := r.URL.Query()
+	if len(q) == 0 {
+		return r.URL.Path
+	}
+
+	for _, p := range redactedParams {
+		if _, ok := q[p]; ok {
+			q.Set(p, "REDACTED")
+		}
+	}
+
+	return r.URL.Path + "?" + q.Encode()
+}
+
+// unmatchedRoute is the route recorded for requests that never reached a handler
+// registered through Route.
+const unmatchedRoute = "unmatched"
+
+// ctxKey is the type of the keys used to store values on a request context.
+type ctxKey int
+
+// keyValues is the key that the *Values of a request are stored under.
+const keyValues ctxKey = 1
+
+// Values contains request-scoped state that is set by RequestMW and filled in as the
+// request makes its way through the handlers.
+type Values struct {
+	RequestID string
+	Route     string
+
+	// Logger is the request-scoped logger, carrying the request ID along with any
+	// fields added through AddLogFields.
+	Logger *zap.Logger
+}
+
+// GetValues returns the *Values stored on the given context, or nil if there are none.
+func GetValues(ctx context.Context) *Values {
+	v, _ := ctx.Value(keyValues).(*Values)
+	return v
+}
+
+// Logger returns the request-scoped logger stored on the given context. If the context
+// didn't pass through RequestMW, a no-op logger is returned.
+func Logger(ctx context.Context) *zap.Logger {
+	if v := GetValues(ctx); v != nil && v.Logger != nil {
+		return v.Logger
+	}
+
+	return zap.NewNop()
+}
+
+// AddLogFields adds the given fields to the request-scoped logger stored on the given
+// context, so that they appear on every subsequent log of the request, including the
+// log emitted upon its completion.
+func AddLogFields(ctx context.Context, fields ...zap.Field) {
+	if v := GetValues(ctx); v != nil && v.Logger != nil {
+		v.Logger = v.Logger.With(fields...)
+	}
+}
+
 // responseWriter wraps an http.ResponseWriter so we can
 // capture the status code.
 type responseWriter struct {
@@ -37,10 +112,26 @@ func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return h.Hijack()
 }
 
+// Route wraps a handler so that the pattern it was registered under is recorded on the
+// request's Values. The pattern, rather than the request URI, is what request metrics
+// are labeled with.
+func Route(pattern string, next http.Handler) http.Handler {
+	f := func(w http.ResponseWriter, r *http.Request) {
+		if v := GetValues(r.Context()); v != nil {
+			v.Route = pattern
+		}
+		AddLogFields(r.Context(), zap.String("route", pattern))
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(f)
+}
+
 // RequestMW is a middleware that creates a request id for each request
 // and sets it on the header field X-Request-Id. Also logs the start and
-// end of each request.
-func RequestMW(logger *zap.Logger, next http.Handler) http.Handler {
+// end of each request, records it within the request metrics and traces
+// it, continuing any trace propagated through W3C trace context headers.
+func RequestMW(logger *zap.Logger, m *metrics.Metrics, next http.Handler) http.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) {
 		st := time.Now()
 
@@ -55,18 +146,32 @@ func RequestMW(logger *zap.Logger, next http.Handler) http.Handler {
 			id = uuid.New()
 		}
 
-		logger.Info("request received",
+		ctx, span := tracing.StartHTTP(r, RedactedURI(r))
+		span.SetAttributes(requestIDAttribute.String(id))
+
+		v := Values{
+			RequestID: id,
+			Route:     unmatchedRoute,
+			Logger: logger.With(
+				zap.String("requestID", id),
+				zap.String("traceID", span.SpanContext().TraceID().String())),
+		}
+
+		r = r.WithContext(context.WithValue(ctx, keyValues, &v))
+
+		v.Logger.Info("request received",
 			zap.String("method", r.Method),
-			zap.String("requestID", id),
-			zap.String("requestURI", r.RequestURI))
+			zap.String("requestURI", RedactedURI(r)))
 
 		defer func() {
-			logger.Info("request completed",
+			v.Logger.Info("request completed",
 				zap.String("method", r.Method),
-				zap.String("requestID", id),
-				zap.String("requestURI", r.RequestURI),
+				zap.String("requestURI", RedactedURI(r)),
 				zap.Int64("time_ms", time.Since(st).Milliseconds()),
 				zap.Int("status", ww.status))
+
+			m.ObserveRequest(r.Method, v.Route, ww.status, time.Since(st))
+			tracing.EndHTTP(span, r.Method, v.Route, ww.status)
 		}()
 
 		ww.Header().Set(requestIDHeader, id)
@@ -0,0 +1,98 @@
+// Package web_test tests the web package.
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/22arw/lorafication/internal/platform/metrics"
+	"github.com/22arw/lorafication/internal/platform/tracing"
+	"github.com/22arw/lorafication/internal/platform/web"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+// TestRequestMW_Tracing tests that the RequestMW middleware continues a propagated trace
+// and records the matched route, request ID and redacted target on its span.
+func TestRequestMW_Tracing(t *testing.T) {
+	if _, err := tracing.Init(context.Background(), tracing.Config{}); err != nil {
+		t.Fatalf("initialize tracing: %v", err)
+	}
+
+	sr := tracetest.NewSpanRecorder()
+	tp := tracing.NewProvider(tracing.Config{SampleRatio: 1, ServiceName: "test"}, sr)
+	otel.SetTracerProvider(tp)
+	defer func() {
+		_ = tp.Shutdown(context.Background())
+	}()
+
+	h := web.RequestMW(zap.NewNop(), metrics.New(), web.Route("/thing/:id",
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req := httptest.NewRequest(http.MethodGet, "/thing/1?token=abc.def", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "request-id")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := sr.Ended()
+	if e, a := 1, len(spans); e != a {
+		t.Fatalf("expected %d ended span, got %d", e, a)
+	}
+	span := spans[0]
+
+	if e, a := "GET /thing/:id", span.Name(); e != a {
+		t.Errorf("expected span name to be \"%s\", got \"%s\"", e, a)
+	}
+
+	if e, a := traceID, span.SpanContext().TraceID().String(); e != a {
+		t.Errorf("expected span trace ID to be \"%s\", got \"%s\"", e, a)
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	if e, a := "request-id", attrs["http.request_id"].AsString(); e != a {
+		t.Errorf("expected http.request_id attribute to be \"%s\", got \"%s\"", e, a)
+	}
+
+	if e, a := int64(http.StatusNoContent), attrs["http.status_code"].AsInt64(); e != a {
+		t.Errorf("expected http.status_code attribute to be %d, got %d", e, a)
+	}
+
+	if e, a := "/thing/1?token=REDACTED", attrs["http.target"].AsString(); e != a {
+		t.Errorf("expected http.target attribute to be \"%s\", got \"%s\"", e, a)
+	}
+}
+
+// TestRedactedURI tests that RedactedURI redacts tokens from the query of a request while
+// keeping its path and other parameters.
+func TestRedactedURI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"/entity/1", "/entity/1"},
+		{"/unsubscribe?token=abc.def", "/unsubscribe?token=REDACTED"},
+		{"/entity/1/verify?contactPoint=2&token=abc", "/entity/1/verify?contactPoint=2&token=REDACTED"},
+		{"/notifications?node=1", "/notifications?node=1"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if e, a := tt.expected, web.RedactedURI(r); e != a {
+			t.Errorf("expected %q, got %q", e, a)
+		}
+	}
+}
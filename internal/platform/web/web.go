@@ -18,7 +18,8 @@ type Response struct {
 
 // ResponseError is the format used for response errors.
 type ResponseError struct {
-	Message string `json:"message"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
 }
 
 // Error implements the error interface.
@@ -26,8 +27,11 @@ func (a ResponseError) Error() string {
 	return a.Message
 }
 
-// Respond sends a response with a status code.
-func Respond(w http.ResponseWriter, r *http.Request, logger *zap.Logger, code int, data interface{}, errs ...error) {
+// Respond sends a response with a status code. Errors are logged using the request-scoped
+// logger.
+func Respond(w http.ResponseWriter, r *http.Request, code int, data interface{}, errs ...error) {
+	logger := Logger(r.Context())
+
 	var respErrs []ResponseError
 
 	if len(errs) > 0 {
@@ -42,35 +46,51 @@ func Respond(w http.ResponseWriter, r *http.Request, logger *zap.Logger, code in
 		Errors:  respErrs,
 	}
 
-	writeResponse(w, r, logger, code, &resp)
+	writeResponse(w, r, code, &resp)
 }
 
-// RespondError sends an error response with a status code. The error is automatically logged for you.
-// If the error implements StatusCoder, the provided status code will be used.
-func RespondError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, code int, err error) {
+// RespondError sends an error response with a status code. The error is automatically logged for you
+// using the request-scoped logger.
+// If the error implements StatusCoder, its status code will be used instead of the provided one.
+func RespondError(w http.ResponseWriter, r *http.Request, code int, err error) {
+	logger := Logger(r.Context())
 	logger.Error("error in unsuccessful request", zap.Error(err))
 
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		code = sc.StatusCode()
+	}
+
+	respErr := ResponseError{
+		Message: err.Error(),
+	}
+
+	// Errors of the Error type carry a message that is safe to respond with, excluding
+	// any context the error was wrapped with along the way.
+	if webErr, ok := asError(err); ok {
+		respErr.Message = webErr.Message
+		respErr.Fields = webErr.Fields
+	}
+
 	if code >= http.StatusInternalServerError {
 
 		// Respond with generic error. Error messages and and codes may potentially contain
 		// sensitive information or help an attacker.
 		code = http.StatusInternalServerError
-		err = errors.New(http.StatusText(http.StatusInternalServerError))
+		respErr = ResponseError{
+			Message: http.StatusText(http.StatusInternalServerError),
+		}
 	}
 
 	resp := Response{
-		Errors: []ResponseError{
-			{
-				Message: err.Error(),
-			},
-		},
+		Errors: []ResponseError{respErr},
 	}
 
-	writeResponse(w, r, logger, code, &resp)
+	writeResponse(w, r, code, &resp)
 }
 
 // writeResponse marshals the response to json and writes it to the response writer.
-func writeResponse(w http.ResponseWriter, r *http.Request, logger *zap.Logger, code int, resp *Response) {
+func writeResponse(w http.ResponseWriter, r *http.Request, code int, resp *Response) {
 	if code == http.StatusNoContent || resp == nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(code)
@@ -79,7 +99,7 @@ func writeResponse(w http.ResponseWriter, r *http.Request, logger *zap.Logger, c
 
 	b, err := json.Marshal(resp)
 	if err != nil {
-		RespondError(w, r, logger, http.StatusInternalServerError, err)
+		RespondError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -87,6 +107,6 @@ func writeResponse(w http.ResponseWriter, r *http.Request, logger *zap.Logger, c
 	w.WriteHeader(code)
 
 	if _, err := w.Write(b); err != nil {
-		logger.Error("write response body", zap.Error(err))
+		Logger(r.Context()).Error("write response body", zap.Error(err))
 	}
 }
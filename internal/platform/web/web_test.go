@@ -0,0 +1,68 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/22arw/lorafication/internal/platform/web"
+)
+
+// TestRespondError_StatusCoder tests that RespondError responds to typed errors with
+// their status code, message and field details, regardless of how they were wrapped.
+func TestRespondError_StatusCoder(t *testing.T) {
+	t.Parallel()
+
+	err := fmt.Errorf("create entity: %w", web.NewValidationError("request failed validation",
+		errors.New("pq: null value in column \"name\""), web.FieldError{Field: "name", Message: "must be provided"}))
+
+	w := httptest.NewRecorder()
+	web.RespondError(w, httptest.NewRequest(http.MethodPost, "/entity", nil), http.StatusInternalServerError, err)
+
+	if e, a := http.StatusUnprocessableEntity, w.Code; e != a {
+		t.Errorf("expected status code to be %d, got %d", e, a)
+	}
+
+	var resp web.Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response body: %v", err)
+	}
+
+	if e, a := 1, len(resp.Errors); e != a {
+		t.Fatalf("expected %d response error, got %d", e, a)
+	}
+
+	if e, a := "request failed validation", resp.Errors[0].Message; e != a {
+		t.Errorf("expected response error message to be \"%s\", got \"%s\"", e, a)
+	}
+
+	if e, a := []web.FieldError{{Field: "name", Message: "must be provided"}}, resp.Errors[0].Fields; len(a) != 1 || e[0] != a[0] {
+		t.Errorf("expected response error fields to be %v, got %v", e, a)
+	}
+}
+
+// TestRespondError_Internal tests that RespondError hides the details of errors that
+// are responded to with a 5xx status code.
+func TestRespondError_Internal(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	web.RespondError(w, httptest.NewRequest(http.MethodPost, "/notify", nil), http.StatusBadGateway,
+		errors.New("dial tcp: connection refused"))
+
+	if e, a := http.StatusInternalServerError, w.Code; e != a {
+		t.Errorf("expected status code to be %d, got %d", e, a)
+	}
+
+	var resp web.Response
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response body: %v", err)
+	}
+
+	if e, a := http.StatusText(http.StatusInternalServerError), resp.Errors[0].Message; e != a {
+		t.Errorf("expected response error message to be \"%s\", got \"%s\"", e, a)
+	}
+}